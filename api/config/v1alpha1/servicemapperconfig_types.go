@@ -0,0 +1,294 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+)
+
+// Feature gates of the service-mapper manager
+const (
+	// FeatureImportBindingAnnotations creates ServiceResourceMaps from the
+	// Service Binding annotations found on CRDs
+	FeatureImportBindingAnnotations = "ImportBindingAnnotations"
+	// FeatureServiceProxyBinding projects Service Endpoint Definitions into
+	// workloads through ServiceProxyBindings
+	FeatureServiceProxyBinding = "ServiceProxyBinding"
+)
+
+// defaultFeatureGates lists the known feature gates with their default value
+var defaultFeatureGates = map[string]bool{
+	FeatureImportBindingAnnotations: false,
+	FeatureServiceProxyBinding:      true,
+}
+
+// Defaults of the ServiceMapperSpec
+const (
+	DefaultInformerResyncPeriod     = time.Minute
+	DefaultCoalesceWindow           = time.Second
+	DefaultCertificateExpiryWarning = 30 * 24 * time.Hour
+	DefaultMaxConcurrentReconciles  = 1
+	DefaultRateLimiterBaseDelay     = 5 * time.Millisecond
+	DefaultRateLimiterMaxDelay      = 1000 * time.Second
+	DefaultRateLimiterQPS           = 10
+	DefaultRateLimiterBurst         = 100
+	DefaultShardingLeaseDuration    = 30 * time.Second
+	DefaultShardingRenewPeriod      = 10 * time.Second
+	DefaultShardingVirtualNodes     = 64
+)
+
+// Keys of the instances hashed to pick their replica
+const (
+	ShardingKeyUID       = "uid"
+	ShardingKeyNamespace = "namespace"
+)
+
+// ServiceMapperSpec holds the options specific to the service-mapper controllers
+type ServiceMapperSpec struct {
+	// InformerResyncPeriod is the resync period of the informers watching the
+	// service instances. Defaults to 1m.
+	// +optional
+	InformerResyncPeriod *metav1.Duration `json:"informerResyncPeriod,omitempty"`
+
+	// CoalesceWindow is the time the changes of an instance are collected
+	// before its Service Endpoint Definitions are updated once. Zero updates
+	// them on every change. Defaults to 1s.
+	// +optional
+	CoalesceWindow *metav1.Duration `json:"coalesceWindow,omitempty"`
+
+	// CertificateExpiryWarning is the time before the expiry of a bound
+	// certificate its ServiceProxy reports it as expiring. Defaults to 720h.
+	// +optional
+	CertificateExpiryWarning *metav1.Duration `json:"certificateExpiryWarning,omitempty"`
+
+	// MaxConcurrentReconciles is the number of concurrent reconciles of each
+	// controller. Defaults to 1.
+	// +optional
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+
+	// RateLimiter configures how failed reconciles are requeued
+	// +optional
+	RateLimiter RateLimiterSpec `json:"rateLimiter,omitempty"`
+
+	// FeatureGates enables or disables optional features
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+
+	// Sharding spreads the service instances over the replicas
+	// +optional
+	Sharding ShardingSpec `json:"sharding,omitempty"`
+}
+
+// ShardingSpec configures the sharding of the service instances: every
+// replica renews a Lease and handles the instances it owns on a consistent
+// hashing ring of the replicas.
+type ShardingSpec struct {
+	// Enabled runs the ServiceResourceMap controller on every replica
+	// instead of the elected one only
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Key is the attribute of the instances hashed to pick their replica,
+	// `uid` or `namespace`. Defaults to uid.
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// LeaseNamespace holds the Leases of the replicas. Defaults to the
+	// namespace of the manager.
+	// +optional
+	LeaseNamespace string `json:"leaseNamespace,omitempty"`
+
+	// LeaseDuration is the time a replica keeps its instances after its
+	// last renewal. Defaults to 30s.
+	// +optional
+	LeaseDuration *metav1.Duration `json:"leaseDuration,omitempty"`
+
+	// RenewPeriod is the time between two renewals of the Leases. Defaults
+	// to 10s.
+	// +optional
+	RenewPeriod *metav1.Duration `json:"renewPeriod,omitempty"`
+
+	// VirtualNodes is the number of virtual nodes of each replica on the
+	// ring, the higher the more even the spread. Defaults to 64.
+	// +optional
+	VirtualNodes int `json:"virtualNodes,omitempty"`
+}
+
+// RateLimiterSpec configures the rate limiter of the controllers' queues: the
+// slower of a per-item exponential backoff and of an overall token bucket.
+type RateLimiterSpec struct {
+	// BaseDelay is the delay of the first retry of an item. Defaults to 5ms.
+	// +optional
+	BaseDelay *metav1.Duration `json:"baseDelay,omitempty"`
+
+	// MaxDelay caps the exponential backoff of an item. Defaults to 1000s.
+	// +optional
+	MaxDelay *metav1.Duration `json:"maxDelay,omitempty"`
+
+	// QPS is the overall rate of retries. Defaults to 10.
+	// +optional
+	QPS int `json:"qps,omitempty"`
+
+	// Burst is the overall burst of retries. Defaults to 100.
+	// +optional
+	Burst int `json:"burst,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ServiceMapperConfig is the Schema for the service-mapper manager configuration file
+type ServiceMapperConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ControllerManagerConfigurationSpec returns the configurations for controllers
+	cfg.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// ServiceMapper holds the options specific to the service-mapper controllers
+	ServiceMapper ServiceMapperSpec `json:"serviceMapper,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceMapperConfig{})
+}
+
+// Default sets the default value of the unset options
+func (c *ServiceMapperConfig) Default() {
+	s := &c.ServiceMapper
+	if s.InformerResyncPeriod == nil {
+		s.InformerResyncPeriod = &metav1.Duration{Duration: DefaultInformerResyncPeriod}
+	}
+	if s.CoalesceWindow == nil {
+		s.CoalesceWindow = &metav1.Duration{Duration: DefaultCoalesceWindow}
+	}
+	if s.CertificateExpiryWarning == nil {
+		s.CertificateExpiryWarning = &metav1.Duration{Duration: DefaultCertificateExpiryWarning}
+	}
+	if s.MaxConcurrentReconciles == 0 {
+		s.MaxConcurrentReconciles = DefaultMaxConcurrentReconciles
+	}
+
+	rl := &s.RateLimiter
+	if rl.BaseDelay == nil {
+		rl.BaseDelay = &metav1.Duration{Duration: DefaultRateLimiterBaseDelay}
+	}
+	if rl.MaxDelay == nil {
+		rl.MaxDelay = &metav1.Duration{Duration: DefaultRateLimiterMaxDelay}
+	}
+	if rl.QPS == 0 {
+		rl.QPS = DefaultRateLimiterQPS
+	}
+	if rl.Burst == 0 {
+		rl.Burst = DefaultRateLimiterBurst
+	}
+
+	sh := &s.Sharding
+	if sh.Key == "" {
+		sh.Key = ShardingKeyUID
+	}
+	if sh.LeaseDuration == nil {
+		sh.LeaseDuration = &metav1.Duration{Duration: DefaultShardingLeaseDuration}
+	}
+	if sh.RenewPeriod == nil {
+		sh.RenewPeriod = &metav1.Duration{Duration: DefaultShardingRenewPeriod}
+	}
+	if sh.VirtualNodes == 0 {
+		sh.VirtualNodes = DefaultShardingVirtualNodes
+	}
+}
+
+// Validate returns an error describing every invalid option of a defaulted configuration
+func (c *ServiceMapperConfig) Validate() error {
+	s := c.ServiceMapper
+	var errs []string
+
+	if s.InformerResyncPeriod.Duration < 0 {
+		errs = append(errs, "serviceMapper.informerResyncPeriod must not be negative")
+	}
+	if s.CoalesceWindow.Duration < 0 {
+		errs = append(errs, "serviceMapper.coalesceWindow must not be negative")
+	}
+	if s.CertificateExpiryWarning.Duration < 0 {
+		errs = append(errs, "serviceMapper.certificateExpiryWarning must not be negative")
+	}
+	if s.MaxConcurrentReconciles < 1 {
+		errs = append(errs, "serviceMapper.maxConcurrentReconciles must be at least 1")
+	}
+
+	rl := s.RateLimiter
+	if rl.BaseDelay.Duration <= 0 {
+		errs = append(errs, "serviceMapper.rateLimiter.baseDelay must be positive")
+	}
+	if rl.MaxDelay.Duration < rl.BaseDelay.Duration {
+		errs = append(errs, "serviceMapper.rateLimiter.maxDelay must not be lower than baseDelay")
+	}
+	if rl.QPS < 1 {
+		errs = append(errs, "serviceMapper.rateLimiter.qps must be at least 1")
+	}
+	if rl.Burst < 1 {
+		errs = append(errs, "serviceMapper.rateLimiter.burst must be at least 1")
+	}
+
+	sh := s.Sharding
+	if sh.Key != ShardingKeyUID && sh.Key != ShardingKeyNamespace {
+		errs = append(errs, fmt.Sprintf("serviceMapper.sharding.key must be '%s' or '%s'", ShardingKeyUID, ShardingKeyNamespace))
+	}
+	if sh.RenewPeriod.Duration <= 0 {
+		errs = append(errs, "serviceMapper.sharding.renewPeriod must be positive")
+	}
+	if sh.LeaseDuration.Duration <= sh.RenewPeriod.Duration {
+		errs = append(errs, "serviceMapper.sharding.leaseDuration must be greater than renewPeriod")
+	}
+	if sh.VirtualNodes < 1 {
+		errs = append(errs, "serviceMapper.sharding.virtualNodes must be at least 1")
+	}
+
+	for f := range s.FeatureGates {
+		if _, ok := defaultFeatureGates[f]; !ok {
+			errs = append(errs, fmt.Sprintf("unknown feature gate '%s'", f))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+	return fmt.Errorf("invalid configuration: %s", strings.Join(errs, ", "))
+}
+
+// Enabled returns true if the feature gate is enabled
+func (s ServiceMapperSpec) Enabled(feature string) bool {
+	if e, ok := s.FeatureGates[feature]; ok {
+		return e
+	}
+	return defaultFeatureGates[feature]
+}
+
+// NewRateLimiter returns the rate limiter configured for the controllers' queues
+func (s RateLimiterSpec) NewRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(s.BaseDelay.Duration, s.MaxDelay.Duration),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(s.QPS), s.Burst)},
+	)
+}
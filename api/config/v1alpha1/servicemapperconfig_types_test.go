@@ -0,0 +1,102 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestConfigFile(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(AddToScheme(scheme)).To(Succeed())
+
+	c := ServiceMapperConfig{}
+	o, err := ctrl.Options{Scheme: scheme}.AndFrom(ctrl.ConfigFile().AtPath("../../../config/manager/controller_manager_config.yaml").OfKind(&c))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(o.LeaderElectionID).To(Equal("46e2453b.binding.operators.coreos.com"))
+	g.Expect(o.Port).To(Equal(9443))
+
+	c.Default()
+	g.Expect(c.Validate()).To(Succeed())
+	g.Expect(c.ServiceMapper.InformerResyncPeriod.Duration).To(Equal(time.Minute))
+	g.Expect(c.ServiceMapper.CoalesceWindow.Duration).To(Equal(time.Second))
+	g.Expect(c.ServiceMapper.RateLimiter.MaxDelay.Duration).To(Equal(1000 * time.Second))
+	g.Expect(c.ServiceMapper.Enabled(FeatureServiceProxyBinding)).To(BeTrue())
+	g.Expect(c.ServiceMapper.Enabled(FeatureImportBindingAnnotations)).To(BeFalse())
+}
+
+func TestDefault(t *testing.T) {
+	g := NewWithT(t)
+
+	c := ServiceMapperConfig{}
+	c.Default()
+	g.Expect(c.Validate()).To(Succeed())
+	g.Expect(c.ServiceMapper.InformerResyncPeriod.Duration).To(Equal(DefaultInformerResyncPeriod))
+	g.Expect(c.ServiceMapper.CoalesceWindow.Duration).To(Equal(DefaultCoalesceWindow))
+	g.Expect(c.ServiceMapper.CertificateExpiryWarning.Duration).To(Equal(DefaultCertificateExpiryWarning))
+	g.Expect(c.ServiceMapper.MaxConcurrentReconciles).To(Equal(DefaultMaxConcurrentReconciles))
+	g.Expect(c.ServiceMapper.RateLimiter.BaseDelay.Duration).To(Equal(DefaultRateLimiterBaseDelay))
+	g.Expect(c.ServiceMapper.RateLimiter.QPS).To(Equal(DefaultRateLimiterQPS))
+	g.Expect(c.ServiceMapper.Enabled(FeatureServiceProxyBinding)).To(BeTrue())
+	g.Expect(c.ServiceMapper.RateLimiter.NewRateLimiter().When("item")).To(Equal(DefaultRateLimiterBaseDelay))
+	g.Expect(c.ServiceMapper.Sharding.Enabled).To(BeFalse())
+	g.Expect(c.ServiceMapper.Sharding.Key).To(Equal(ShardingKeyUID))
+	g.Expect(c.ServiceMapper.Sharding.LeaseDuration.Duration).To(Equal(DefaultShardingLeaseDuration))
+	g.Expect(c.ServiceMapper.Sharding.VirtualNodes).To(Equal(DefaultShardingVirtualNodes))
+}
+
+func TestValidate(t *testing.T) {
+	g := NewWithT(t)
+
+	c := ServiceMapperConfig{
+		ServiceMapper: ServiceMapperSpec{
+			InformerResyncPeriod:     &metav1.Duration{Duration: -time.Second},
+			CoalesceWindow:           &metav1.Duration{Duration: -time.Second},
+			CertificateExpiryWarning: &metav1.Duration{Duration: -time.Hour},
+			MaxConcurrentReconciles:  -1,
+			RateLimiter: RateLimiterSpec{
+				BaseDelay: &metav1.Duration{Duration: time.Second},
+				MaxDelay:  &metav1.Duration{Duration: time.Millisecond},
+			},
+			FeatureGates: map[string]bool{"Unknown": true},
+			Sharding: ShardingSpec{
+				Key:           "name",
+				LeaseDuration: &metav1.Duration{Duration: time.Second},
+			},
+		},
+	}
+	c.Default()
+
+	err := c.Validate()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("informerResyncPeriod must not be negative"))
+	g.Expect(err.Error()).To(ContainSubstring("coalesceWindow must not be negative"))
+	g.Expect(err.Error()).To(ContainSubstring("certificateExpiryWarning must not be negative"))
+	g.Expect(err.Error()).To(ContainSubstring("maxConcurrentReconciles must be at least 1"))
+	g.Expect(err.Error()).To(ContainSubstring("maxDelay must not be lower than baseDelay"))
+	g.Expect(err.Error()).To(ContainSubstring("unknown feature gate 'Unknown'"))
+	g.Expect(err.Error()).To(ContainSubstring("sharding.key must be 'uid' or 'namespace'"))
+	g.Expect(err.Error()).To(ContainSubstring("sharding.leaseDuration must be greater than renewPeriod"))
+}
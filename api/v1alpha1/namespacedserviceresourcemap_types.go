@@ -0,0 +1,74 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespacedServiceResourceMapSpec defines the desired state of
+// NamespacedServiceResourceMap
+type NamespacedServiceResourceMapSpec struct {
+	ServiceResourceMapSpec `json:",inline"`
+
+	// ServiceAccountName is the ServiceAccount of the namespace on behalf of
+	// which the instances are watched and the Secrets, ConfigMaps, Services
+	// and Certificates referenced by the rules are read: the instances are not
+	// watched unless it can get, list and watch them, and the rules referring
+	// to objects it can not get fail. Only users who can impersonate it may set the spec.
+	// Defaults to `default`.
+	//+optional
+	ServiceAccountName string `json:"service_account_name,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=nsrm
+//+kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].reason"
+
+// NamespacedServiceResourceMap is the Schema for the
+// namespacedserviceresourcemaps API: a ServiceResourceMap mapping the
+// instances of its own namespace only
+type NamespacedServiceResourceMap struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespacedServiceResourceMapSpec `json:"spec,omitempty"`
+	Status ServiceResourceMapStatus         `json:"status,omitempty"`
+}
+
+// ServiceAccount returns the ServiceAccount reading the referenced objects
+func (m *NamespacedServiceResourceMap) ServiceAccount() string {
+	if m.Spec.ServiceAccountName == "" {
+		return "default"
+	}
+	return m.Spec.ServiceAccountName
+}
+
+//+kubebuilder:object:root=true
+
+// NamespacedServiceResourceMapList contains a list of NamespacedServiceResourceMap
+type NamespacedServiceResourceMapList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacedServiceResourceMap `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespacedServiceResourceMap{}, &NamespacedServiceResourceMapList{})
+}
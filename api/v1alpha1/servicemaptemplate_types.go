@@ -0,0 +1,54 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceMapTemplateSpec defines the desired state of ServiceMapTemplate
+type ServiceMapTemplateSpec struct {
+	// ServiceMap holds the rules inherited by the service_map of the maps
+	// extending the template
+	ServiceMap map[string]string `json:"service_map"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster,shortName=smt
+
+// ServiceMapTemplate is the Schema for the servicemaptemplates API: rules
+// shared by the ServiceResourceMaps and NamespacedServiceResourceMaps
+// naming it in their `extends`
+type ServiceMapTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceMapTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ServiceMapTemplateList contains a list of ServiceMapTemplate
+type ServiceMapTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceMapTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceMapTemplate{}, &ServiceMapTemplateList{})
+}
@@ -25,8 +25,20 @@ import (
 
 // ServiceProxySpec defines the desired state of ServiceProxy
 type ServiceProxySpec struct {
-	ServiceResourceMapRef string         `json:"service_resource_map"`
-	ServiceInstance       NamespacedName `json:"service_instance"`
+	ServiceResourceMapRef string `json:"service_resource_map"`
+
+	// ServiceResourceMapKind is the kind of the map referred to by
+	// ServiceResourceMapRef: ServiceResourceMap if empty, or
+	// NamespacedServiceResourceMap for the maps of the ServiceProxy namespace
+	//+optional
+	ServiceResourceMapKind string `json:"service_resource_map_kind,omitempty"`
+
+	ServiceInstance NamespacedName `json:"service_instance"`
+
+	// Output is the name of the ServiceResourceMap output exposed by this
+	// ServiceProxy, empty for the default one
+	//+optional
+	Output string `json:"output,omitempty"`
 }
 
 type NamespacedName struct {
@@ -37,14 +49,46 @@ type NamespacedName struct {
 // ServiceProxyStatus defines the observed state of ServiceProxy
 type ServiceProxyStatus struct {
 	Binding ServiceProxyStatusBinding `json:"binding"`
+
+	// Outputs records the resources generated for the named outputs of the
+	// ServiceResourceMap
+	//+optional
+	Outputs []ServiceProxyStatusOutput `json:"outputs,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// ServiceProxy conditions
+const (
+	// ServiceProxyConditionReady is true once the Service Endpoint Definition
+	// is published, false while the instance is not ready
+	ServiceProxyConditionReady = "Ready"
+	// ServiceProxyConditionCertificatesValid is false once a certificate of
+	// the Service Endpoint Definitions is about to expire or expired. It is
+	// only set if they hold PEM encoded certificates.
+	ServiceProxyConditionCertificatesValid = "CertificatesValid"
+)
+
 type ServiceProxyStatusBinding struct {
 	Name string `json:"name"`
 }
 
+type ServiceProxyStatusOutput struct {
+	Name string `json:"name"`
+
+	// SEDName is the name of the output's Service Endpoint Definition
+	SEDName string `json:"sed_name"`
+
+	// ServiceProxy is the name of the output's dedicated ServiceProxy, if any
+	//+optional
+	ServiceProxy string `json:"service_proxy,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
 
 // ServiceProxy is the Schema for the serviceproxies API
 type ServiceProxy struct {
@@ -0,0 +1,113 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceProxyBindingSpec defines the desired state of ServiceProxyBinding
+type ServiceProxyBindingSpec struct {
+	// ServiceProxy is the name of the ServiceProxy, in the same namespace,
+	// whose Service Endpoint Definition is projected
+	ServiceProxy string `json:"service_proxy"`
+
+	// Workload is the workload, in the same namespace, the Service Endpoint
+	// Definition is projected into
+	Workload WorkloadReference `json:"workload"`
+
+	// Name of the binding, that is the directory under $SERVICE_BINDING_ROOT
+	// the Service Endpoint Definition is mounted at.
+	// Defaults to the name of the ServiceProxyBinding.
+	//+optional
+	Name string `json:"name,omitempty"`
+
+	// InjectEnv injects the environment variables declared by the
+	// ServiceResourceMap in the workload's containers, as references to the
+	// Service Endpoint Definition's keys
+	//+optional
+	InjectEnv bool `json:"inject_env,omitempty"`
+}
+
+// WorkloadReference identifies a Deployment, StatefulSet, DaemonSet or CronJob
+type WorkloadReference struct {
+	ApiVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// ServiceProxyBindingStatus defines the observed state of ServiceProxyBinding
+type ServiceProxyBindingStatus struct {
+	// Binding is the Service Endpoint Definition projected into the workload
+	//+optional
+	Binding ServiceProxyStatusBinding `json:"binding,omitempty"`
+
+	// Workload is the workload the Service Endpoint Definition is projected into
+	//+optional
+	Workload *WorkloadReference `json:"workload,omitempty"`
+
+	// InjectedEnv lists the environment variables injected in the containers
+	// of the workload, as `<container>/<variable>`
+	//+optional
+	InjectedEnv []string `json:"injected_env,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ServiceProxyBinding conditions
+const (
+	ServiceProxyBindingConditionReady = "Ready"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+
+// ServiceProxyBinding projects the Service Endpoint Definition of a
+// ServiceProxy into a workload, following the workload projection rules of
+// the Service Binding specification, without requiring the Service Binding
+// Operator
+type ServiceProxyBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceProxyBindingSpec   `json:"spec,omitempty"`
+	Status ServiceProxyBindingStatus `json:"status,omitempty"`
+}
+
+// BindingName returns the name of the binding
+func (b *ServiceProxyBinding) BindingName() string {
+	if b.Spec.Name != "" {
+		return b.Spec.Name
+	}
+	return b.Name
+}
+
+//+kubebuilder:object:root=true
+
+// ServiceProxyBindingList contains a list of ServiceProxyBinding
+type ServiceProxyBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceProxyBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceProxyBinding{}, &ServiceProxyBindingList{})
+}
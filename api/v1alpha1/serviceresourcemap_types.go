@@ -29,18 +29,161 @@ type ServiceResourceMapSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	ServiceKindReference ServiceKindReference `json:"service_kind_reference"`
-	ServiceMap           map[string]string    `json:"service_map"`
+
+	// Selector restricts the mapped instances to the ones whose labels match
+	//+optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// FieldSelector restricts the mapped instances to the ones whose fields
+	// match, e.g. `metadata.namespace!=kube-system`
+	//+optional
+	FieldSelector string `json:"field_selector,omitempty"`
+
+	// Priority orders the maps selecting the same instances: the map with the
+	// highest priority claims them, unless they are pinned to another map with
+	// the `servicemapper.binding/service-resource-map` annotation
+	//+optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// Readiness is the state the instances must reach before their Service
+	// Endpoint Definitions are published and their ServiceProxies are ready
+	//+optional
+	Readiness *ServiceResourceMapReadiness `json:"readiness,omitempty"`
+
+	// Extends names the ServiceMapTemplates whose rules the service_map
+	// inherits: the rules of later templates replace the ones of earlier
+	// templates, and the service_map replaces the rules of all of them
+	//+optional
+	Extends []string `json:"extends,omitempty"`
+
+	// ServiceMap holds the rules of the default Service Endpoint Definition
+	//+optional
+	ServiceMap map[string]string `json:"service_map,omitempty"`
+
+	// Cases are blocks of rules merged into the service_map for the
+	// instances matching their condition, in order: the rules of later
+	// blocks replace the ones of earlier blocks
+	//+optional
+	Cases []ServiceResourceMapCase `json:"cases,omitempty"`
+
+	// SEDName is the name of the default Service Endpoint Definition.
+	// `{instance}`, which it must contain, is replaced with the name of the
+	// instance.
+	// Defaults to `{instance}-sed`.
+	//+optional
+	SEDName string `json:"sed_name,omitempty"`
+
+	// Env maps keys of the default Service Endpoint Definition to the names
+	// of the environment variables injected by ServiceProxyBindings
+	// requesting it
+	//+optional
+	Env map[string]string `json:"env,omitempty"`
+
+	// Outputs are additional Service Endpoint Definitions generated for
+	// every instance, e.g. admin and read-only credentials.
+	//+optional
+	Outputs []ServiceResourceMapOutput `json:"outputs,omitempty"`
+
+	// AllowOverrides honors the `servicemapper.binding/override.<key>`
+	// annotations of the instances, which then add or replace rules of the
+	// service_map. As overriding rules may read any field of the instance
+	// and any Secret or ConfigMap of its namespace, they are ignored unless
+	// allowed.
+	//+optional
+	AllowOverrides bool `json:"allow_overrides,omitempty"`
+}
+
+// ServiceResourceMapReadiness is the state of a ready instance: all the set
+// fields must be satisfied
+type ServiceResourceMapReadiness struct {
+	// ConditionType is the type of a condition of the instance's
+	// `status.conditions` whose status must be ConditionStatus, e.g. `Ready`
+	//+optional
+	ConditionType string `json:"condition_type,omitempty"`
+
+	// ConditionStatus is the status of the ConditionType condition of ready
+	// instances. Defaults to `True`.
+	//+optional
+	ConditionStatus metav1.ConditionStatus `json:"condition_status,omitempty"`
+
+	// When is a condition on the instance, in the grammar of the cases, e.g.
+	// `.status.endpoint.address` or `.status.phase == "available"`
+	//+optional
+	When string `json:"when,omitempty"`
+}
+
+// ServiceResourceMapCase is a block of rules applied to the instances
+// matching its condition
+type ServiceResourceMapCase struct {
+	// When is the condition on the instance: a JSONPath compared to a value
+	// with `==` or `!=`, e.g. `.spec.engine == "mysql"`, or alone to require
+	// the field to be set, not empty and not `false`
+	When string `json:"when"`
+
+	// ServiceMap holds the rules merged into the service_map of the
+	// instances matching the condition
+	ServiceMap map[string]string `json:"service_map"`
+}
+
+// ServiceResourceMapOutput is a named Service Endpoint Definition generated
+// for every instance
+type ServiceResourceMapOutput struct {
+	// Name identifies the output within the ServiceResourceMap
+	Name string `json:"name"`
+
+	// SEDName is the name of the output's Service Endpoint Definition.
+	// `{instance}`, which it must contain, is replaced with the name of the
+	// instance.
+	// Defaults to `{instance}-<name>-sed`.
+	//+optional
+	SEDName string `json:"sed_name,omitempty"`
+
+	// ServiceProxy requests a dedicated ServiceProxy, named
+	// `{instance}-<name>`, exposing the output's Service Endpoint Definition
+	//+optional
+	ServiceProxy bool `json:"service_proxy,omitempty"`
+
+	// Env maps keys of the output's Service Endpoint Definition to the names
+	// of the environment variables injected by ServiceProxyBindings
+	// requesting it
+	//+optional
+	Env map[string]string `json:"env,omitempty"`
+
+	// ServiceMap holds the rules of the output's Service Endpoint Definition
+	ServiceMap map[string]string `json:"service_map"`
 }
 
 // ServiceResourceMapStatus defines the observed state of ServiceResourceMap
 type ServiceResourceMapStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the generation of the spec applied to the instances
+	//+optional
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// ServiceResourceMap conditions
+const (
+	// ServiceResourceMapConditionSynced is true once the informer watching the
+	// instances has synced, false while it is syncing or failing its watch
+	ServiceResourceMapConditionSynced = "Synced"
+
+	// ServiceResourceMapConditionInstanceClaimed is true when instances
+	// selected by the map are claimed by other maps, which the message lists
+	ServiceResourceMapConditionInstanceClaimed = "InstanceClaimed"
+
+	// ServiceResourceMapConditionNameCollision is true when the SEDs or
+	// ServiceProxies of instances selected by the map have the names of the
+	// ones of other instances or outputs, which the message lists
+	ServiceResourceMapConditionNameCollision = "NameCollision"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].reason"
 
 // ServiceResourceMap is the Schema for the serviceresourcemaps API
 type ServiceResourceMap struct {
@@ -22,6 +22,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -40,6 +41,81 @@ func (in *NamespacedName) DeepCopy() *NamespacedName {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedServiceResourceMap) DeepCopyInto(out *NamespacedServiceResourceMap) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedServiceResourceMap.
+func (in *NamespacedServiceResourceMap) DeepCopy() *NamespacedServiceResourceMap {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedServiceResourceMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespacedServiceResourceMap) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedServiceResourceMapList) DeepCopyInto(out *NamespacedServiceResourceMapList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespacedServiceResourceMap, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedServiceResourceMapList.
+func (in *NamespacedServiceResourceMapList) DeepCopy() *NamespacedServiceResourceMapList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedServiceResourceMapList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespacedServiceResourceMapList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedServiceResourceMapSpec) DeepCopyInto(out *NamespacedServiceResourceMapSpec) {
+	*out = *in
+	in.ServiceResourceMapSpec.DeepCopyInto(&out.ServiceResourceMapSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedServiceResourceMapSpec.
+func (in *NamespacedServiceResourceMapSpec) DeepCopy() *NamespacedServiceResourceMapSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedServiceResourceMapSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceKindReference) DeepCopyInto(out *ServiceKindReference) {
 	*out = *in
@@ -55,13 +131,93 @@ func (in *ServiceKindReference) DeepCopy() *ServiceKindReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceMapTemplate) DeepCopyInto(out *ServiceMapTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceMapTemplate.
+func (in *ServiceMapTemplate) DeepCopy() *ServiceMapTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceMapTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceMapTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceMapTemplateList) DeepCopyInto(out *ServiceMapTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ServiceMapTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceMapTemplateList.
+func (in *ServiceMapTemplateList) DeepCopy() *ServiceMapTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceMapTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceMapTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceMapTemplateSpec) DeepCopyInto(out *ServiceMapTemplateSpec) {
+	*out = *in
+	if in.ServiceMap != nil {
+		in, out := &in.ServiceMap, &out.ServiceMap
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceMapTemplateSpec.
+func (in *ServiceMapTemplateSpec) DeepCopy() *ServiceMapTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceMapTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceProxy) DeepCopyInto(out *ServiceProxy) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceProxy.
@@ -82,6 +238,114 @@ func (in *ServiceProxy) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceProxyBinding) DeepCopyInto(out *ServiceProxyBinding) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceProxyBinding.
+func (in *ServiceProxyBinding) DeepCopy() *ServiceProxyBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceProxyBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceProxyBinding) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceProxyBindingList) DeepCopyInto(out *ServiceProxyBindingList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ServiceProxyBinding, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceProxyBindingList.
+func (in *ServiceProxyBindingList) DeepCopy() *ServiceProxyBindingList {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceProxyBindingList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceProxyBindingList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceProxyBindingSpec) DeepCopyInto(out *ServiceProxyBindingSpec) {
+	*out = *in
+	out.Workload = in.Workload
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceProxyBindingSpec.
+func (in *ServiceProxyBindingSpec) DeepCopy() *ServiceProxyBindingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceProxyBindingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceProxyBindingStatus) DeepCopyInto(out *ServiceProxyBindingStatus) {
+	*out = *in
+	out.Binding = in.Binding
+	if in.Workload != nil {
+		in, out := &in.Workload, &out.Workload
+		*out = new(WorkloadReference)
+		**out = **in
+	}
+	if in.InjectedEnv != nil {
+		in, out := &in.InjectedEnv, &out.InjectedEnv
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceProxyBindingStatus.
+func (in *ServiceProxyBindingStatus) DeepCopy() *ServiceProxyBindingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceProxyBindingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceProxyList) DeepCopyInto(out *ServiceProxyList) {
 	*out = *in
@@ -134,6 +398,18 @@ func (in *ServiceProxySpec) DeepCopy() *ServiceProxySpec {
 func (in *ServiceProxyStatus) DeepCopyInto(out *ServiceProxyStatus) {
 	*out = *in
 	out.Binding = in.Binding
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]ServiceProxyStatusOutput, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceProxyStatus.
@@ -161,13 +437,28 @@ func (in *ServiceProxyStatusBinding) DeepCopy() *ServiceProxyStatusBinding {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceProxyStatusOutput) DeepCopyInto(out *ServiceProxyStatusOutput) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceProxyStatusOutput.
+func (in *ServiceProxyStatusOutput) DeepCopy() *ServiceProxyStatusOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceProxyStatusOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceResourceMap) DeepCopyInto(out *ServiceResourceMap) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceResourceMap.
@@ -188,6 +479,28 @@ func (in *ServiceResourceMap) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceResourceMapCase) DeepCopyInto(out *ServiceResourceMapCase) {
+	*out = *in
+	if in.ServiceMap != nil {
+		in, out := &in.ServiceMap, &out.ServiceMap
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceResourceMapCase.
+func (in *ServiceResourceMapCase) DeepCopy() *ServiceResourceMapCase {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceResourceMapCase)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceResourceMapList) DeepCopyInto(out *ServiceResourceMapList) {
 	*out = *in
@@ -220,10 +533,69 @@ func (in *ServiceResourceMapList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceResourceMapOutput) DeepCopyInto(out *ServiceResourceMapOutput) {
+	*out = *in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServiceMap != nil {
+		in, out := &in.ServiceMap, &out.ServiceMap
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceResourceMapOutput.
+func (in *ServiceResourceMapOutput) DeepCopy() *ServiceResourceMapOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceResourceMapOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceResourceMapReadiness) DeepCopyInto(out *ServiceResourceMapReadiness) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceResourceMapReadiness.
+func (in *ServiceResourceMapReadiness) DeepCopy() *ServiceResourceMapReadiness {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceResourceMapReadiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceResourceMapSpec) DeepCopyInto(out *ServiceResourceMapSpec) {
 	*out = *in
 	out.ServiceKindReference = in.ServiceKindReference
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(ServiceResourceMapReadiness)
+		**out = **in
+	}
+	if in.Extends != nil {
+		in, out := &in.Extends, &out.Extends
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.ServiceMap != nil {
 		in, out := &in.ServiceMap, &out.ServiceMap
 		*out = make(map[string]string, len(*in))
@@ -231,6 +603,27 @@ func (in *ServiceResourceMapSpec) DeepCopyInto(out *ServiceResourceMapSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Cases != nil {
+		in, out := &in.Cases, &out.Cases
+		*out = make([]ServiceResourceMapCase, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]ServiceResourceMapOutput, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceResourceMapSpec.
@@ -246,6 +639,13 @@ func (in *ServiceResourceMapSpec) DeepCopy() *ServiceResourceMapSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceResourceMapStatus) DeepCopyInto(out *ServiceResourceMapStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceResourceMapStatus.
@@ -257,3 +657,18 @@ func (in *ServiceResourceMapStatus) DeepCopy() *ServiceResourceMapStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadReference) DeepCopyInto(out *WorkloadReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadReference.
+func (in *WorkloadReference) DeepCopy() *WorkloadReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadReference)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/openshift-app-service-poc/service-mapper/pkg/suggest"
+)
+
+// runImport prints the ServiceResourceMap equivalent to the Service Binding
+// annotations of the given CRD:
+//
+//	srmctl import [-f crd.yaml] [crd-name]
+func runImport(args []string, out io.Writer) error {
+	var cf crdFlags
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	cf.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	crd, err := cf.load(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	sm, skipped, err := suggest.FromBindingAnnotations(crd)
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "warning: skipped %v\n", s)
+	}
+	if err != nil {
+		return err
+	}
+	return printYAML(out, sm)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+}
+
+type command struct {
+	description string
+	run         func(args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"suggest": {
+		description: "Generate a draft ServiceResourceMap from a CRD's OpenAPI schema",
+		run:         runSuggest,
+	},
+	"import": {
+		description: "Convert a CRD's Service Binding annotations into a ServiceResourceMap",
+		run:         runImport,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	c, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(1)
+	}
+
+	if err := c.run(os.Args[2:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for n, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", n, c.description)
+	}
+}
+
+// crdFlags are the flags shared by the commands reading a CRD
+type crdFlags struct {
+	file string
+}
+
+func (f *crdFlags) bind(fs *flag.FlagSet) {
+	fs.StringVar(&f.file, "f", "", "Read the CRD from the given file instead of the cluster.")
+}
+
+// load reads the CRD from the file, if any, or from the cluster pointed by
+// the current kubeconfig context
+func (f *crdFlags) load(ctx context.Context, name string) (*apiextensionsv1.CustomResourceDefinition, error) {
+	var crd apiextensionsv1.CustomResourceDefinition
+
+	if f.file != "" {
+		b, err := os.ReadFile(f.file)
+		if err != nil {
+			return nil, fmt.Errorf("can not read CRD file '%s': %w", f.file, err)
+		}
+		if err := yaml.UnmarshalStrict(b, &crd); err != nil {
+			return nil, fmt.Errorf("can not parse CRD file '%s': %w", f.file, err)
+		}
+		return &crd, nil
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("either a CRD name or a file is required")
+	}
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cli.Get(ctx, client.ObjectKey{Name: name}, &crd); err != nil {
+		return nil, fmt.Errorf("can not retrieve CRD '%s': %w", name, err)
+	}
+	return &crd, nil
+}
+
+func printYAML(out io.Writer, obj interface{}) error {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(b)
+	return err
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	"github.com/openshift-app-service-poc/service-mapper/pkg/suggest"
+)
+
+// runSuggest prints a draft ServiceResourceMap for the given CRD:
+//
+//	srmctl suggest [-f crd.yaml] [crd-name]
+func runSuggest(args []string, out io.Writer) error {
+	var cf crdFlags
+	fs := flag.NewFlagSet("suggest", flag.ContinueOnError)
+	cf.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	crd, err := cf.load(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	sm, err := suggest.FromCRD(crd)
+	if err != nil {
+		return err
+	}
+	return printYAML(out, sm)
+}
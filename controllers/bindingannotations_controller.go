@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/suggest"
+)
+
+// BindingAnnotationsReconciler imports the Service Binding annotations of
+// CRDs into ServiceResourceMaps
+type BindingAnnotationsReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	Options controller.Options
+}
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+
+// Reconcile creates or updates the ServiceResourceMap equivalent to the
+// Service Binding annotations of a CRD. The ServiceResourceMap is owned by
+// the CRD, and deleted when the CRD loses its annotations or is deleted.
+func (r *BindingAnnotationsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := r.Get(ctx, req.NamespacedName, &crd); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.deleteImported(ctx, req.Name)
+	}
+
+	if !suggest.HasBindingAnnotations(&crd) {
+		return ctrl.Result{}, r.deleteImported(ctx, crd.Name)
+	}
+
+	sm, skipped, err := suggest.FromBindingAnnotations(&crd)
+	for _, s := range skipped {
+		l.Info("skipping Service Binding annotation", "crd", crd.Name, "reason", s.Error())
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var esm bindingoperatorscoreoscomv1alpha1.ServiceResourceMap
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sm), &esm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		if err := controllerutil.SetControllerReference(&crd, sm, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		l.Info("creating ServiceResourceMap from Service Binding annotations", "crd", crd.Name, "srm", sm.Name)
+		if err := r.Create(ctx, sm); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error creating ServiceResourceMap '%s': %w", sm.Name, err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// never overwrite ServiceResourceMaps written by administrators
+	if esm.Annotations[suggest.AnnotationImportedFrom] != crd.Name {
+		l.Info("ServiceResourceMap already exists and was not imported from CRD, skipping", "crd", crd.Name, "srm", sm.Name)
+		return ctrl.Result{}, nil
+	}
+
+	esm.Spec = sm.Spec
+	if err := r.Update(ctx, &esm); err != nil {
+		return ctrl.Result{}, fmt.Errorf("error updating ServiceResourceMap '%s': %w", sm.Name, err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// deleteImported deletes the ServiceResourceMap imported from the CRD, if
+// any. ServiceResourceMaps written by administrators are kept.
+func (r *BindingAnnotationsReconciler) deleteImported(ctx context.Context, crd string) error {
+	var sms bindingoperatorscoreoscomv1alpha1.ServiceResourceMapList
+	if err := r.List(ctx, &sms); err != nil {
+		return fmt.Errorf("error listing ServiceResourceMaps: %w", err)
+	}
+
+	for i := range sms.Items {
+		sm := &sms.Items[i]
+		owner := metav1.GetControllerOf(sm)
+		if sm.Annotations[suggest.AnnotationImportedFrom] != crd ||
+			owner == nil || owner.Kind != "CustomResourceDefinition" || owner.Name != crd {
+			continue
+		}
+
+		log.FromContext(ctx).Info("deleting ServiceResourceMap imported from Service Binding annotations", "crd", crd, "srm", sm.Name)
+		if err := r.Delete(ctx, sm); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("error deleting ServiceResourceMap '%s': %w", sm.Name, err)
+		}
+	}
+	return nil
+}
+
+// importedPredicate filters the CRDs that carry Service Binding annotations,
+// or carried them before an update or their deletion
+func importedPredicate() predicate.Predicate {
+	annotated := func(o client.Object) bool {
+		crd, ok := o.(*apiextensionsv1.CustomResourceDefinition)
+		return !ok || suggest.HasBindingAnnotations(crd)
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return annotated(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return annotated(e.ObjectOld) || annotated(e.ObjectNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return annotated(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return annotated(e.Object) },
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BindingAnnotationsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiextensionsv1.CustomResourceDefinition{}).
+		Owns(&bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{}).
+		WithOptions(r.Options).
+		WithEventFilter(importedPredicate()).
+		Complete(r)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/suggest"
+)
+
+func TestBindingAnnotationsRemoved(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(bindingoperatorscoreoscomv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "dbinstances.rds.services.k8s.aws",
+			Annotations: map[string]string{"service.binding/host": "path={.status.endpoint.address}"},
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "rds.services.k8s.aws",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "DBInstance", Plural: "dbinstances"},
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Storage: true},
+			},
+		},
+	}
+	cli := newCountingClient()
+	g.Expect(cli.Create(ctx, crd)).To(Succeed())
+	// maps written by administrators are never deleted
+	g.Expect(cli.Create(ctx, &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "admin",
+			Annotations: map[string]string{suggest.AnnotationImportedFrom: crd.Name},
+		},
+	})).To(Succeed())
+
+	r := &BindingAnnotationsReconciler{Client: cli, Scheme: scheme}
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Name: crd.Name}}
+	imported := func() error {
+		return cli.Get(ctx, client.ObjectKey{Name: crd.Name}, &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{})
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(imported()).To(Succeed())
+
+	// removing the annotations deletes the imported map
+	crd.Annotations = nil
+	g.Expect(cli.Update(ctx, crd)).To(Succeed())
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apierrors.IsNotFound(imported())).To(BeTrue())
+
+	// so does deleting the CRD
+	crd.Annotations = map[string]string{"service.binding/host": "path={.status.endpoint.address}"}
+	g.Expect(cli.Update(ctx, crd)).To(Succeed())
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(imported()).To(Succeed())
+
+	g.Expect(cli.Delete(ctx, crd)).To(Succeed())
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apierrors.IsNotFound(imported())).To(BeTrue())
+	g.Expect(cli.Get(ctx, client.ObjectKey{Name: "admin"}, &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{})).To(Succeed())
+}
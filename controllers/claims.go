@@ -0,0 +1,202 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/claims"
+)
+
+// Reasons of the InstanceClaimed condition
+const (
+	ReasonInstancesClaimed = "InstancesClaimed"
+	ReasonNoConflict       = "NoConflict"
+)
+
+// maxClaimedInstances is the number of instances claimed by other maps
+// listed in the InstanceClaimed condition
+const maxClaimedInstances = 10
+
+// claimant returns the key of the map claiming the instance among the maps
+// watching the same service and selecting it, sm included
+func (r *ServiceResourceMapReconciler) claimant(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	u *unstructured.Unstructured) string {
+	l := log.FromContext(ctx)
+	key := mapKey(sm)
+	maps := []claims.Map{claimOf(sm)}
+
+	var sms bindingoperatorscoreoscomv1alpha1.ServiceResourceMapList
+	if err := r.List(ctx, &sms); err != nil {
+		l.Error(err, "can not list the ServiceResourceMaps claiming the instance", "srm", key)
+		return key
+	}
+	var ms bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapList
+	if err := r.List(ctx, &ms, client.InNamespace(u.GetNamespace())); err != nil {
+		l.Error(err, "can not list the NamespacedServiceResourceMaps claiming the instance", "srm", key)
+		return key
+	}
+
+	others := make([]*bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, 0, len(sms.Items)+len(ms.Items))
+	for i := range sms.Items {
+		others = append(others, &sms.Items[i])
+	}
+	for i := range ms.Items {
+		others = append(others, mapOf(&ms.Items[i]))
+	}
+
+	for _, o := range others {
+		// only the maps whose instances are watched claim them
+		if _, ok := r.informers.State(mapKey(o)); !ok || mapKey(o) == key || o.DeletionTimestamp != nil {
+			continue
+		}
+		if sameService(o.Spec.ServiceKindReference, sm.Spec.ServiceKindReference) && selects(&o.Spec, u) {
+			maps = append(maps, claimOf(o))
+		}
+	}
+	return claims.Winner(maps, u.GetAnnotations()[claims.AnnotationPin]).Key
+}
+
+func claimOf(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) claims.Map {
+	return claims.Map{
+		Key:        mapKey(sm),
+		Namespaced: sm.Namespace != "",
+		Priority:   sm.Spec.Priority,
+		Created:    sm.CreationTimestamp,
+	}
+}
+
+// sameService returns true if the references name the same resource, in any
+// version
+func sameService(a, b bindingoperatorscoreoscomv1alpha1.ServiceKindReference) bool {
+	ga, erra := schema.ParseGroupVersion(a.ApiGroup)
+	gb, errb := schema.ParseGroupVersion(b.ApiGroup)
+	if erra != nil || errb != nil {
+		return a == b
+	}
+	return ga.Group == gb.Group && a.Kind == b.Kind
+}
+
+// selects returns true if the selectors of the map match the instance. Only
+// the requirements of the field selector on the name and namespace are
+// evaluated here, the ones on other fields are assumed to match.
+func selects(spec *bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec, u *unstructured.Unstructured) bool {
+	if spec.Selector != nil {
+		s, err := metav1.LabelSelectorAsSelector(spec.Selector)
+		if err != nil || !s.Matches(labels.Set(u.GetLabels())) {
+			return false
+		}
+	}
+
+	if spec.FieldSelector == "" {
+		return true
+	}
+	s, err := fields.ParseSelector(spec.FieldSelector)
+	if err != nil {
+		return false
+	}
+
+	set := fields.Set{"metadata.name": u.GetName(), "metadata.namespace": u.GetNamespace()}
+	for _, req := range s.Requirements() {
+		v, ok := set[req.Field]
+		if !ok {
+			continue
+		}
+		if (req.Operator == selection.NotEquals) == (v == req.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// replayOthers hands the instances of the other maps watching the same
+// service as sm to their handlers again, so that they claim the instances
+// again when sm is registered, changes or is deleted
+func (r *ServiceResourceMapReconciler) replayOthers(ctx context.Context, sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) {
+	l := log.FromContext(ctx)
+
+	var sms bindingoperatorscoreoscomv1alpha1.ServiceResourceMapList
+	if err := r.List(ctx, &sms); err != nil {
+		l.Error(err, "can not list the ServiceResourceMaps to replay")
+		return
+	}
+	var ms bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapList
+	if err := r.List(ctx, &ms); err != nil {
+		l.Error(err, "can not list the NamespacedServiceResourceMaps to replay")
+		return
+	}
+
+	replay := func(o *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) {
+		if mapKey(o) != mapKey(sm) && sameService(o.Spec.ServiceKindReference, sm.Spec.ServiceKindReference) {
+			r.informers.Replay(mapKey(o))
+		}
+	}
+	for i := range sms.Items {
+		replay(&sms.Items[i])
+	}
+	for i := range ms.Items {
+		replay(mapOf(&ms.Items[i]))
+	}
+}
+
+// claimed records which map claims an instance selected by sm, requeuing sm
+// to update its InstanceClaimed condition when its contested instances change
+func (r *ServiceResourceMapReconciler) claimed(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	u *unstructured.Unstructured,
+	winner string) {
+	if !r.contested.Set(mapKey(sm), u.GetNamespace()+"/"+u.GetName(), winner) {
+		return
+	}
+
+	select {
+	case r.events <- event.GenericEvent{Object: mapObject(sm)}:
+	case <-ctx.Done():
+	}
+}
+
+// claimedCondition returns the InstanceClaimed condition of the map
+func (r *ServiceResourceMapReconciler) claimedCondition(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) metav1.Condition {
+	c := metav1.Condition{
+		Type:               bindingoperatorscoreoscomv1alpha1.ServiceResourceMapConditionInstanceClaimed,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonNoConflict,
+		Message:            "no instance is claimed by another map",
+		ObservedGeneration: sm.Generation,
+	}
+
+	if contested := r.contested.Of(mapKey(sm)); len(contested) > 0 {
+		c.Status = metav1.ConditionTrue
+		c.Reason = ReasonInstancesClaimed
+		c.Message = claims.Message(contested, maxClaimedInstances)
+	}
+	return c
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/claims"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/informers"
+)
+
+func TestClaimant(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	ref := bindingoperatorscoreoscomv1alpha1.ServiceKindReference{ApiGroup: "postgresql.example.com/v1", Kind: "databases"}
+	srm := func(name string, age time.Duration, spec bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec) *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap {
+		return &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created.Add(-age))},
+			Spec:       spec,
+		}
+	}
+	nsrm := func(name string, spec bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec) *bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap {
+		return &bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: name, CreationTimestamp: metav1.NewTime(created)},
+			Spec:       bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapSpec{ServiceResourceMapSpec: spec},
+		}
+	}
+	instance := func(namespace, name string, labels, annotations map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		u.SetLabels(labels)
+		u.SetAnnotations(annotations)
+		return u
+	}
+
+	cli := newCountingClient()
+	r := &ServiceResourceMapReconciler{
+		Client:     cli,
+		informers:  informers.NewManager(noDynamic{}, 0, "", nil),
+		contested:  claims.NewContested(),
+		collisions: claims.NewContested(),
+	}
+	gvr := schema.GroupVersionResource{Group: "postgresql.example.com", Version: "v1", Resource: "databases"}
+	register := func(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) {
+		r.informers.Register(mapKey(sm), gvr, informers.Options{}, nil)
+	}
+
+	old := srm("old", time.Hour, bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{ServiceKindReference: ref})
+	recent := srm("recent", 0, bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{ServiceKindReference: ref})
+	preferred := srm("preferred", 0, bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+		ServiceKindReference: bindingoperatorscoreoscomv1alpha1.ServiceKindReference{ApiGroup: "postgresql.example.com/v2", Kind: "databases"},
+		Selector:             &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "gold"}},
+		Priority:             10,
+	})
+	unwatched := srm("unwatched", 2*time.Hour, bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{ServiceKindReference: ref})
+	other := srm("other", 2*time.Hour, bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+		ServiceKindReference: bindingoperatorscoreoscomv1alpha1.ServiceKindReference{ApiGroup: "redis.example.com/v1", Kind: "caches"},
+	})
+	for _, sm := range []*bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{old, recent, preferred, unwatched, other} {
+		g.Expect(cli.Create(ctx, sm)).To(Succeed())
+		if sm != unwatched {
+			register(sm)
+		}
+	}
+
+	// the maps selecting an instance claim it by priority, then age
+	g.Expect(r.claimant(ctx, recent, instance("ns", "db", nil, nil))).To(Equal("old"))
+	g.Expect(r.claimant(ctx, old, instance("ns", "db", nil, nil))).To(Equal("old"))
+	g.Expect(r.claimant(ctx, recent, instance("ns", "db", map[string]string{"tier": "gold"}, nil))).To(Equal("preferred"))
+
+	// unless the instance is pinned to one of them
+	pinned := instance("ns", "db", map[string]string{"tier": "gold"}, map[string]string{claims.AnnotationPin: "recent"})
+	g.Expect(r.claimant(ctx, old, pinned)).To(Equal("recent"))
+
+	// the namespaced maps selecting an instance of their namespace claim it
+	for _, m := range []*bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap{
+		nsrm("labelled", bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceKindReference: ref,
+			Selector:             &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+		}),
+		nsrm("named", bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceKindReference: ref,
+			FieldSelector:        "metadata.name!=legacy,status.phase=Ready",
+		}),
+	} {
+		g.Expect(cli.Create(ctx, m)).To(Succeed())
+		register(mapOf(m))
+	}
+	g.Expect(r.claimant(ctx, old, instance("tenant", "db", nil, nil))).To(Equal("tenant/named"))
+	g.Expect(r.claimant(ctx, old, instance("tenant", "legacy", map[string]string{"team": "a"}, nil))).To(Equal("tenant/labelled"))
+	g.Expect(r.claimant(ctx, old, instance("tenant", "legacy", nil, nil))).To(Equal("old"))
+	g.Expect(r.claimant(ctx, old, instance("ns", "db", nil, nil))).To(Equal("old"))
+
+	// the losing maps report the instances claimed by other maps
+	r.events = make(chan event.GenericEvent, 1)
+	r.claimed(ctx, recent, instance("ns", "db", nil, nil), "old")
+	g.Expect(r.events).To(Receive())
+	c := r.claimedCondition(recent)
+	g.Expect(c.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(c.Message).To(Equal("instances claimed by other maps: ns/db by 'old'"))
+
+	r.claimed(ctx, recent, instance("ns", "db", nil, nil), "recent")
+	g.Expect(r.events).To(Receive())
+	g.Expect(r.claimedCondition(recent).Reason).To(Equal(ReasonNoConflict))
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/binding"
+)
+
+// Reasons of the NameCollision condition
+const (
+	ReasonNamesCollide = "NamesCollide"
+	ReasonNoCollision  = "NoCollision"
+)
+
+// maxCollidingInstances is the number of instances whose names collide
+// listed in the NameCollision condition
+const maxCollidingInstances = 10
+
+// nameCollision reports a SED or ServiceProxy of an instance whose name is
+// used by the one of another instance or output, e.g. the default SED of the
+// instance `db-ro` and the SED of the output `ro` of the instance `db`
+type nameCollision struct {
+	kind string
+	name string
+	// instance and output the existing object was generated for
+	instance string
+	output   string
+}
+
+func (c *nameCollision) Error() string {
+	if c.output == "" {
+		return fmt.Sprintf("%s %s is used by instance %s", c.kind, c.name, c.instance)
+	}
+	return fmt.Sprintf("%s %s is used by output %s of instance %s", c.kind, c.name, c.output, c.instance)
+}
+
+// spCollision returns the collision with the stored ServiceProxy sp if it
+// was generated for another instance or output than spec, nil otherwise
+func spCollision(sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy, spec bindingoperatorscoreoscomv1alpha1.ServiceProxySpec) *nameCollision {
+	if sp.Spec.ServiceInstance == spec.ServiceInstance && sp.Spec.Output == spec.Output {
+		return nil
+	}
+	return &nameCollision{
+		kind:     "ServiceProxy",
+		name:     sp.Name,
+		instance: sp.Spec.ServiceInstance.Name,
+		output:   sp.Spec.Output,
+	}
+}
+
+// sedCollision returns the collision with the stored SED current if it was
+// generated for another instance or output than the rendered one, nil
+// otherwise
+func sedCollision(current, desired *corev1.Secret) *nameCollision {
+	instance, ok := current.Labels[binding.LabelInstanceName]
+	if !ok {
+		return nil
+	}
+	output := current.Labels[binding.LabelOutput]
+	if instance == desired.Labels[binding.LabelInstanceName] && output == desired.Labels[binding.LabelOutput] {
+		return nil
+	}
+	return &nameCollision{kind: "Secret", name: current.Name, instance: instance, output: output}
+}
+
+// collisionOf returns the first name collision of the ServiceProxies and
+// SEDs of the instance with the stored ones, nil if none, without writing
+// them: the replica owning a sharded map finds the collisions of the
+// instances handled by the other replicas
+func (r *ServiceResourceMapReconciler) collisionOf(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	u *unstructured.Unstructured) (*nameCollision, error) {
+	for _, o := range binding.Outputs(sm) {
+		// instances whose names are invalid are not published either
+		if binding.ValidateNames(o, u.GetName()) != nil {
+			return nil, nil
+		}
+
+		// the SED is generated for the default ServiceProxy, or the dedicated
+		// one of the output
+		dedicated := o.Name != "" && o.ServiceProxy
+		spo := binding.Output{}
+		if dedicated {
+			spo = o
+		}
+		sp := &bindingoperatorscoreoscomv1alpha1.ServiceProxy{Spec: serviceProxySpec(sm, u, spo)}
+		sp.Namespace, sp.Name = u.GetNamespace(), spo.ServiceProxyName(u.GetName())
+		if o.Name == "" || dedicated {
+			var current bindingoperatorscoreoscomv1alpha1.ServiceProxy
+			if err := r.Get(ctx, client.ObjectKeyFromObject(sp), &current); err == nil {
+				if c := spCollision(&current, sp.Spec); c != nil {
+					return c, nil
+				}
+			} else if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("error getting ServiceProxy %s/%s: %w", sp.Namespace, sp.Name, err)
+			}
+		}
+
+		var s corev1.Secret
+		key := client.ObjectKey{Namespace: u.GetNamespace(), Name: o.SecretName(u.GetName())}
+		if err := r.Get(ctx, key, &s); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("error getting Secret %s/%s: %w", key.Namespace, key.Name, err)
+		}
+		desired := &corev1.Secret{}
+		desired.Labels = binding.SEDLabels(sm, sp, o)
+		if c := sedCollision(&s, desired); c != nil {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// collided records the name collision of an instance selected by sm, nil if
+// none, requeuing sm to update its NameCollision condition when the
+// collisions of its instances change
+func (r *ServiceResourceMapReconciler) collided(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	u *unstructured.Unstructured,
+	c *nameCollision) {
+	var msg string
+	if c != nil {
+		msg = c.Error()
+	}
+	if !r.collisions.Set(mapKey(sm), u.GetNamespace()+"/"+u.GetName(), msg) {
+		return
+	}
+
+	select {
+	case r.events <- event.GenericEvent{Object: mapObject(sm)}:
+	case <-ctx.Done():
+	}
+}
+
+// collisionCondition returns the NameCollision condition of the map
+func (r *ServiceResourceMapReconciler) collisionCondition(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) metav1.Condition {
+	c := metav1.Condition{
+		Type:               bindingoperatorscoreoscomv1alpha1.ServiceResourceMapConditionNameCollision,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonNoCollision,
+		Message:            "no instance has the names of another instance or output",
+		ObservedGeneration: sm.Generation,
+	}
+
+	collisions := r.collisions.Of(mapKey(sm))
+	if len(collisions) == 0 {
+		return c
+	}
+	instances := make([]string, 0, len(collisions))
+	for i := range collisions {
+		instances = append(instances, i)
+	}
+	sort.Strings(instances)
+
+	var b strings.Builder
+	b.WriteString("instances not published as their names are used:")
+	for n, i := range instances {
+		if n == maxCollidingInstances {
+			fmt.Fprintf(&b, " and %d more", len(instances)-maxCollidingInstances)
+			break
+		}
+		if n > 0 {
+			b.WriteString(";")
+		}
+		fmt.Fprintf(&b, " %s: %s", i, collisions[i])
+	}
+
+	c.Status = metav1.ConditionTrue
+	c.Reason = ReasonNamesCollide
+	c.Message = b.String()
+	return c
+}
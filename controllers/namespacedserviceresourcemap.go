@@ -0,0 +1,154 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/binding"
+)
+
+// KindNamespacedServiceResourceMap is the ServiceResourceMapKind of the
+// ServiceProxies of the instances mapped by NamespacedServiceResourceMaps
+const KindNamespacedServiceResourceMap = "NamespacedServiceResourceMap"
+
+// NamespacedServiceResourceMaps are reconciled by the ServiceResourceMapReconciler
+// as ServiceResourceMaps whose namespace is set: the functions below convert
+// them back and forth.
+
+// mapOf returns the ServiceResourceMap equivalent to a NamespacedServiceResourceMap
+func mapOf(m *bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap) *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap {
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{}
+	m.ObjectMeta.DeepCopyInto(&sm.ObjectMeta)
+	m.Spec.ServiceResourceMapSpec.DeepCopyInto(&sm.Spec)
+	m.Status.DeepCopyInto(&sm.Status)
+	return sm
+}
+
+// mapObject returns the object of the map: sm itself, or the
+// NamespacedServiceResourceMap it was converted from
+func mapObject(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) client.Object {
+	if sm.Namespace == "" {
+		return sm
+	}
+
+	m := &bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap{}
+	sm.ObjectMeta.DeepCopyInto(&m.ObjectMeta)
+	sm.Spec.DeepCopyInto(&m.Spec.ServiceResourceMapSpec)
+	sm.Status.DeepCopyInto(&m.Status)
+	return m
+}
+
+// mapKey identifies the map among the registrations of the informers and the
+// shards, as in the logs and metrics: the name of ServiceResourceMaps, the
+// namespace and name of NamespacedServiceResourceMaps
+func mapKey(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) string {
+	return binding.MapKey(sm)
+}
+
+// mapKind returns the ServiceResourceMapKind of the ServiceProxies of the map
+func mapKind(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) string {
+	if sm.Namespace == "" {
+		return ""
+	}
+	return KindNamespacedServiceResourceMap
+}
+
+// spMapKey returns the key of the map referred to by a ServiceProxy
+func spMapKey(sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy) string {
+	if sp.Spec.ServiceResourceMapKind == KindNamespacedServiceResourceMap {
+		return sp.Namespace + "/" + sp.Spec.ServiceResourceMapRef
+	}
+	return sp.Spec.ServiceResourceMapRef
+}
+
+// getServiceResourceMap returns the map referred to by a ServiceProxy
+func getServiceResourceMap(
+	ctx context.Context,
+	c client.Reader,
+	sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy) (*bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, error) {
+	switch sp.Spec.ServiceResourceMapKind {
+	case "":
+		var sm bindingoperatorscoreoscomv1alpha1.ServiceResourceMap
+		if err := c.Get(ctx, client.ObjectKey{Name: sp.Spec.ServiceResourceMapRef}, &sm); err != nil {
+			return nil, err
+		}
+		return &sm, nil
+	case KindNamespacedServiceResourceMap:
+		var m bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap
+		if err := c.Get(ctx, client.ObjectKey{Namespace: sp.Namespace, Name: sp.Spec.ServiceResourceMapRef}, &m); err != nil {
+			return nil, err
+		}
+		return mapOf(&m), nil
+	}
+	return nil, fmt.Errorf("unknown service_resource_map_kind '%s'", sp.Spec.ServiceResourceMapKind)
+}
+
+// referenceClient returns the client reading the objects referenced by the
+// rules of the map: the ones of NamespacedServiceResourceMaps are read on
+// behalf of their ServiceAccount
+func (r *ServiceResourceMapReconciler) referenceClient(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) (client.Client, error) {
+	if sm.Namespace == "" {
+		return r.Client, nil
+	}
+
+	sa, err := r.serviceAccount(ctx, sm)
+	if err != nil {
+		return nil, err
+	}
+	return r.reviewer.Client(r.Client, sm.Namespace, sa), nil
+}
+
+// reviewWatch returns why the map may not watch the instances of the
+// resource, empty if it may: NamespacedServiceResourceMaps watch the ones
+// their ServiceAccount may get, list and watch
+func (r *ServiceResourceMapReconciler) reviewWatch(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	gvr schema.GroupVersionResource) (string, error) {
+	if sm.Namespace == "" {
+		return "", nil
+	}
+
+	sa, err := r.serviceAccount(ctx, sm)
+	if err != nil {
+		return "", err
+	}
+	allowed, err := r.reviewer.CanWatch(ctx, sm.Namespace, sa, gvr.GroupResource())
+	if err != nil || allowed {
+		return "", err
+	}
+	return fmt.Sprintf("ServiceAccount '%s' can not get, list and watch %s", sa, gvr.GroupResource()), nil
+}
+
+// serviceAccount returns the ServiceAccount of a NamespacedServiceResourceMap
+func (r *ServiceResourceMapReconciler) serviceAccount(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) (string, error) {
+	var m bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sm), &m); err != nil {
+		return "", fmt.Errorf("error getting NamespacedServiceResourceMap '%s': %w", mapKey(sm), err)
+	}
+	return m.ServiceAccount(), nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/access"
+)
+
+func TestMapOf(t *testing.T) {
+	g := NewWithT(t)
+
+	m := &bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "db", ResourceVersion: "3"},
+		Spec: bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapSpec{
+			ServiceResourceMapSpec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{ServiceMap: map[string]string{"type": "postgresql"}},
+			ServiceAccountName:     "binder",
+		},
+	}
+	sm := mapOf(m)
+	g.Expect(mapKey(sm)).To(Equal("tenant/db"))
+	g.Expect(mapKind(sm)).To(Equal(KindNamespacedServiceResourceMap))
+	g.Expect(sm.Spec).To(Equal(m.Spec.ServiceResourceMapSpec))
+
+	o := mapObject(sm)
+	g.Expect(o).To(BeAssignableToTypeOf(m))
+	g.Expect(client.ObjectKeyFromObject(o)).To(Equal(client.ObjectKeyFromObject(m)))
+	g.Expect(o.GetResourceVersion()).To(Equal("3"))
+
+	sp := &bindingoperatorscoreoscomv1alpha1.ServiceProxy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant"},
+		Spec:       bindingoperatorscoreoscomv1alpha1.ServiceProxySpec{ServiceResourceMapRef: "db", ServiceResourceMapKind: mapKind(sm)},
+	}
+	g.Expect(spMapKey(sp)).To(Equal(mapKey(sm)))
+
+	// ServiceResourceMaps are identified by their name
+	cluster := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{ObjectMeta: metav1.ObjectMeta{Name: "db"}}
+	g.Expect(mapKey(cluster)).To(Equal("db"))
+	g.Expect(mapObject(cluster)).To(BeIdenticalTo(cluster))
+	sp.Spec.ServiceResourceMapKind = mapKind(cluster)
+	g.Expect(spMapKey(sp)).To(Equal("db"))
+}
+
+// reviewingClient allows the SubjectAccessReviews of the users in allowed
+type reviewingClient struct {
+	*countingClient
+	allowed map[string]bool
+}
+
+func (c *reviewingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if sar, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+		sar.Status.Allowed = c.allowed[sar.Spec.User]
+	}
+	return c.countingClient.Create(ctx, obj, opts...)
+}
+
+func TestReviewWatch(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cli := &reviewingClient{countingClient: newCountingClient(), allowed: map[string]bool{"system:serviceaccount:tenant:binder": true}}
+	r := &ServiceResourceMapReconciler{Client: cli, reviewer: access.NewReviewer(cli, time.Minute)}
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "databases"}
+
+	// ServiceResourceMaps watch every instance
+	msg, err := r.reviewWatch(ctx, &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{ObjectMeta: metav1.ObjectMeta{Name: "db"}}, gvr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(msg).To(BeEmpty())
+
+	// NamespacedServiceResourceMaps the ones their ServiceAccount may watch
+	m := &bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "db"},
+		Spec:       bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapSpec{ServiceAccountName: "binder"},
+	}
+	g.Expect(cli.Create(ctx, m)).To(Succeed())
+	msg, err = r.reviewWatch(ctx, mapOf(m), gvr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(msg).To(BeEmpty())
+
+	m.Spec.ServiceAccountName = ""
+	g.Expect(cli.Update(ctx, m)).To(Succeed())
+	msg, err = r.reviewWatch(ctx, mapOf(m), gvr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(msg).To(Equal("ServiceAccount 'default' can not get, list and watch databases.example.com"))
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/binding"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/projection"
+)
+
+const serviceProxyBindingFinalizer = "servicemapper.binding/projection"
+
+// ServiceProxyBindingReconciler reconciles a ServiceProxyBinding object
+type ServiceProxyBindingReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	Options controller.Options
+}
+
+//+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=serviceproxybindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=serviceproxybindings/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=serviceproxybindings/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=statefulsets;daemonsets,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update;patch
+
+// Reconcile projects the Service Endpoint Definition of the ServiceProxy into
+// the workload, and removes the projection when the ServiceProxyBinding is
+// deleted or its workload changes.
+func (r *ServiceProxyBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	var spb bindingoperatorscoreoscomv1alpha1.ServiceProxyBinding
+	if err := r.Get(ctx, req.NamespacedName, &spb); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !spb.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&spb, serviceProxyBindingFinalizer) {
+			return ctrl.Result{}, nil
+		}
+
+		if w := spb.Status.Workload; w != nil {
+			l.Info("ServiceProxyBinding deleted, removing projection", "workload", w.Name)
+			if err := r.unproject(ctx, &spb); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		controllerutil.RemoveFinalizer(&spb, serviceProxyBindingFinalizer)
+		return ctrl.Result{}, r.Update(ctx, &spb)
+	}
+
+	if !controllerutil.ContainsFinalizer(&spb, serviceProxyBindingFinalizer) {
+		controllerutil.AddFinalizer(&spb, serviceProxyBindingFinalizer)
+		if err := r.Update(ctx, &spb); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var sp bindingoperatorscoreoscomv1alpha1.ServiceProxy
+	spkey := client.ObjectKey{Namespace: spb.Namespace, Name: spb.Spec.ServiceProxy}
+	if err := r.Get(ctx, spkey, &sp); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		// the Service Endpoint Definition is deleted together with the
+		// ServiceProxy, so is the projection
+		if err := r.unproject(ctx, &spb); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.setReady(ctx, &spb, metav1.ConditionFalse, "ServiceProxyNotFound",
+			fmt.Sprintf("ServiceProxy '%s' not found", spb.Spec.ServiceProxy))
+	}
+
+	if sp.Status.Binding.Name == "" {
+		return ctrl.Result{}, r.setReady(ctx, &spb, metav1.ConditionFalse, "ServiceEndpointDefinitionNotReady",
+			fmt.Sprintf("ServiceProxy '%s' has no Service Endpoint Definition yet", sp.Name))
+	}
+
+	// remove the projection from the workload previously bound
+	if w := spb.Status.Workload; w != nil && *w != spb.Spec.Workload {
+		l.Info("workload changed, removing projection", "workload", w.Name)
+		if err := r.unproject(ctx, &spb); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	env, err := r.envFor(ctx, &spb, &sp)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.project(ctx, &spb, sp.Status.Binding.Name, env); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.setReady(ctx, &spb, metav1.ConditionFalse, "WorkloadNotFound", err.Error())
+		}
+		if errors.Is(err, projection.ErrVolumeConflict) {
+			return ctrl.Result{}, r.setReady(ctx, &spb, metav1.ConditionFalse, "VolumeConflict", err.Error())
+		}
+		if errors.Is(err, projection.ErrBindingRoot) {
+			return ctrl.Result{}, r.setReady(ctx, &spb, metav1.ConditionFalse, "InvalidBindingRoot", err.Error())
+		}
+		return ctrl.Result{}, err
+	}
+
+	spb.Status.Binding.Name = sp.Status.Binding.Name
+	w := spb.Spec.Workload
+	return ctrl.Result{}, r.setReady(ctx, &spb, metav1.ConditionTrue, "Projected",
+		fmt.Sprintf("Secret '%s' projected into %s '%s'", sp.Status.Binding.Name, w.Kind, w.Name))
+}
+
+// envFor returns the environment variables to inject, as declared by the
+// ServiceResourceMap output exposed by the ServiceProxy
+func (r *ServiceProxyBindingReconciler) envFor(
+	ctx context.Context,
+	spb *bindingoperatorscoreoscomv1alpha1.ServiceProxyBinding,
+	sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy) (map[string]string, error) {
+	if !spb.Spec.InjectEnv {
+		return nil, nil
+	}
+
+	sm, err := getServiceResourceMap(ctx, r.Client, sp)
+	if err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	o, _ := binding.OutputFor(sm, sp.Spec.Output)
+	return o.Env, nil
+}
+
+// project mounts the Secret in the workload and injects the environment
+// variables, recording the projection in the status
+func (r *ServiceProxyBindingReconciler) project(
+	ctx context.Context,
+	spb *bindingoperatorscoreoscomv1alpha1.ServiceProxyBinding,
+	secret string,
+	env map[string]string) error {
+	var injected []string
+	err := r.updateWorkload(ctx, spb.Namespace, spb.Spec.Workload, func(u *unstructured.Unstructured) error {
+		t, err := projection.PodTemplate(u)
+		if err != nil {
+			return err
+		}
+
+		if err := projection.Project(t, spb.BindingName(), secret); err != nil {
+			return err
+		}
+		injected = projection.ProjectEnv(t, secret, env, spb.Status.InjectedEnv)
+		return projection.SetPodTemplate(u, t)
+	})
+	if err != nil {
+		return err
+	}
+
+	w := spb.Spec.Workload
+	spb.Status.Workload = &w
+	spb.Status.InjectedEnv = injected
+	return nil
+}
+
+// unproject removes the projection recorded in the status from the workload
+func (r *ServiceProxyBindingReconciler) unproject(ctx context.Context, spb *bindingoperatorscoreoscomv1alpha1.ServiceProxyBinding) error {
+	w := spb.Status.Workload
+	if w == nil {
+		return nil
+	}
+
+	err := r.updateWorkload(ctx, spb.Namespace, *w, func(u *unstructured.Unstructured) error {
+		t, err := projection.PodTemplate(u)
+		if err != nil {
+			return err
+		}
+
+		projection.Unproject(t, spb.BindingName())
+		projection.UnprojectEnv(t, spb.Status.InjectedEnv)
+		return projection.SetPodTemplate(u, t)
+	})
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	spb.Status.Workload = nil
+	spb.Status.InjectedEnv = nil
+	spb.Status.Binding.Name = ""
+	return nil
+}
+
+// updateWorkload retrieves the workload, applies the mutation and updates it
+// if the mutation changed it
+func (r *ServiceProxyBindingReconciler) updateWorkload(
+	ctx context.Context,
+	namespace string,
+	w bindingoperatorscoreoscomv1alpha1.WorkloadReference,
+	mutate func(*unstructured.Unstructured) error) error {
+	gv, err := schema.ParseGroupVersion(w.ApiVersion)
+	if err != nil {
+		return err
+	}
+	if !projection.IsSupported(gv.WithKind(w.Kind).GroupKind()) {
+		return fmt.Errorf("unsupported workload kind: %s", gv.WithKind(w.Kind))
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gv.WithKind(w.Kind))
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: w.Name}, u); err != nil {
+		return err
+	}
+
+	o := u.DeepCopy()
+	if err := mutate(u); err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(o, u) {
+		return nil
+	}
+	return r.Update(ctx, u)
+}
+
+func (r *ServiceProxyBindingReconciler) setReady(
+	ctx context.Context,
+	spb *bindingoperatorscoreoscomv1alpha1.ServiceProxyBinding,
+	status metav1.ConditionStatus,
+	reason, message string) error {
+	meta.SetStatusCondition(&spb.Status.Conditions, metav1.Condition{
+		Type:               bindingoperatorscoreoscomv1alpha1.ServiceProxyBindingConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: spb.Generation,
+	})
+	return r.Status().Update(ctx, spb)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServiceProxyBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.
+		GetFieldIndexer().
+		IndexField(context.Background(),
+			&bindingoperatorscoreoscomv1alpha1.ServiceProxyBinding{},
+			".spec.service_proxy",
+			func(o client.Object) []string {
+				spb := o.(*bindingoperatorscoreoscomv1alpha1.ServiceProxyBinding)
+				return []string{spb.Spec.ServiceProxy}
+			})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&bindingoperatorscoreoscomv1alpha1.ServiceProxyBinding{}).
+		WithOptions(r.Options).
+		Watches(
+			&source.Kind{Type: &bindingoperatorscoreoscomv1alpha1.ServiceProxy{}},
+			handler.EnqueueRequestsFromMapFunc(r.serviceProxyBindingsFor)).
+		Complete(r)
+}
+
+// serviceProxyBindingsFor returns the ServiceProxyBindings referencing a ServiceProxy
+func (r *ServiceProxyBindingReconciler) serviceProxyBindingsFor(o client.Object) []reconcile.Request {
+	var spbs bindingoperatorscoreoscomv1alpha1.ServiceProxyBindingList
+	opts := []client.ListOption{
+		client.InNamespace(o.GetNamespace()),
+		client.MatchingFields{".spec.service_proxy": o.GetName()},
+	}
+	if err := r.List(context.Background(), &spbs, opts...); err != nil {
+		return nil
+	}
+
+	rs := make([]reconcile.Request, 0, len(spbs.Items))
+	for _, spb := range spbs.Items {
+		rs = append(rs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&spb)})
+	}
+	return rs
+}
@@ -17,50 +17,133 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/dynamic/dynamicinformer"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
 	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/access"
 	"github.com/openshift-app-service-poc/service-mapper/pkg/binding"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/claims"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/coalesce"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/informers"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/metrics"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/sharding"
 )
 
-// ServiceResourceMapReconciler reconciles a ServiceResourceMap object
-type ServiceResourceMapReconciler struct {
-	client.Client
-	Scheme *runtime.Scheme
+// Reasons of the Events emitted by the ServiceResourceMapReconciler
+const (
+	ReasonSynced              = "Synced"
+	ReasonSyncing             = "Syncing"
+	ReasonWatchFailed         = "WatchFailed"
+	ReasonInformerStarted     = "InformerStarted"
+	ReasonInformerStopped     = "InformerStopped"
+	ReasonGVRUnresolved       = "GVRUnresolved"
+	ReasonInvalidSelector     = "InvalidSelector"
+	ReasonInvalidCase         = "InvalidCase"
+	ReasonInvalidReadiness    = "InvalidReadiness"
+	ReasonInvalidOutput       = "InvalidOutput"
+	ReasonNamespaceNotWatched = "NamespaceNotWatched"
+	ReasonInstancesForbidden  = "InstancesForbidden"
+	ReasonSEDCreated          = "SEDCreated"
+	ReasonSEDUpdated          = "SEDUpdated"
+	ReasonRuleFailed          = "RuleFailed"
+	ReasonProxied             = "Proxied"
+)
 
-	config    *rest.Config
-	informers map[string]informer
-}
+// Reasons of the Ready condition of the ServiceProxies
+const (
+	ReasonPublished        = "Published"
+	ReasonInstanceNotReady = "InstanceNotReady"
+)
 
-type informer struct {
-	informer   cache.SharedIndexInformer
-	ctx        context.Context
-	cancelFunc context.CancelFunc
-}
+// Reasons of the CertificatesValid condition of the ServiceProxies, also
+// used by the Events warning of expiring certificates
+const (
+	ReasonCertificatesValid   = "CertificatesValid"
+	ReasonCertificateExpiring = "CertificateExpiring"
+	ReasonCertificateExpired  = "CertificateExpired"
+)
 
-func (i *informer) run() {
-	i.informer.Run(i.ctx.Done())
+// ServiceResourceMapReconciler reconciles a ServiceResourceMap object
+type ServiceResourceMapReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Options  controller.Options
+
+	// ResyncPeriod is the resync period of the informers watching the service
+	// instances, defaults to one minute
+	ResyncPeriod time.Duration
+	// CoalesceWindow is the time the changes of an instance are collected
+	// before its ServiceProxies and SEDs are updated once
+	CoalesceWindow time.Duration
+	// CertificateExpiryWarning is the time before the expiry of a bound
+	// certificate its ServiceProxy reports it as expiring, zero reports it
+	// once expired only
+	CertificateExpiryWarning time.Duration
+	// Namespace restricts the watched service instances to a single
+	// namespace, all namespaces are watched if empty
+	Namespace string
+	// Sharding, if not nil, runs the controller on every replica, each
+	// handling the instances it owns
+	Sharding *sharding.Options
+
+	// informers watches the instances of the mapped services
+	informers *informers.Manager
+	// coalescer updates the ServiceProxies and SEDs of the changed instances
+	coalescer *coalesce.Coalescer
+	// sharder picks the replica handling an instance, nil if not sharded
+	sharder *sharding.Sharder
+	// reviewer checks the access of the NamespacedServiceResourceMaps to the
+	// objects referenced by their rules
+	reviewer *access.Reviewer
+	// contested records the instances of each map claimed by other maps
+	contested *claims.Contested
+	// ruleFailures records the failed rules of the outputs of the instances
+	// of each map, reported when they change
+	ruleFailures *claims.Contested
+	// collisions records the instances of each map whose SED or ServiceProxy
+	// names are used by other instances, and by which
+	collisions *claims.Contested
+	// events requeues the maps
+	events chan event.GenericEvent
 }
 
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=serviceproxies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=serviceproxies/status,verbs=get;update;patch
@@ -68,6 +151,9 @@ func (i *informer) run() {
 //+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=serviceresourcemaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=serviceresourcemaps/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=serviceresourcemaps/finalizers,verbs=update
+//+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=namespacedserviceresourcemaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=namespacedserviceresourcemaps/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=binding.operators.coreos.com,resources=servicemaptemplates,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -77,6 +163,12 @@ func (i *informer) run() {
 func (r *ServiceResourceMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	l := log.FromContext(ctx)
 
+	// ServiceResourceMaps are cluster scoped, the namespaced requests are
+	// for NamespacedServiceResourceMaps
+	if req.Namespace != "" {
+		return r.reconcileNamespaced(ctx, req)
+	}
+
 	// Get ServiceResourceMap
 	l.Info("get ServiceResourceMap", "srm name", req.Name)
 	var sm bindingoperatorscoreoscomv1alpha1.ServiceResourceMap
@@ -87,152 +179,645 @@ func (r *ServiceResourceMapReconciler) Reconcile(ctx context.Context, req ctrl.R
 
 		// delete serviceproxies and Service Endpoint definitions
 		l.Info("ServiceResourceMap deleted, deleting also ServiceProxy", "srm name", req.Name)
-		return ctrl.Result{}, r.deleteLinkedResources(ctx, req.Name)
+		return ctrl.Result{}, r.deleteLinkedResources(ctx, req.Name, "map deleted")
 	}
 
 	// reconciling resources
 	return ctrl.Result{}, r.reconcileLinkedResources(ctx, &sm)
 }
 
+// reconcileNamespaced reconciles a NamespacedServiceResourceMap as the
+// equivalent ServiceResourceMap restricted to its namespace. It is requeued
+// after the resync period, when the access decisions of its ServiceAccount
+// expire, so that it stops watching the instances once they are revoked.
+func (r *ServiceResourceMapReconciler) reconcileNamespaced(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	l.Info("get NamespacedServiceResourceMap", "nsrm", req.NamespacedName)
+	var m bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap
+	if err := r.Get(ctx, req.NamespacedName, &m); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		l.Info("NamespacedServiceResourceMap deleted, deleting also ServiceProxy", "nsrm", req.NamespacedName)
+		return ctrl.Result{}, r.deleteLinkedResources(ctx, req.NamespacedName.String(), "map deleted")
+	}
+
+	sm := mapOf(&m)
+	if r.Namespace != "" && r.Namespace != m.Namespace {
+		return ctrl.Result{}, r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonNamespaceNotWatched,
+			fmt.Sprintf("only the instances of namespace '%s' are watched", r.Namespace))
+	}
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, r.reconcileLinkedResources(ctx, sm)
+}
+
 func (r *ServiceResourceMapReconciler) reconcileLinkedResources(
 	ctx context.Context,
 	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) error {
 	l := log.FromContext(ctx)
 
-	clusterClient, err := dynamic.NewForConfig(r.config)
+	// the instances are mapped with the rules the map inherits from its
+	// templates
+	extended, err := r.extend(ctx, sm)
+	if apierrors.IsNotFound(err) {
+		// the instances are not handled with the rules of the remaining
+		// templates: their ServiceProxies and SEDs are kept as they are until
+		// the template exists again, unless other maps claim them
+		msg := err.Error()
+		r.Recorder.Event(mapObject(sm), corev1.EventTypeWarning, ReasonTemplateNotFound, msg)
+		if past := r.stopWatching(mapKey(sm), "template not found"); past != nil {
+			r.replayOthers(ctx, past)
+		}
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonTemplateNotFound, msg)
+	} else if err != nil {
+		return err
+	}
+	sm = extended
+
+	gv, err := schema.ParseGroupVersion(sm.Spec.ServiceKindReference.ApiGroup)
 	if err != nil {
+		// a malformed api_group will not resolve until the map is fixed
+		msg := fmt.Sprintf("invalid api_group '%s': %v", sm.Spec.ServiceKindReference.ApiGroup, err)
+		r.Recorder.Event(mapObject(sm), corev1.EventTypeWarning, ReasonGVRUnresolved, msg)
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonGVRUnresolved, msg)
+	}
+	gvr := gv.WithResource(sm.Spec.ServiceKindReference.Kind)
+
+	if _, err := r.RESTMapper().KindFor(gvr); err != nil {
+		l.Error(err, "error resolving resource", "GroupVersionResource", gvr)
+		msg := fmt.Sprintf("resource %s is not served by the cluster", gvr)
+		r.Recorder.Event(mapObject(sm), corev1.EventTypeWarning, ReasonGVRUnresolved, msg)
+		if err := r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonGVRUnresolved, msg); err != nil {
+			return err
+		}
 		return err
 	}
 
-	gvr := schema.GroupVersionResource{
-		Group:    strings.Split(sm.Spec.ServiceKindReference.ApiGroup, "/")[0],
-		Version:  strings.Split(sm.Spec.ServiceKindReference.ApiGroup, "/")[1],
-		Resource: sm.Spec.ServiceKindReference.Kind,
+	opts, err := informerOptions(sm)
+	if err != nil {
+		msg := err.Error()
+		r.Recorder.Event(mapObject(sm), corev1.EventTypeWarning, ReasonInvalidSelector, msg)
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonInvalidSelector, msg)
+	}
+
+	if err := binding.ValidateCases(sm); err != nil {
+		msg := err.Error()
+		r.Recorder.Event(mapObject(sm), corev1.EventTypeWarning, ReasonInvalidCase, msg)
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonInvalidCase, msg)
+	}
+
+	if err := binding.ValidateReadiness(sm); err != nil {
+		msg := err.Error()
+		r.Recorder.Event(mapObject(sm), corev1.EventTypeWarning, ReasonInvalidReadiness, msg)
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonInvalidReadiness, msg)
+	}
+
+	if err := binding.ValidateOutputs(sm); err != nil {
+		msg := err.Error()
+		r.Recorder.Event(mapObject(sm), corev1.EventTypeWarning, ReasonInvalidOutput, msg)
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonInvalidOutput, msg)
 	}
 
-	crds, err := clusterClient.
-		Resource(gvr).
-		Namespace(corev1.NamespaceAll).
-		List(ctx, metav1.ListOptions{})
+	// NamespacedServiceResourceMaps only map the instances their
+	// ServiceAccount may read, and stop once it no longer can
+	key := mapKey(sm)
+	msg, err := r.reviewWatch(ctx, sm, gvr)
 	if err != nil {
-		l.Error(err, "error listing resource", "GroupVersionResource", gvr)
 		return err
 	}
-
-	for _, c := range crds.Items {
-		if err := r.createOrUpdateServiceProxyAndSED(ctx, sm, &c); err != nil {
+	if msg != "" {
+		r.Recorder.Event(mapObject(sm), corev1.EventTypeWarning, ReasonInstancesForbidden, msg)
+		if err := r.deleteLinkedResources(ctx, key, msg); err != nil {
 			return err
 		}
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonInstancesForbidden, msg)
 	}
 
-	// running informer for monitored resources if not running
-	if err := r.runInformer(ctx, clusterClient, gvr, sm); err != nil {
-		return err
+	// watch the instances: the handler is replaced to use the latest version
+	// of the ServiceResourceMap, and the instances are handled again when its
+	// spec or the rules of its templates changed, as are the ones of the
+	// other maps of the service, which may claim them
+	past, _ := r.informers.Handler(key).(*instanceHandler)
+	if r.informers.Register(key, gvr, opts, &instanceHandler{r: r, sm: sm}) {
+		r.Recorder.Eventf(mapObject(sm), corev1.EventTypeNormal, ReasonInformerStarted, "watching %s", gvr)
+		r.replayOthers(ctx, sm)
+	} else if sm.Status.ObservedGeneration != sm.Generation ||
+		(past != nil && !equality.Semantic.DeepEqual(past.sm.Spec.ServiceMap, sm.Spec.ServiceMap)) {
+		r.informers.Replay(key)
+		r.replayOthers(ctx, sm)
 	}
 
-	return nil
+	// the informer requeues the ServiceResourceMap when its state changes
+	s, _ := r.informers.State(key)
+	switch {
+	case s.Err != nil:
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonWatchFailed, s.Err.Error())
+	case !s.Synced:
+		return r.setSynced(ctx, sm, metav1.ConditionFalse, ReasonSyncing, fmt.Sprintf("waiting for the informer watching %s to sync", gvr))
+	}
+	return r.setSynced(ctx, sm, metav1.ConditionTrue, ReasonSynced, fmt.Sprintf("watching %s", gvr))
+}
+
+// informerOptions returns the options of the informer watching the instances
+// of the ServiceResourceMap: its namespace, its selectors and the fields read
+// by its rules
+func informerOptions(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) (informers.Options, error) {
+	opts := informers.Options{Namespace: sm.Namespace, Fields: binding.Fields(sm)}
+
+	if sm.Spec.Selector != nil {
+		s, err := metav1.LabelSelectorAsSelector(sm.Spec.Selector)
+		if err != nil {
+			return informers.Options{}, fmt.Errorf("invalid selector: %w", err)
+		}
+		opts.LabelSelector = s.String()
+	}
+
+	if sm.Spec.FieldSelector != "" {
+		s, err := fields.ParseSelector(sm.Spec.FieldSelector)
+		if err != nil {
+			return informers.Options{}, fmt.Errorf("invalid field_selector '%s': %w", sm.Spec.FieldSelector, err)
+		}
+		opts.FieldSelector = s.String()
+	}
+	return opts, nil
 }
 
-func (r *ServiceResourceMapReconciler) runInformer(
+// setSynced records the Synced, InstanceClaimed and NameCollision conditions
+// and the observed generation
+func (r *ServiceResourceMapReconciler) setSynced(
 	ctx context.Context,
-	clusterClient dynamic.Interface,
-	gvr schema.GroupVersionResource,
-	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) error {
-	l, _ := logr.FromContext(ctx)
-	if _, ok := r.informers[sm.Name]; ok {
-		// informer already running for this GVR
-		l.Info("informer yet running", "GroupVersionResource", gvr)
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	status metav1.ConditionStatus,
+	reason, message string) error {
+	// the status is written by the replica owning the ServiceResourceMap
+	if !r.owns(mapKey(sm)) {
+		return nil
+	}
+	past := sm.Status.DeepCopy()
+
+	sm.Status.ObservedGeneration = sm.Generation
+	meta.SetStatusCondition(&sm.Status.Conditions, metav1.Condition{
+		Type:               bindingoperatorscoreoscomv1alpha1.ServiceResourceMapConditionSynced,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: sm.Generation,
+	})
+	meta.SetStatusCondition(&sm.Status.Conditions, r.claimedCondition(sm))
+	meta.SetStatusCondition(&sm.Status.Conditions, r.collisionCondition(sm))
+	if equality.Semantic.DeepEqual(past, &sm.Status) {
 		return nil
 	}
 
-	// run dynamic informer
-	factory := dynamicinformer.NewDynamicSharedInformerFactory(clusterClient, time.Minute)
-	i := factory.ForResource(gvr).Informer()
-	i.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			u := obj.(*unstructured.Unstructured)
-			l.Info("new monitored instance found: creating SP and SED", "srm", sm.Namespace+"/"+sm.Name, "target", u.GetNamespace()+"/"+u.GetName())
-			r.createOrUpdateServiceProxyAndSED(ctx, sm, obj)
-		},
-		UpdateFunc: func(past, future interface{}) {
-			u := future.(*unstructured.Unstructured)
-			l.Info("monitored instance updated: updating SP and SED", "srm", sm.Namespace+"/"+sm.Name, "target", u.GetNamespace()+"/"+u.GetName())
-			r.createOrUpdateServiceProxyAndSED(ctx, sm, future)
-		},
-		DeleteFunc: func(obj interface{}) {
-			u := obj.(*unstructured.Unstructured)
-			l.Info("monitored instance deleted: deleting SP and SED", "srm", sm.Namespace+"/"+sm.Name, "target", u.GetNamespace()+"/"+u.GetName())
-			r.deleteServiceProxyAndSED(ctx, obj)
-		},
+	o := mapObject(sm)
+	if err := r.Status().Update(ctx, o); err != nil {
+		return fmt.Errorf("error updating the status of ServiceResourceMap '%s': %w", mapKey(sm), err)
+	}
+	sm.ResourceVersion = o.GetResourceVersion()
+	return nil
+}
+
+// instanceHandler creates, updates and deletes the ServiceProxies and SEDs of
+// the instances mapped by a ServiceResourceMap
+type instanceHandler struct {
+	r  *ServiceResourceMapReconciler
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap
+
+	mu sync.Mutex
+	// received records, by instance, when the first change not handled yet
+	// was received
+	received map[string]time.Time
+}
+
+func (h *instanceHandler) OnAdd(ctx context.Context, u *unstructured.Unstructured) {
+	received := time.Now()
+	if !h.r.ownsInstance(u) {
+		h.track(ctx, u)
+		return
+	}
+	h.schedule(ctx, u, received, "new monitored instance found: creating SP and SED")
+}
+
+func (h *instanceHandler) OnUpdate(ctx context.Context, past, future *unstructured.Unstructured) {
+	received := time.Now()
+	owned := h.r.ownsInstance(future)
+	if !owned && !h.r.owns(mapKey(h.sm)) {
+		return
+	}
+
+	// resyncs are handled to refresh the values of the referenced objects,
+	// other changes only if they change the values read by the rules or the
+	// map the instance is pinned to
+	if past.GetResourceVersion() != future.GetResourceVersion() &&
+		past.GetAnnotations()[claims.AnnotationPin] == future.GetAnnotations()[claims.AnnotationPin] &&
+		equality.Semantic.DeepEqual(binding.Extract(h.sm, past.Object), binding.Extract(h.sm, future.Object)) {
+		metrics.SkippedInstanceUpdates.WithLabelValues(mapKey(h.sm), metrics.SkipUnchanged).Inc()
+		return
+	}
+	if !owned {
+		h.track(ctx, future)
+		return
+	}
+	h.schedule(ctx, future, received, "monitored instance updated: updating SP and SED")
+}
+
+func (h *instanceHandler) OnDelete(ctx context.Context, u *unstructured.Unstructured) {
+	if !h.r.ownsInstance(u) {
+		if h.r.owns(mapKey(h.sm)) {
+			h.r.coalescer.Now(h.key(u), func(cctx context.Context) {
+				h.r.claimed(cctx, h.sm, u, "")
+				h.r.collided(cctx, h.sm, u, nil)
+			})
+		}
+		return
+	}
+	l := log.FromContext(ctx)
+
+	// the deletion replaces the pending update of the instance
+	h.firstReceived(h.key(u), time.Time{})
+	h.r.coalescer.Now(h.key(u), func(cctx context.Context) {
+		h.r.claimed(cctx, h.sm, u, "")
+		h.r.collided(cctx, h.sm, u, nil)
+		for _, o := range binding.Outputs(h.sm) {
+			h.r.ruleFailures.Set(mapKey(h.sm), outputKey(u, o), "")
+		}
+		l.Info("monitored instance deleted: deleting SP and SED", "srm", mapKey(h.sm), "target", u.GetNamespace()+"/"+u.GetName())
+		h.r.deleteServiceProxyAndSED(log.IntoContext(cctx, l), u)
 	})
+}
 
-	l.Info("run informer", "GroupVersionResource", gvr)
-	c, fc := context.WithCancel(ctx)
+// schedule updates the ServiceProxy and SEDs of the instance once the
+// coalesce window has elapsed, with the latest state of the instance. The
+// latency of the update is observed from the first change it handles.
+func (h *instanceHandler) schedule(ctx context.Context, u *unstructured.Unstructured, received time.Time, msg string) {
+	l := log.FromContext(ctx)
+
+	h.firstReceived(h.key(u), received)
+	scheduled := h.r.coalescer.Schedule(h.key(u), func(cctx context.Context) {
+		received := h.firstReceived(h.key(u), time.Time{})
+		// the ServiceResourceMap may have been deleted meanwhile, and other
+		// maps selecting the instance may claim it
+		key := mapKey(h.sm)
+		if _, ok := h.r.informers.State(key); !ok {
+			return
+		}
+		winner := h.r.claimant(cctx, h.sm, u)
+		h.r.claimed(cctx, h.sm, u, winner)
+		if winner != key {
+			l.V(1).Info("instance claimed by another map, skipping", "srm", key, "claimant", winner,
+				"target", u.GetNamespace()+"/"+u.GetName())
+			return
+		}
+		l.Info(msg, "srm", mapKey(h.sm), "target", u.GetNamespace()+"/"+u.GetName())
+		h.r.observeCreateOrUpdateServiceProxyAndSED(log.IntoContext(cctx, l), h.sm, u, received)
+	})
+	if !scheduled {
+		metrics.SkippedInstanceUpdates.WithLabelValues(mapKey(h.sm), metrics.SkipCoalesced).Inc()
+	}
+}
+
+// track records, once the coalesce window has elapsed, which map claims an
+// instance handled by another replica and the collisions of its names, if
+// the replica owns the map: its InstanceClaimed and NameCollision conditions
+// then report every instance, whichever replica handles it
+func (h *instanceHandler) track(ctx context.Context, u *unstructured.Unstructured) {
+	l := log.FromContext(ctx)
+	key := mapKey(h.sm)
+	if !h.r.owns(key) {
+		return
+	}
+
+	scheduled := h.r.coalescer.Schedule(h.key(u), func(cctx context.Context) {
+		if _, ok := h.r.informers.State(key); !ok {
+			return
+		}
+		winner := h.r.claimant(cctx, h.sm, u)
+		h.r.claimed(cctx, h.sm, u, winner)
+		if winner != key {
+			return
+		}
+		c, err := h.r.collisionOf(cctx, h.sm, u)
+		if err != nil {
+			l.Error(err, "can not find the name collisions of the instance", "srm", key, "target", u.GetNamespace()+"/"+u.GetName())
+			return
+		}
+		h.r.collided(cctx, h.sm, u, c)
+	})
+	if !scheduled {
+		metrics.SkippedInstanceUpdates.WithLabelValues(mapKey(h.sm), metrics.SkipCoalesced).Inc()
+	}
+}
+
+// firstReceived returns when the first change of the instance not handled
+// yet was received, recording t if none is. A zero t forgets the changes of
+// the instance, as they are being handled.
+func (h *instanceHandler) firstReceived(key string, t time.Time) time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	first, ok := h.received[key]
+	switch {
+	case t.IsZero():
+		delete(h.received, key)
+	case !ok:
+		if h.received == nil {
+			h.received = map[string]time.Time{}
+		}
+		h.received[key] = t
+		first = t
+	}
+	return first
+}
 
-	li := informer{informer: i, ctx: c, cancelFunc: fc}
-	r.informers[sm.GetName()] = li
-	go li.run()
+// key identifies the instance for the coalescer
+func (h *instanceHandler) key(u *unstructured.Unstructured) string {
+	return mapKey(h.sm) + "/" + u.GetNamespace() + "/" + u.GetName()
+}
+
+// setReady sets the Ready condition of a ServiceProxy
+func setReady(status *bindingoperatorscoreoscomv1alpha1.ServiceProxyStatus, s metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    bindingoperatorscoreoscomv1alpha1.ServiceProxyConditionReady,
+		Status:  s,
+		Reason:  reason,
+		Message: message,
+	})
+}
 
+// setNotReady sets the Ready condition of a ServiceProxy to False, with the
+// reason InstanceNotReady, if not already
+func (r *ServiceResourceMapReconciler) setNotReady(ctx context.Context, sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy, msg string) error {
+	status := sp.Status.DeepCopy()
+	setReady(status, metav1.ConditionFalse, ReasonInstanceNotReady, msg)
+	if equality.Semantic.DeepEqual(&sp.Status, status) {
+		return nil
+	}
+	sp.Status = *status
+	if err := r.Status().Update(ctx, sp); err != nil {
+		return fmt.Errorf("error updating the status of ServiceProxy %s/%s: %w", sp.Namespace, sp.Name, err)
+	}
 	return nil
 }
 
+// setCertificatesValid sets the CertificatesValid condition of a ServiceProxy
+// from the earliest expiry of the certificates of its SEDs, or removes it if
+// they hold none. A warning Event is emitted when a certificate starts
+// expiring or expires.
+func (r *ServiceResourceMapReconciler) setCertificatesValid(
+	sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy,
+	status *bindingoperatorscoreoscomv1alpha1.ServiceProxyStatus,
+	notAfter, now time.Time) {
+	if notAfter.IsZero() {
+		meta.RemoveStatusCondition(&status.Conditions, bindingoperatorscoreoscomv1alpha1.ServiceProxyConditionCertificatesValid)
+		return
+	}
+
+	at := notAfter.UTC().Format(time.RFC3339)
+	c := metav1.Condition{
+		Type:    bindingoperatorscoreoscomv1alpha1.ServiceProxyConditionCertificatesValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  ReasonCertificatesValid,
+		Message: fmt.Sprintf("the first certificate expires at %s", at),
+	}
+	switch {
+	case !now.Before(notAfter):
+		c.Status, c.Reason, c.Message = metav1.ConditionFalse, ReasonCertificateExpired, fmt.Sprintf("a certificate expired at %s", at)
+	case !now.Add(r.CertificateExpiryWarning).Before(notAfter):
+		c.Status, c.Reason, c.Message = metav1.ConditionFalse, ReasonCertificateExpiring, fmt.Sprintf("a certificate expires at %s", at)
+	}
+
+	past := meta.FindStatusCondition(status.Conditions, c.Type)
+	if c.Status == metav1.ConditionFalse && (past == nil || past.Reason != c.Reason) {
+		r.Recorder.Event(sp, corev1.EventTypeWarning, c.Reason, c.Message)
+	}
+	meta.SetStatusCondition(&status.Conditions, c)
+}
+
+// observeCreateOrUpdateServiceProxyAndSED handles an instance change received
+// by an informer at the given time, recording the name collisions of the
+// instance
+func (r *ServiceResourceMapReconciler) observeCreateOrUpdateServiceProxyAndSED(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	obj interface{},
+	received time.Time) {
+	l, _ := logr.FromContext(ctx)
+
+	err := r.createOrUpdateServiceProxyAndSED(ctx, sm, obj, received)
+	var c *nameCollision
+	if err == nil || errors.As(err, &c) {
+		r.collided(ctx, sm, obj.(*unstructured.Unstructured), c)
+	}
+	if err != nil {
+		l.Error(err, "error creating or updating SP and SED", "srm", mapKey(sm))
+	}
+}
+
+// createOrUpdateServiceProxyAndSED updates the ServiceProxies and SEDs of the
+// instance. Once the SEDs are written, the latency from the reception of the
+// change is observed, unless received is zero.
 func (r *ServiceResourceMapReconciler) createOrUpdateServiceProxyAndSED(
 	ctx context.Context,
 	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
-	obj interface{}) error {
-	sp, err := r.createOrUpdateServiceProxy(ctx, sm, obj)
+	obj interface{},
+	received time.Time) error {
+	u := obj.(*unstructured.Unstructured)
+
+	for _, o := range binding.Outputs(sm) {
+		if err := binding.ValidateNames(o, u.GetName()); err != nil {
+			return fmt.Errorf("can not name the resources of instance %s/%s: %w", u.GetNamespace(), u.GetName(), err)
+		}
+	}
+
+	sp, err := r.createOrUpdateServiceProxy(ctx, sm, obj, binding.Output{})
 	if err != nil {
 		return err
 	}
 
-	sec, err := r.createOrUpdateSED(ctx, sp, sm, obj)
-	if err != nil {
+	// the SEDs are only published once the instance is ready, the ones
+	// already published are kept, and the ServiceProxies of the outputs are
+	// not ready either
+	if ready, msg := binding.Ready(sm, u.UnstructuredContent()); !ready {
+		for _, o := range binding.Outputs(sm) {
+			if o.Name == "" || !o.ServiceProxy {
+				continue
+			}
+			osp, err := r.createOrUpdateServiceProxy(ctx, sm, obj, o)
+			if err != nil {
+				return err
+			}
+			if err := r.setNotReady(ctx, osp, msg); err != nil {
+				return err
+			}
+		}
+		return r.setNotReady(ctx, sp, msg)
+	}
+
+	status := bindingoperatorscoreoscomv1alpha1.ServiceProxyStatus{
+		Conditions: append([]metav1.Condition(nil), sp.Status.Conditions...),
+	}
+	setReady(&status, metav1.ConditionTrue, ReasonPublished, "")
+	// earliest expiry of the certificates of the SEDs bound by sp
+	var notAfter time.Time
+	now := time.Now()
+	for _, o := range binding.Outputs(sm) {
+		osp := sp
+		if o.Name != "" && o.ServiceProxy {
+			if osp, err = r.createOrUpdateServiceProxy(ctx, sm, obj, o); err != nil {
+				return err
+			}
+		}
+
+		sec, err := r.createOrUpdateSED(ctx, osp, sm, o, obj)
+		if err != nil {
+			return err
+		}
+		t, ok := binding.NotAfter(sec.Data)
+		if ok && osp == sp && (notAfter.IsZero() || t.Before(notAfter)) {
+			notAfter = t
+		}
+
+		if o.Name == "" {
+			status.Binding.Name = sec.Name
+			continue
+		}
+
+		so := bindingoperatorscoreoscomv1alpha1.ServiceProxyStatusOutput{Name: o.Name, SEDName: sec.Name}
+		if osp != sp {
+			so.ServiceProxy = osp.Name
+		}
+		// the dedicated ServiceProxies track the certificates of their own SED
+		if osp != sp {
+			ostatus := osp.Status.DeepCopy()
+			ostatus.Binding.Name = sec.Name
+			setReady(ostatus, metav1.ConditionTrue, ReasonPublished, "")
+			r.setCertificatesValid(osp, ostatus, t, now)
+			if !equality.Semantic.DeepEqual(&osp.Status, ostatus) {
+				osp.Status = *ostatus
+				if err := r.Status().Update(ctx, osp); err != nil {
+					return fmt.Errorf("error updating serviceproxy.status.binding.name to '%s': %w", sec.Name, err)
+				}
+			}
+		}
+		status.Outputs = append(status.Outputs, so)
+	}
+	if !received.IsZero() {
+		metrics.InstanceToSEDLatency.WithLabelValues(mapKey(sm)).Observe(time.Since(received).Seconds())
+	}
+	r.setCertificatesValid(sp, &status, notAfter, now)
+
+	// delete the resources generated for outputs that are no longer declared
+	if err := r.deleteStaleOutputs(ctx, u.GetNamespace(), sp.Status, status); err != nil {
 		return err
 	}
 
-	sp.Status.Binding.Name = sec.Name
+	if equality.Semantic.DeepEqual(sp.Status, status) {
+		return nil
+	}
+	sp.Status = status
 	if err := r.Status().Update(ctx, sp); err != nil {
-		return fmt.Errorf("error updating serviceproxy.status.binding.name to '%s': %w", sec.Name, err)
+		return fmt.Errorf("error updating serviceproxy.status.binding.name to '%s': %w", status.Binding.Name, err)
 	}
 
 	return nil
 }
 
-func (r *ServiceResourceMapReconciler) deleteServiceProxyAndSED(ctx context.Context, obj interface{}) {
-	u := obj.(*unstructured.Unstructured)
+func (r *ServiceResourceMapReconciler) deleteStaleOutputs(ctx context.Context, namespace string, past, future bindingoperatorscoreoscomv1alpha1.ServiceProxyStatus) error {
+	seds, sps := recordedNames(future)
+	pseds, psps := recordedNames(past)
+
+	for n := range pseds {
+		if _, ok := seds[n]; !ok {
+			if err := r.deleteSecretIfExists(ctx, namespace, n); err != nil {
+				return err
+			}
+		}
+	}
 
-	r.deleteServiceProxy(ctx, obj)
-	r.deleteSecretIfExists(ctx, u.GetNamespace(), u.GetName()+"-sed")
+	for n := range psps {
+		if _, ok := sps[n]; !ok {
+			if err := r.deleteServiceProxyIfExists(ctx, namespace, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func (r *ServiceResourceMapReconciler) createOrUpdateServiceProxy(
-	ctx context.Context,
-	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
-	obj interface{}) (*bindingoperatorscoreoscomv1alpha1.ServiceProxy, error) {
+// recordedNames returns the names of the Service Endpoint Definitions and of
+// the dedicated ServiceProxies recorded in a ServiceProxy status
+func recordedNames(status bindingoperatorscoreoscomv1alpha1.ServiceProxyStatus) (map[string]struct{}, map[string]struct{}) {
+	seds, sps := map[string]struct{}{}, map[string]struct{}{}
+	if status.Binding.Name != "" {
+		seds[status.Binding.Name] = struct{}{}
+	}
+
+	for _, o := range status.Outputs {
+		seds[o.SEDName] = struct{}{}
+		if o.ServiceProxy != "" {
+			sps[o.ServiceProxy] = struct{}{}
+		}
+	}
+	return seds, sps
+}
+
+func (r *ServiceResourceMapReconciler) deleteServiceProxyAndSED(ctx context.Context, obj interface{}) {
 	l, _ := logr.FromContext(ctx)
 	u := obj.(*unstructured.Unstructured)
 
+	okey := client.ObjectKey{Namespace: u.GetNamespace(), Name: u.GetName()}
 	var sp bindingoperatorscoreoscomv1alpha1.ServiceProxy
-	spSpec := bindingoperatorscoreoscomv1alpha1.ServiceProxySpec{
-		ServiceResourceMapRef: sm.GetName(),
+	if err := r.Get(ctx, okey, &sp); err != nil {
+		l.Error(err, "error getting proxable service for target service", "target service key", okey)
+		return
+	}
+
+	if err := r.deleteServiceProxy(ctx, &sp); err != nil {
+		l.Error(err, "error deleting proxable service", "target service key", okey)
+	}
+}
+
+// serviceProxySpec returns the spec of the ServiceProxy of the output o of
+// the instance u, its default ServiceProxy if o is the default output
+func serviceProxySpec(
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	u *unstructured.Unstructured,
+	o binding.Output) bindingoperatorscoreoscomv1alpha1.ServiceProxySpec {
+	return bindingoperatorscoreoscomv1alpha1.ServiceProxySpec{
+		ServiceResourceMapRef:  sm.GetName(),
+		ServiceResourceMapKind: mapKind(sm),
 		ServiceInstance: bindingoperatorscoreoscomv1alpha1.NamespacedName{
 			Name:      u.GetName(),
 			Namespace: u.GetNamespace(),
 		},
+		Output: o.Name,
 	}
+}
+
+func (r *ServiceResourceMapReconciler) createOrUpdateServiceProxy(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	obj interface{},
+	o binding.Output) (*bindingoperatorscoreoscomv1alpha1.ServiceProxy, error) {
+	l, _ := logr.FromContext(ctx)
+	u := obj.(*unstructured.Unstructured)
+
+	var sp bindingoperatorscoreoscomv1alpha1.ServiceProxy
+	spSpec := serviceProxySpec(sm, u, o)
 
 	// check if ServiceProxy already exists
-	spkey := client.ObjectKey{Namespace: u.GetNamespace(), Name: u.GetName()}
+	spkey := client.ObjectKey{Namespace: u.GetNamespace(), Name: o.ServiceProxyName(u.GetName())}
 	if err := r.Get(ctx, spkey, &sp); err != nil {
 		if !apierrors.IsNotFound(err) {
-			return nil, fmt.Errorf("error getting ServiceProxy %s/%s: %w", sp.Namespace, sp.Name, err)
+			return nil, fmt.Errorf("error getting ServiceProxy %s/%s: %w", spkey.Namespace, spkey.Name, err)
 		}
 
 		// create ServiceProxy
 		sp = bindingoperatorscoreoscomv1alpha1.ServiceProxy{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      u.GetName(),
-				Namespace: u.GetNamespace(),
+				Name:      spkey.Name,
+				Namespace: spkey.Namespace,
 			},
 			Spec: spSpec,
 		}
@@ -240,10 +825,22 @@ func (r *ServiceResourceMapReconciler) createOrUpdateServiceProxy(
 			return nil, fmt.Errorf("error creating ServiceProxy %s/%s: %w", sp.Namespace, sp.Name, err)
 		}
 
+		r.Recorder.Eventf(u, corev1.EventTypeNormal, ReasonProxied,
+			"proxied by ServiceProxy %s of ServiceResourceMap %s", sp.Name, sm.Name)
+
 		return &sp, nil
 	}
 
+	// the ServiceProxy of another instance or output with the same name is
+	// left untouched
+	if c := spCollision(&sp, spSpec); c != nil {
+		return nil, c
+	}
+
 	// update ServiceProxy
+	if equality.Semantic.DeepEqual(sp.Spec, spSpec) {
+		return &sp, nil
+	}
 	sp.Spec = spSpec
 	if err := r.Update(ctx, &sp); err != nil {
 		l.Error(err, "error updating ServiceProxy")
@@ -255,12 +852,26 @@ func (r *ServiceResourceMapReconciler) createOrUpdateSED(
 	ctx context.Context,
 	sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy,
 	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
-	o interface{}) (*corev1.Secret, error) {
+	o binding.Output,
+	i interface{}) (*corev1.Secret, error) {
 
-	obj := o.(*unstructured.Unstructured)
+	obj := i.(*unstructured.Unstructured)
+
+	cli, err := r.referenceClient(ctx, sm)
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate Service Endpoint Definition
-	sed := binding.NewServiceEndpointDefinition(ctx, r.Client, sm, sp, obj.UnstructuredContent())
+	sed, failures := binding.NewServiceEndpointDefinition(ctx, cli, sm, sp, o, obj.UnstructuredContent())
+	// the failures are reported when they change, without the names and
+	// values their errors may hold
+	if r.ruleFailures.Set(mapKey(sm), outputKey(obj, o), failedRules(failures)) {
+		for _, f := range failures {
+			r.Recorder.Eventf(sp, corev1.EventTypeWarning, ReasonRuleFailed,
+				"rule '%s' of ServiceResourceMap %s failed: %s", f.Key, sm.Name, f.Reason())
+		}
+	}
 
 	okey := client.ObjectKey{Namespace: sed.ObjectMeta.Namespace, Name: sed.ObjectMeta.Name}
 	var s corev1.Secret
@@ -274,18 +885,91 @@ func (r *ServiceResourceMapReconciler) createOrUpdateSED(
 			return nil, err2
 		}
 
+		r.Recorder.Eventf(sp, corev1.EventTypeNormal, ReasonSEDCreated, "created Secret %s", sed.Name)
+		return sed, nil
+	}
+
+	// the SED of another instance or output with the same name is left
+	// untouched
+	if c := sedCollision(&s, sed); c != nil {
+		return nil, c
+	}
+
+	// the type of a Secret is immutable, so it must be recreated when the
+	// binding type changes
+	if s.Type != sed.Type {
+		if err := r.Delete(ctx, &s); err != nil {
+			return nil, err
+		}
+		if err := r.Create(ctx, sed); err != nil {
+			return nil, err
+		}
+		r.Recorder.Eventf(sp, corev1.EventTypeNormal, ReasonSEDUpdated,
+			"recreated Secret %s with type %s", sed.Name, sed.Type)
 		return sed, nil
 	}
 
+	if !sedChanged(&s, sed) {
+		return sed, nil
+	}
 	if err := r.Update(ctx, sed); err != nil {
 		return nil, err
 	}
+	r.Recorder.Eventf(sp, corev1.EventTypeNormal, ReasonSEDUpdated, "updated Secret %s", sed.Name)
 	return sed, nil
 }
 
-func (r *ServiceResourceMapReconciler) deleteLinkedResources(ctx context.Context, smName string) error {
+// outputKey identifies the output of an instance
+func outputKey(u *unstructured.Unstructured, o binding.Output) string {
+	return u.GetNamespace() + "/" + u.GetName() + "/" + o.Name
+}
+
+// failedRules describes the failed rules by their keys and reasons
+func failedRules(failures []*binding.RuleError) string {
+	fs := make([]string, 0, len(failures))
+	for _, f := range failures {
+		fs = append(fs, f.Key+"="+f.Reason())
+	}
+	return strings.Join(fs, ",")
+}
+
+// sedChanged returns true if the rendered SED differs from the stored one.
+// Only the content and the labels are compared, as the rest is not managed.
+func sedChanged(current, desired *corev1.Secret) bool {
+	if len(current.Data) != len(desired.Data) {
+		return true
+	}
+	for k, v := range desired.Data {
+		if cv, ok := current.Data[k]; !ok || !bytes.Equal(cv, v) {
+			return true
+		}
+	}
+
+	for k, v := range desired.Labels {
+		if current.Labels[k] != v {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteLinkedResources stops watching the instances of a deleted map, or of
+// a map no longer allowed to watch them, and deletes their ServiceProxies and
+// SEDs. The map is identified by its mapKey, why is reported by the Event.
+func (r *ServiceResourceMapReconciler) deleteLinkedResources(ctx context.Context, smName, why string) error {
 	l := log.FromContext(ctx)
 
+	// the other maps of the service claim its instances back, once they are
+	// deleted
+	if sm := r.stopWatching(smName, why); sm != nil {
+		defer r.replayOthers(ctx, sm)
+	}
+
+	// the resources are deleted by the replica owning the ServiceResourceMap
+	if !r.owns(smName) {
+		return nil
+	}
+
 	// retrieve serviceproxies
 	l.Info("service map deleted, deleting also ServiceProxy", "serviceresourcemap name", smName)
 	var sps bindingoperatorscoreoscomv1alpha1.ServiceProxyList
@@ -298,24 +982,66 @@ func (r *ServiceResourceMapReconciler) deleteLinkedResources(ctx context.Context
 	for _, sp := range sps.Items {
 		l.Info("processing impacted ServiceProxies", "serviceproxy namespace", sp.Namespace, "serviceproxy name", sp.Name)
 
-		if sn := sp.Status.Binding.Name; sn != "" {
-			l.Info("deleting linked Service Endpoint Definition", "sed", sn, "namespace", sp.Namespace)
-			if err := r.deleteSecretIfExists(ctx, sp.Namespace, sn); err != nil {
-				return err
-			}
-		}
-
-		// delete ServiceProxy
-		if err := r.Delete(ctx, &sp); err != nil {
+		if err := r.deleteServiceProxy(ctx, &sp); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	if i, ok := r.informers[smName]; ok {
-		i.cancelFunc()
-		delete(r.informers, smName)
+// stopWatching stops watching the instances of the map identified by its
+// mapKey, forgetting their claims, collisions and rule failures. It returns
+// the map the instances were handled with, nil if they were not watched,
+// for the other maps of the service to claim them.
+func (r *ServiceResourceMapReconciler) stopWatching(smName, why string) *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap {
+	r.contested.Forget(smName)
+	r.collisions.Forget(smName)
+	r.ruleFailures.Forget(smName)
+	h, ok := r.informers.Unregister(smName).(*instanceHandler)
+	if !ok {
+		return nil
+	}
+	r.Recorder.Event(mapObject(h.sm), corev1.EventTypeNormal, ReasonInformerStopped, why+", stopped watching instances")
+	return h.sm
+}
+
+// owns returns true if the replica owns the ServiceResourceMap, always true
+// if not sharded
+func (r *ServiceResourceMapReconciler) owns(smName string) bool {
+	return r.sharder == nil || r.sharder.Owns(smName)
+}
+
+// ownsInstance returns true if the replica handles the instance, always true
+// if not sharded
+func (r *ServiceResourceMapReconciler) ownsInstance(u *unstructured.Unstructured) bool {
+	return r.sharder == nil || r.sharder.OwnsObject(u)
+}
+
+// rebalance hands the instances of every ServiceResourceMap to the handlers
+// again when the shard members change, so that the replica handles the
+// instances it took over
+func (r *ServiceResourceMapReconciler) rebalance(ctx context.Context, ring *sharding.Ring) {
+	l := log.FromContext(ctx)
+
+	var sms bindingoperatorscoreoscomv1alpha1.ServiceResourceMapList
+	if err := r.List(ctx, &sms); err != nil {
+		l.Error(err, "can not list the ServiceResourceMaps to rebalance")
+		return
+	}
+
+	var ms bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapList
+	if err := r.List(ctx, &ms); err != nil {
+		l.Error(err, "can not list the NamespacedServiceResourceMaps to rebalance")
+		return
+	}
+
+	l.Info("rebalancing instances", "members", ring.Members())
+	for _, sm := range sms.Items {
+		r.informers.Replay(sm.Name)
+	}
+	for _, m := range ms.Items {
+		r.informers.Replay(mapKey(mapOf(&m)))
 	}
-	return nil
 }
 
 func (r *ServiceResourceMapReconciler) deleteSecretIfExists(ctx context.Context, namespace, name string) error {
@@ -335,29 +1061,140 @@ func (r *ServiceResourceMapReconciler) deleteSecretIfExists(ctx context.Context,
 	return nil
 }
 
-func (r *ServiceResourceMapReconciler) deleteServiceProxy(ctx context.Context, obj interface{}) {
+// deleteServiceProxy deletes the ServiceProxy together with the Service
+// Endpoint Definitions and the dedicated ServiceProxies recorded in its status
+func (r *ServiceResourceMapReconciler) deleteServiceProxy(ctx context.Context, sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy) error {
 	l, _ := logr.FromContext(ctx)
-	u := obj.(*unstructured.Unstructured)
 
-	l.Info("deleting service proxy")
+	seds, sps := recordedNames(sp.Status)
+	for n := range seds {
+		l.Info("deleting linked Service Endpoint Definition", "sed", n, "namespace", sp.Namespace)
+		if err := r.deleteSecretIfExists(ctx, sp.Namespace, n); err != nil {
+			return err
+		}
+	}
 
-	okey := client.ObjectKey{Namespace: u.GetNamespace(), Name: u.GetName()}
+	for n := range sps {
+		l.Info("deleting linked ServiceProxy", "serviceproxy", n, "namespace", sp.Namespace)
+		if err := r.deleteServiceProxyIfExists(ctx, sp.Namespace, n); err != nil {
+			return err
+		}
+	}
+
+	l.Info("deleting service proxy", "serviceproxy", sp.Name, "namespace", sp.Namespace)
+	return client.IgnoreNotFound(r.Delete(ctx, sp))
+}
+
+func (r *ServiceResourceMapReconciler) deleteServiceProxyIfExists(ctx context.Context, namespace, name string) error {
 	var sp bindingoperatorscoreoscomv1alpha1.ServiceProxy
-	if err := r.Get(ctx, okey, &sp); err != nil {
-		l.Error(err, "error getting proxable service for target service", "target service key", okey)
-		return
+	spkey := client.ObjectKey{Namespace: namespace, Name: name}
+	if err := r.Get(ctx, spkey, &sp); err != nil {
+		return client.IgnoreNotFound(err)
 	}
 
-	if err := r.Delete(ctx, &sp); err != nil {
-		l.Error(err, "error deleting proxable service", "target service key", okey)
-		return
+	return client.IgnoreNotFound(r.Delete(ctx, &sp))
+}
+
+// ReadyCheck fails while the informer of any ServiceResourceMap has not
+// synced or is failing its watch. Replicas that are not elected run no
+// informer and are ready, unless sharded: sharded replicas are not ready
+// until they know the shard members.
+func (r *ServiceResourceMapReconciler) ReadyCheck(req *http.Request) error {
+	if r.sharder != nil && !r.sharder.Ready() {
+		return fmt.Errorf("shard members not listed yet")
+	}
+	if !r.informers.Started() {
+		return nil
+	}
+
+	var sms bindingoperatorscoreoscomv1alpha1.ServiceResourceMapList
+	if err := r.List(req.Context(), &sms); err != nil {
+		return err
+	}
+	var ms bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapList
+	if err := r.List(req.Context(), &ms); err != nil {
+		return err
 	}
+
+	for i := range sms.Items {
+		if err := r.checkReady(&sms.Items[i]); err != nil {
+			return err
+		}
+	}
+	for i := range ms.Items {
+		if err := r.checkReady(mapOf(&ms.Items[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReady fails if the informer of the map has not synced or is failing
+func (r *ServiceResourceMapReconciler) checkReady(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) error {
+	// maps whose resource, selectors, cases, readiness, outputs, namespace
+	// or templates are invalid are not watched, nor are the instances the
+	// ServiceAccount of a NamespacedServiceResourceMap can not read
+	if c := meta.FindStatusCondition(sm.Status.Conditions, bindingoperatorscoreoscomv1alpha1.ServiceResourceMapConditionSynced); c != nil {
+		switch c.Reason {
+		case ReasonGVRUnresolved, ReasonInvalidSelector, ReasonInvalidCase, ReasonInvalidReadiness,
+			ReasonInvalidOutput, ReasonNamespaceNotWatched, ReasonInstancesForbidden, ReasonTemplateNotFound:
+			return nil
+		}
+	}
+
+	key := mapKey(sm)
+	s, ok := r.informers.State(key)
+	switch {
+	case !ok:
+		return fmt.Errorf("ServiceResourceMap '%s' is not watched yet", key)
+	case s.Err != nil:
+		return fmt.Errorf("informer of ServiceResourceMap '%s' is failing: %w", key, s.Err)
+	case !s.Synced:
+		return fmt.Errorf("informer of ServiceResourceMap '%s' has not synced", key)
+	}
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ServiceResourceMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	r.config = mgr.GetConfig()
-	r.informers = make(map[string]informer)
+	if r.ResyncPeriod == 0 {
+		r.ResyncPeriod = time.Minute
+	}
+
+	clusterClient, err := dynamic.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	// ServiceResourceMaps are requeued when the state of their informer
+	// changes, or when other maps claim their instances
+	r.events = make(chan event.GenericEvent)
+	r.contested = claims.NewContested()
+	r.collisions = claims.NewContested()
+	r.ruleFailures = claims.NewContested()
+	r.informers = informers.NewManager(clusterClient, r.ResyncPeriod, r.Namespace, func(ctx context.Context, name string) {
+		// the registrations of NamespacedServiceResourceMaps are named
+		// after their namespace and name
+		sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if ns, n, ok := strings.Cut(name, "/"); ok {
+			sm.Namespace, sm.Name = ns, n
+		}
+		select {
+		case r.events <- event.GenericEvent{Object: sm}:
+		case <-ctx.Done():
+		}
+	})
+	r.coalescer = coalesce.New(r.CoalesceWindow, r.Options.MaxConcurrentReconciles)
+	// access decisions are reviewed again as often as the referenced objects
+	// are read again
+	r.reviewer = access.NewReviewer(mgr.GetClient(), r.ResyncPeriod)
+
+	if r.Sharding != nil {
+		r.sharder = sharding.New(mgr.GetClient(), mgr.GetAPIReader(), *r.Sharding, r.rebalance)
+		if err := mgr.Add(r.sharder); err != nil {
+			return err
+		}
+	}
 
 	mgr.
 		GetFieldIndexer().
@@ -365,11 +1202,49 @@ func (r *ServiceResourceMapReconciler) SetupWithManager(mgr ctrl.Manager) error
 			&bindingoperatorscoreoscomv1alpha1.ServiceProxy{},
 			".spec.service_resource_map",
 			func(o client.Object) []string {
-				sm := o.(*bindingoperatorscoreoscomv1alpha1.ServiceProxy)
-				return []string{sm.Spec.ServiceResourceMapRef}
+				return []string{spMapKey(o.(*bindingoperatorscoreoscomv1alpha1.ServiceProxy))}
 			})
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{}).
-		Complete(r)
+	// the controller is not managed by the manager, so that it runs on every
+	// replica when sharded
+	opts := r.Options
+	opts.Reconciler = r
+	c, err := controller.NewUnmanaged("serviceresourcemap", mgr, opts)
+	if err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{}},
+		&handler.EnqueueRequestForObject{}, predicate.GenerationChangedPredicate{}); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap{}},
+		&handler.EnqueueRequestForObject{}, predicate.GenerationChangedPredicate{}); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate{}},
+		handler.EnqueueRequestsFromMapFunc(r.extending), predicate.GenerationChangedPredicate{}); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Channel{Source: r.events}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+
+	for _, run := range []manager.Runnable{r.informers, r.coalescer, c} {
+		if r.sharder != nil {
+			run = everyReplica{run}
+		}
+		if err := mgr.Add(run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// everyReplica runs a Runnable on every replica, elected or not
+type everyReplica struct {
+	manager.Runnable
+}
+
+func (everyReplica) NeedLeaderElection() bool {
+	return false
 }
@@ -0,0 +1,527 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/claims"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/coalesce"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/informers"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/metrics"
+)
+
+// countingClient is an in-memory client counting the API calls, by verb. The
+// lists of maps, read from the manager's cache, and of Leases are not
+// counted.
+type countingClient struct {
+	client.Client
+
+	mu    sync.Mutex
+	objs  map[string]client.Object
+	calls map[string]int
+}
+
+func newCountingClient() *countingClient {
+	return &countingClient{objs: map[string]client.Object{}, calls: map[string]int{}}
+}
+
+func (c *countingClient) key(obj client.Object, k client.ObjectKey) string {
+	return fmt.Sprintf("%T/%s", obj, k)
+}
+
+func (c *countingClient) Get(_ context.Context, k client.ObjectKey, obj client.Object) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["get"]++
+
+	stored, ok := c.objs[c.key(obj, k)]
+	if !ok {
+		return apierrors.NewNotFound(schema.GroupResource{}, k.Name)
+	}
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(stored.DeepCopyObject()).Elem())
+	return nil
+}
+
+func (c *countingClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["create"]++
+	c.objs[c.key(obj, client.ObjectKeyFromObject(obj))] = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+func (c *countingClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["update"]++
+	c.objs[c.key(obj, client.ObjectKeyFromObject(obj))] = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+func (c *countingClient) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["delete"]++
+
+	k := c.key(obj, client.ObjectKeyFromObject(obj))
+	if _, ok := c.objs[k]; !ok {
+		return apierrors.NewNotFound(schema.GroupResource{}, obj.GetName())
+	}
+	delete(c.objs, k)
+	return nil
+}
+
+func (c *countingClient) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	o := &client.ListOptions{}
+	o.ApplyOptions(opts)
+	for _, obj := range c.objs {
+		if o.Namespace != "" && obj.GetNamespace() != o.Namespace {
+			continue
+		}
+		switch l := list.(type) {
+		case *bindingoperatorscoreoscomv1alpha1.ServiceResourceMapList:
+			if sm, ok := obj.(*bindingoperatorscoreoscomv1alpha1.ServiceResourceMap); ok {
+				l.Items = append(l.Items, *sm.DeepCopy())
+			}
+		case *bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapList:
+			if m, ok := obj.(*bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap); ok {
+				l.Items = append(l.Items, *m.DeepCopy())
+			}
+		case *coordinationv1.LeaseList:
+			if lease, ok := obj.(*coordinationv1.Lease); ok {
+				l.Items = append(l.Items, *lease.DeepCopy())
+			}
+		default:
+			panic("not implemented")
+		}
+	}
+	return nil
+}
+
+func (c *countingClient) Status() client.StatusWriter {
+	return c
+}
+
+func (c *countingClient) Patch(context.Context, client.Object, client.Patch, ...client.PatchOption) error {
+	panic("not implemented")
+}
+
+func (c *countingClient) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, v := range c.calls {
+		n += v
+	}
+	return n
+}
+
+// noDynamic is the client of informers that are never started
+type noDynamic struct {
+	dynamic.Interface
+	dynamic.NamespaceableResourceInterface
+}
+
+func (n noDynamic) Resource(schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return n
+}
+
+func (n noDynamic) Namespace(string) dynamic.ResourceInterface {
+	return n
+}
+
+// BenchmarkInstanceUpdates reports the API calls made for 100 instances
+// changing 10 times 1ms apart, 8 of the changes being status churn not read
+// by the rules. The `every-change` case updates the ServiceProxy and SED on
+// every change, neither coalescing nor skipping them.
+func BenchmarkInstanceUpdates(b *testing.B) {
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "srm"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceKindReference: bindingoperatorscoreoscomv1alpha1.ServiceKindReference{ApiGroup: "postgresql.example.com/v1", Kind: "databases"},
+			ServiceMap:           map[string]string{"type": "postgresql", "host": "path={.status.host}"},
+		},
+	}
+	gvr := schema.GroupVersionResource{Group: "postgresql.example.com", Version: "v1", Resource: "databases"}
+
+	// instance returns the nth version of an instance
+	instance := func(i, n int) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("postgresql.example.com/v1")
+		u.SetKind("Database")
+		u.SetNamespace("ns")
+		u.SetName(fmt.Sprintf("db-%d", i))
+		u.SetResourceVersion(fmt.Sprint(n))
+		u.Object["status"] = map[string]interface{}{
+			"host":               fmt.Sprintf("db-%d-%d.example.com", i, n/5),
+			"observedGeneration": int64(n),
+		}
+		return u
+	}
+
+	for _, bc := range []struct {
+		name   string
+		window time.Duration
+		every  bool
+	}{
+		{name: "every-change", every: true},
+		{name: "window=0s", window: 0},
+		{name: "window=20ms", window: 20 * time.Millisecond},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			calls, changes := 0, 0
+			for n := 0; n < b.N; n++ {
+				cli := newCountingClient()
+				r := &ServiceResourceMapReconciler{
+					Client:       cli,
+					Recorder:     &record.FakeRecorder{},
+					informers:    informers.NewManager(noDynamic{}, 0, "", nil),
+					coalescer:    coalesce.New(bc.window, 4),
+					contested:    claims.NewContested(),
+					collisions:   claims.NewContested(),
+					ruleFailures: claims.NewContested(),
+				}
+				h := &instanceHandler{r: r, sm: sm}
+				r.informers.Register(sm.Name, gvr, informers.Options{}, h)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				go func() {
+					_ = r.coalescer.Start(ctx)
+				}()
+
+				for i := 0; i < 100; i++ {
+					h.OnAdd(ctx, instance(i, 0))
+				}
+				for r.coalescer.Pending() > 0 {
+					time.Sleep(time.Millisecond)
+				}
+				before := cli.Calls()
+
+				for v := 1; v <= 10; v++ {
+					for i := 0; i < 100; i++ {
+						past, future := instance(i, v-1), instance(i, v)
+						if bc.every {
+							r.observeCreateOrUpdateServiceProxyAndSED(ctx, sm, future, time.Now())
+						} else {
+							h.OnUpdate(ctx, past, future)
+						}
+						changes++
+					}
+					time.Sleep(time.Millisecond)
+				}
+				for r.coalescer.Pending() > 0 {
+					time.Sleep(time.Millisecond)
+				}
+				// let the last work complete
+				time.Sleep(10 * time.Millisecond)
+
+				calls += cli.Calls() - before
+				cancel()
+			}
+			b.ReportMetric(float64(calls)/float64(changes), "api-calls/change")
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "readiness"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceMap: map[string]string{"type": "postgresql", "host": "path={.status.endpoint.address}"},
+			Readiness: &bindingoperatorscoreoscomv1alpha1.ServiceResourceMapReadiness{
+				ConditionType: "Ready",
+				When:          ".status.endpoint.address",
+			},
+			Outputs: []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{
+				{Name: "ro", ServiceProxy: true, ServiceMap: map[string]string{"type": "postgresql", "username": "reader"}},
+			},
+		},
+	}
+	u := &unstructured.Unstructured{}
+	u.SetNamespace("ns")
+	u.SetName("db")
+
+	cli := newCountingClient()
+	r := &ServiceResourceMapReconciler{Client: cli, Recorder: &record.FakeRecorder{}, ruleFailures: claims.NewContested()}
+	readyOf := func(name string) *metav1.Condition {
+		var sp bindingoperatorscoreoscomv1alpha1.ServiceProxy
+		g.Expect(cli.Get(ctx, client.ObjectKey{Namespace: "ns", Name: name}, &sp)).To(Succeed())
+		return meta.FindStatusCondition(sp.Status.Conditions, bindingoperatorscoreoscomv1alpha1.ServiceProxyConditionReady)
+	}
+	ready := func() *metav1.Condition {
+		return readyOf("db")
+	}
+	sed := func() error {
+		return cli.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "db-sed"}, &corev1.Secret{})
+	}
+
+	// the ServiceProxy of an instance that is not ready is not ready, and its
+	// SED is not published
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Now())).To(Succeed())
+	g.Expect(ready().Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(ready().Reason).To(Equal(ReasonInstanceNotReady))
+	g.Expect(ready().Message).To(ContainSubstring("condition 'Ready' to be 'True', not found"))
+	g.Expect(apierrors.IsNotFound(sed())).To(BeTrue())
+	// nor are the ServiceProxies of its outputs
+	g.Expect(readyOf("db-ro").Reason).To(Equal(ReasonInstanceNotReady))
+	g.Expect(readyOf("db-ro").Message).To(Equal(ready().Message))
+
+	u.Object["status"] = map[string]interface{}{
+		"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}},
+	}
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Now())).To(Succeed())
+	g.Expect(ready().Message).To(ContainSubstring("satisfy '.status.endpoint.address'"))
+	g.Expect(apierrors.IsNotFound(sed())).To(BeTrue())
+
+	// the latency is observed from the reception of the change once the SED
+	// is written
+	latency := func() *dto.Histogram {
+		var m dto.Metric
+		g.Expect(metrics.InstanceToSEDLatency.WithLabelValues(sm.Name).(prometheus.Histogram).Write(&m)).To(Succeed())
+		return m.GetHistogram()
+	}
+	g.Expect(latency().GetSampleCount()).To(BeZero())
+
+	u.Object["status"].(map[string]interface{})["endpoint"] = map[string]interface{}{"address": "db.example.com"}
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Now().Add(-time.Minute))).To(Succeed())
+	g.Expect(ready().Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(ready().Reason).To(Equal(ReasonPublished))
+	g.Expect(sed()).To(Succeed())
+	g.Expect(readyOf("db-ro").Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(latency().GetSampleCount()).To(Equal(uint64(1)))
+	g.Expect(latency().GetSampleSum()).To(BeNumerically(">=", 60))
+}
+
+func TestNameCollision(t *testing.T) {
+	g := NewWithT(t)
+	ctx := logr.NewContext(context.Background(), logr.Discard())
+
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "rds"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceMap: map[string]string{"type": "postgresql", "username": "admin"},
+			Outputs: []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{
+				{Name: "ro", ServiceMap: map[string]string{"type": "postgresql", "username": "reader"}},
+			},
+		},
+	}
+	instance := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetNamespace("ns")
+		u.SetName(name)
+		return u
+	}
+
+	cli := newCountingClient()
+	r := &ServiceResourceMapReconciler{
+		Client:       cli,
+		Recorder:     &record.FakeRecorder{},
+		collisions:   claims.NewContested(),
+		ruleFailures: claims.NewContested(),
+		events:       make(chan event.GenericEvent, 2),
+	}
+
+	// the default SED of db-ro is the SED of the output ro of db
+	r.observeCreateOrUpdateServiceProxyAndSED(ctx, sm, instance("db"), time.Now())
+	r.observeCreateOrUpdateServiceProxyAndSED(ctx, sm, instance("db-ro"), time.Now())
+	g.Expect(r.events).To(HaveLen(1))
+
+	var sed corev1.Secret
+	g.Expect(cli.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "db-ro-sed"}, &sed)).To(Succeed())
+	g.Expect(string(sed.Data["username"])).To(Equal("reader"))
+
+	c := r.collisionCondition(sm)
+	g.Expect(c.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(c.Reason).To(Equal(ReasonNamesCollide))
+	g.Expect(c.Message).To(ContainSubstring("ns/db-ro: Secret db-ro-sed is used by output ro of instance db"))
+
+	// the collision is cleared once the instance is deleted
+	r.collided(ctx, sm, instance("db-ro"), nil)
+	g.Expect(r.collisionCondition(sm).Reason).To(Equal(ReasonNoCollision))
+}
+
+func TestRuleFailedEvents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "rds"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceMap: map[string]string{
+				"type":     "postgresql",
+				"host":     "path={.status.endpoint.address}",
+				"password": "path={.spec.secret},objectType=Secret,sourceKey=password",
+			},
+		},
+	}
+	u := &unstructured.Unstructured{}
+	u.SetNamespace("ns")
+	u.SetName("db")
+	u.Object["spec"] = map[string]interface{}{"secret": "db-master-password"}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceResourceMapReconciler{Client: newCountingClient(), Recorder: recorder, ruleFailures: claims.NewContested()}
+	failed := func() []string {
+		var es []string
+		for len(recorder.Events) > 0 {
+			if e := <-recorder.Events; strings.Contains(e, ReasonRuleFailed) {
+				es = append(es, e)
+			}
+		}
+		return es
+	}
+
+	// the Events do not disclose the names of the referenced objects
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Time{})).To(Succeed())
+	g.Expect(failed()).To(ConsistOf(
+		"Warning RuleFailed rule 'host' of ServiceResourceMap rds failed: JsonpathFailed",
+		"Warning RuleFailed rule 'password' of ServiceResourceMap rds failed: ReferenceNotFound",
+	))
+
+	// the same failures are not reported again
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Time{})).To(Succeed())
+	g.Expect(failed()).To(BeEmpty())
+
+	u.Object["status"] = map[string]interface{}{"endpoint": map[string]interface{}{"address": "db.example.com"}}
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Time{})).To(Succeed())
+	g.Expect(failed()).To(ConsistOf("Warning RuleFailed rule 'password' of ServiceResourceMap rds failed: ReferenceNotFound"))
+}
+
+func TestCertificatesValid(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).NotTo(HaveOccurred())
+	notAfter := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "rds"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceMap: map[string]string{
+				"type":   "postgresql",
+				"ca.crt": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			},
+			Outputs: []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{{
+				Name:         "admin",
+				ServiceProxy: true,
+				ServiceMap: map[string]string{
+					"type":   "postgresql",
+					"ca.crt": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+				},
+			}},
+		},
+	}
+	u := &unstructured.Unstructured{}
+	u.SetNamespace("ns")
+	u.SetName("db")
+
+	cli := newCountingClient()
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceResourceMapReconciler{
+		Client:                   cli,
+		Recorder:                 recorder,
+		CertificateExpiryWarning: 30 * 24 * time.Hour,
+		ruleFailures:             claims.NewContested(),
+	}
+	validOf := func(name string) *metav1.Condition {
+		var sp bindingoperatorscoreoscomv1alpha1.ServiceProxy
+		g.Expect(cli.Get(ctx, client.ObjectKey{Namespace: "ns", Name: name}, &sp)).To(Succeed())
+		return meta.FindStatusCondition(sp.Status.Conditions, bindingoperatorscoreoscomv1alpha1.ServiceProxyConditionCertificatesValid)
+	}
+	valid := func() *metav1.Condition {
+		return validOf("db")
+	}
+	events := func() []string {
+		var es []string
+		for len(recorder.Events) > 0 {
+			es = append(es, <-recorder.Events)
+		}
+		return es
+	}
+
+	// the certificate expires within the warning, which is reported once
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Time{})).To(Succeed())
+	g.Expect(valid().Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(valid().Reason).To(Equal(ReasonCertificateExpiring))
+	g.Expect(valid().Message).To(ContainSubstring(notAfter.UTC().Format(time.RFC3339)))
+	// as by the dedicated ServiceProxy of the output
+	g.Expect(validOf("db-admin").Reason).To(Equal(ReasonCertificateExpiring))
+	g.Expect(events()).To(ContainElements(HavePrefix("Warning CertificateExpiring"), HavePrefix("Warning CertificateExpiring")))
+
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Time{})).To(Succeed())
+	g.Expect(valid().Reason).To(Equal(ReasonCertificateExpiring))
+	g.Expect(events()).NotTo(ContainElement(HavePrefix("Warning")))
+
+	r.CertificateExpiryWarning = 24 * time.Hour
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Time{})).To(Succeed())
+	g.Expect(valid().Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(valid().Reason).To(Equal(ReasonCertificatesValid))
+	g.Expect(validOf("db-admin").Reason).To(Equal(ReasonCertificatesValid))
+
+	status := bindingoperatorscoreoscomv1alpha1.ServiceProxyStatus{}
+	r.setCertificatesValid(&bindingoperatorscoreoscomv1alpha1.ServiceProxy{}, &status, notAfter, notAfter)
+	g.Expect(meta.IsStatusConditionFalse(status.Conditions, bindingoperatorscoreoscomv1alpha1.ServiceProxyConditionCertificatesValid)).To(BeTrue())
+	g.Expect(status.Conditions[0].Reason).To(Equal(ReasonCertificateExpired))
+	g.Expect(events()).To(ContainElement(HavePrefix("Warning CertificateExpired")))
+
+	// the condition is removed once the SEDs hold no certificates, each
+	// ServiceProxy tracking the SEDs it binds
+	delete(sm.Spec.ServiceMap, "ca.crt")
+	g.Expect(r.createOrUpdateServiceProxyAndSED(ctx, sm, u, time.Time{})).To(Succeed())
+	g.Expect(valid()).To(BeNil())
+	g.Expect(validOf("db-admin").Reason).To(Equal(ReasonCertificatesValid))
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/binding"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/claims"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/coalesce"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/informers"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/sharding"
+)
+
+// replicaClient connects a replica of the sharding harness to the shared
+// in-memory API server, and fails every call while the replica is
+// partitioned.
+type replicaClient struct {
+	*countingClient
+
+	mu          sync.Mutex
+	partitioned bool
+}
+
+func (c *replicaClient) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.partitioned {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+func (c *replicaClient) setPartitioned(p bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.partitioned = p
+}
+
+func (c *replicaClient) Get(ctx context.Context, k client.ObjectKey, obj client.Object) error {
+	if err := c.err(); err != nil {
+		return err
+	}
+	return c.countingClient.Get(ctx, k, obj)
+}
+
+func (c *replicaClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.err(); err != nil {
+		return err
+	}
+	return c.countingClient.Create(ctx, obj, opts...)
+}
+
+func (c *replicaClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.err(); err != nil {
+		return err
+	}
+	return c.countingClient.Update(ctx, obj, opts...)
+}
+
+func (c *replicaClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.err(); err != nil {
+		return err
+	}
+	return c.countingClient.Delete(ctx, obj, opts...)
+}
+
+func (c *replicaClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.err(); err != nil {
+		return err
+	}
+	return c.countingClient.List(ctx, list, opts...)
+}
+
+func (c *replicaClient) Status() client.StatusWriter {
+	return c
+}
+
+// handlerReplica is an operator replica of the sharding harness, running
+// the instance handler of a ServiceResourceMap
+type handlerReplica struct {
+	cli *replicaClient
+	r   *ServiceResourceMapReconciler
+	h   *instanceHandler
+}
+
+// handledBy returns and forgets the instances whose SED the replica rendered,
+// as recorded with the failure of their `host` rule
+func (rp *handlerReplica) handledBy() map[string]string {
+	handled := rp.r.ruleFailures.Of(mapKey(rp.h.sm))
+	rp.r.ruleFailures.Forget(mapKey(rp.h.sm))
+	return handled
+}
+
+func startHandlerReplica(ctx context.Context, api *countingClient, identity string, sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) *handlerReplica {
+	cli := &replicaClient{countingClient: api}
+	r := &ServiceResourceMapReconciler{
+		Client:       cli,
+		Recorder:     &record.FakeRecorder{},
+		informers:    informers.NewManager(noDynamic{}, 0, "", nil),
+		coalescer:    coalesce.New(0, 2),
+		contested:    claims.NewContested(),
+		collisions:   claims.NewContested(),
+		ruleFailures: claims.NewContested(),
+		events:       make(chan event.GenericEvent),
+		sharder: sharding.New(cli, cli, sharding.Options{
+			Identity:      identity,
+			Namespace:     "service-mapper-system",
+			Key:           sharding.KeyUID,
+			LeaseDuration: time.Second,
+			RenewPeriod:   50 * time.Millisecond,
+			VirtualNodes:  64,
+		}, nil),
+	}
+	h := &instanceHandler{r: r, sm: sm}
+	r.informers.Register(mapKey(sm), schema.GroupVersionResource{Group: "postgresql.example.com", Version: "v1", Resource: "databases"}, informers.Options{}, h)
+
+	// the maps are requeued by the controller, not run by the harness
+	go func() {
+		for {
+			select {
+			case <-r.events:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		_ = r.coalescer.Start(ctx)
+	}()
+	go func() {
+		_ = r.sharder.Start(ctx)
+	}()
+	return &handlerReplica{cli: cli, r: r, h: h}
+}
+
+// TestShardedHandlers runs the instance handlers of three replicas receiving
+// the same instance changes, as their informers do, and checks that every
+// instance is handled by a single replica, including when a replica is
+// partitioned from the API server, and that the replica owning the map
+// reports the name collisions of every instance.
+func TestShardedHandlers(t *testing.T) {
+	g := NewWithT(t)
+	ctx, cancel := context.WithCancel(logr.NewContext(context.Background(), logr.Discard()))
+	defer cancel()
+
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceMap: map[string]string{"type": "postgresql", "host": "path={.status.endpoint.address}"},
+		},
+	}
+	sm.Name = "srm"
+	var instances []*unstructured.Unstructured
+	for i := 0; i < 30; i++ {
+		u := &unstructured.Unstructured{}
+		u.SetNamespace("ns")
+		u.SetName(fmt.Sprintf("db-%d", i))
+		u.SetUID(types.UID(fmt.Sprintf("uid-%d", i)))
+		u.SetResourceVersion("1")
+		instances = append(instances, u)
+	}
+
+	api := newCountingClient()
+	// the SEDs of the first instances are taken by other instances
+	for i := 0; i < 10; i++ {
+		sed := &corev1.Secret{}
+		sed.Namespace, sed.Name = "ns", fmt.Sprintf("db-%d-sed", i)
+		sed.Labels = map[string]string{binding.LabelInstanceName: "other"}
+		g.Expect(api.Create(ctx, sed)).To(Succeed())
+	}
+	var replicas []*handlerReplica
+	for i := 0; i < 3; i++ {
+		replicas = append(replicas, startHandlerReplica(ctx, api, fmt.Sprintf("replica-%d", i), sm))
+	}
+	members := func(rs ...*handlerReplica) func() [][]string {
+		return func() [][]string {
+			var ms [][]string
+			for _, rp := range rs {
+				ms = append(ms, rp.r.sharder.Members())
+			}
+			return ms
+		}
+	}
+	all := []string{"replica-0", "replica-1", "replica-2"}
+	g.Eventually(members(replicas...), 5*time.Second).Should(Equal([][]string{all, all, all}))
+	// the instances are handed over to the replicas that joined once the
+	// others have seen them join
+	settled := func(rs ...*handlerReplica) func() bool {
+		return func() bool {
+			for _, u := range instances {
+				n := 0
+				for _, rp := range rs {
+					if rp.r.ownsInstance(u) {
+						n++
+					}
+				}
+				if n != 1 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	g.Eventually(settled(replicas...), 5*time.Second).Should(BeTrue())
+
+	// handle delivers the instances to every replica, and returns the number
+	// of replicas handling each instance
+	handle := func(deliver func(h *instanceHandler, u *unstructured.Unstructured)) map[string]int {
+		for _, u := range instances {
+			for _, rp := range replicas {
+				deliver(rp.h, u)
+			}
+		}
+		for _, rp := range replicas {
+			for rp.r.coalescer.Pending() > 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		// let the last work complete
+		time.Sleep(10 * time.Millisecond)
+
+		handled := map[string]int{}
+		for _, rp := range replicas {
+			for n := range rp.handledBy() {
+				handled[n]++
+			}
+		}
+		return handled
+	}
+	once := func(handled map[string]int) {
+		g.Expect(handled).To(HaveLen(len(instances)))
+		for n, c := range handled {
+			g.Expect(c).To(Equal(1), n)
+		}
+	}
+
+	once(handle(func(h *instanceHandler, u *unstructured.Unstructured) { h.OnAdd(ctx, u) }))
+
+	// the replica owning the map knows the collisions of every instance,
+	// including the ones handled by the other replicas
+	var owners []*handlerReplica
+	for _, rp := range replicas {
+		if rp.r.owns(mapKey(sm)) {
+			owners = append(owners, rp)
+		}
+	}
+	g.Expect(owners).To(HaveLen(1))
+	collisions := owners[0].r.collisions.Of(mapKey(sm))
+	g.Expect(collisions).To(HaveLen(10))
+	g.Expect(collisions).To(HaveKeyWithValue("ns/db-0", "Secret db-0-sed is used by instance other"))
+	handledElsewhere := 0
+	for i := 0; i < 10; i++ {
+		if !owners[0].r.ownsInstance(instances[i]) {
+			handledElsewhere++
+		}
+	}
+	g.Expect(handledElsewhere).To(BeNumerically(">", 0))
+	g.Expect(owners[0].r.collisionCondition(sm).Status).To(Equal(metav1.ConditionTrue))
+
+	// a partitioned replica can neither renew its Lease nor see the other
+	// replicas take its instances over: it stops handling them once its Lease
+	// expired, when the others start
+	replicas[0].cli.setPartitioned(true)
+	g.Eventually(members(replicas[1:]...), 5*time.Second).Should(Equal([][]string{
+		{"replica-1", "replica-2"},
+		{"replica-1", "replica-2"},
+	}))
+	for _, u := range instances {
+		g.Expect(replicas[0].r.ownsInstance(u)).To(BeFalse(), u.GetName())
+	}
+	g.Eventually(settled(replicas[1:]...), 5*time.Second).Should(BeTrue())
+
+	// resyncs are handled again
+	once(handle(func(h *instanceHandler, u *unstructured.Unstructured) { h.OnUpdate(ctx, u, u) }))
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/openshift-app-service-poc/service-mapper/pkg/sharding"
+)
+
+// replica simulates a replica of the manager sharing the instances
+type replica struct {
+	sharder *sharding.Sharder
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+func startReplica(identity string) *replica {
+	s := sharding.New(k8sClient, k8sClient, sharding.Options{
+		Identity:      identity,
+		Namespace:     "default",
+		Key:           sharding.KeyUID,
+		LeaseDuration: 2 * time.Second,
+		RenewPeriod:   200 * time.Millisecond,
+		VirtualNodes:  64,
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replica{sharder: s, stop: cancel, stopped: make(chan struct{})}
+	go func() {
+		defer GinkgoRecover()
+		defer close(r.stopped)
+		Expect(s.Start(ctx)).To(Succeed())
+	}()
+	return r
+}
+
+// owners returns the number of replicas owning every key
+func owners(replicas []*replica, keys []string) map[string]int {
+	counts := map[string]int{}
+	for _, k := range keys {
+		counts[k] = 0
+		for _, r := range replicas {
+			if r.sharder.Owns(k) {
+				counts[k]++
+			}
+		}
+	}
+	return counts
+}
+
+// settled returns true once every key has a single owner, the keys moving
+// between replicas being handed over once they all have seen the change
+func settled(replicas []*replica, keys []string) func() bool {
+	return func() bool {
+		for _, n := range owners(replicas, keys) {
+			if n != 1 {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+var _ = Describe("Sharding", func() {
+	It("spreads the instances over the replicas and rebalances them", func() {
+		keys := make([]string, 1000)
+		for i := range keys {
+			keys[i] = string(uuid.NewUUID())
+		}
+		members := func(r *replica) func() []string {
+			return r.sharder.Members
+		}
+
+		By("starting three replicas")
+		replicas := []*replica{}
+		for i := 0; i < 3; i++ {
+			replicas = append(replicas, startReplica(fmt.Sprintf("replica-%d", i)))
+		}
+		defer func() {
+			for _, r := range replicas {
+				r.stop()
+			}
+		}()
+		for _, r := range replicas {
+			Eventually(members(r), 10*time.Second).Should(Equal([]string{"replica-0", "replica-1", "replica-2"}))
+		}
+		Eventually(settled(replicas, keys), 10*time.Second).Should(BeTrue())
+
+		By("stopping a replica, whose instances are taken over")
+		replicas[2].stop()
+		Eventually(replicas[2].stopped).Should(BeClosed())
+		replicas = replicas[:2]
+		for _, r := range replicas {
+			Eventually(members(r), 10*time.Second).Should(Equal([]string{"replica-0", "replica-1"}))
+		}
+		Eventually(settled(replicas, keys), 10*time.Second).Should(BeTrue())
+
+		By("adding a replica, which takes instances over")
+		before := map[string]bool{}
+		for _, k := range keys {
+			before[k] = replicas[0].sharder.Owns(k)
+		}
+		replicas = append(replicas, startReplica("replica-3"))
+		for _, r := range replicas {
+			Eventually(members(r), 10*time.Second).Should(Equal([]string{"replica-0", "replica-1", "replica-3"}))
+		}
+		Eventually(settled(replicas, keys), 10*time.Second).Should(BeTrue())
+		// only the instances taken over by the new replica move
+		moved := 0
+		for k, n := range owners(replicas, keys) {
+			Expect(n).To(Equal(1), k)
+			if replicas[0].sharder.Owns(k) != before[k] {
+				Expect(replicas[2].sharder.Owns(k)).To(BeTrue(), k)
+			}
+			if replicas[2].sharder.Owns(k) {
+				moved++
+			}
+		}
+		Expect(moved).To(BeNumerically("~", len(keys)/3, len(keys)/6))
+	})
+})
@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/binding"
+)
+
+// ReasonTemplateNotFound is the reason of the Synced condition of the maps
+// extending a ServiceMapTemplate that does not exist
+const ReasonTemplateNotFound = "TemplateNotFound"
+
+// extend returns the map whose service_map inherits the rules of the
+// ServiceMapTemplates it extends
+func (r *ServiceResourceMapReconciler) extend(
+	ctx context.Context,
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) (*bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, error) {
+	templates := make([]bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate, 0, len(sm.Spec.Extends))
+	for _, n := range sm.Spec.Extends {
+		var t bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate
+		if err := r.Get(ctx, client.ObjectKey{Name: n}, &t); err != nil {
+			return nil, fmt.Errorf("error getting ServiceMapTemplate '%s': %w", n, err)
+		}
+		templates = append(templates, t)
+	}
+	return binding.Extend(sm, templates), nil
+}
+
+// extending returns the requests of the maps extending a ServiceMapTemplate,
+// so that they apply its changes to their instances
+func (r *ServiceResourceMapReconciler) extending(o client.Object) []reconcile.Request {
+	ctx := context.Background()
+	l := log.FromContext(ctx)
+
+	var sms bindingoperatorscoreoscomv1alpha1.ServiceResourceMapList
+	if err := r.List(ctx, &sms); err != nil {
+		l.Error(err, "can not list the ServiceResourceMaps extending the template", "template", o.GetName())
+		return nil
+	}
+	var ms bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapList
+	if err := r.List(ctx, &ms); err != nil {
+		l.Error(err, "can not list the NamespacedServiceResourceMaps extending the template", "template", o.GetName())
+		return nil
+	}
+
+	var reqs []reconcile.Request
+	for i := range sms.Items {
+		if extends(&sms.Items[i].Spec, o.GetName()) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&sms.Items[i])})
+		}
+	}
+	for i := range ms.Items {
+		if extends(&ms.Items[i].Spec.ServiceResourceMapSpec, o.GetName()) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ms.Items[i])})
+		}
+	}
+	return reqs
+}
+
+// extends returns true if the map extends the ServiceMapTemplate
+func extends(spec *bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec, template string) bool {
+	for _, n := range spec.Extends {
+		if n == template {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/claims"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/informers"
+)
+
+func TestExtend(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cli := newCountingClient()
+	r := &ServiceResourceMapReconciler{Client: cli}
+
+	g.Expect(cli.Create(ctx, &bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "postgresql"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceMapTemplateSpec{
+			ServiceMap: map[string]string{"type": "postgresql", "port": "5432"},
+		},
+	})).To(Succeed())
+
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "rds"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			Extends:    []string{"postgresql"},
+			ServiceMap: map[string]string{"port": "path={.status.endpoint.port}"},
+		},
+	}
+	m := &bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "crunchy"},
+		Spec: bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapSpec{
+			ServiceResourceMapSpec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{Extends: []string{"postgresql"}},
+		},
+	}
+	unrelated := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{ObjectMeta: metav1.ObjectMeta{Name: "redis"}}
+	g.Expect(cli.Create(ctx, sm)).To(Succeed())
+	g.Expect(cli.Create(ctx, m)).To(Succeed())
+	g.Expect(cli.Create(ctx, unrelated)).To(Succeed())
+
+	extended, err := r.extend(ctx, sm)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(extended.Spec.ServiceMap).To(Equal(map[string]string{"type": "postgresql", "port": "path={.status.endpoint.port}"}))
+
+	// the maps extending a template are reconciled when it changes
+	template := &bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate{ObjectMeta: metav1.ObjectMeta{Name: "postgresql"}}
+	g.Expect(r.extending(template)).To(ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Name: "rds"}},
+		reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "tenant", Name: "crunchy"}},
+	))
+
+	// maps extending missing templates are not mapped
+	sm.Spec.Extends = append(sm.Spec.Extends, "missing")
+	_, err = r.extend(ctx, sm)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestTemplateNotFound(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cli := newCountingClient()
+	r := &ServiceResourceMapReconciler{
+		Client:       cli,
+		Recorder:     &record.FakeRecorder{},
+		informers:    informers.NewManager(noDynamic{}, 0, "", nil),
+		contested:    claims.NewContested(),
+		collisions:   claims.NewContested(),
+		ruleFailures: claims.NewContested(),
+	}
+
+	sm := &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "rds", Generation: 2},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			Extends:    []string{"missing"},
+			ServiceMap: map[string]string{"type": "postgresql"},
+		},
+	}
+	g.Expect(cli.Create(ctx, sm)).To(Succeed())
+	gvr := schema.GroupVersionResource{Group: "rds.services.k8s.aws", Version: "v1alpha1", Resource: "dbinstances"}
+	r.informers.Register(mapKey(sm), gvr, informers.Options{}, &instanceHandler{r: r, sm: sm})
+
+	// the instances are no longer handled with the rules of the remaining
+	// templates
+	g.Expect(r.reconcileLinkedResources(ctx, sm)).To(Succeed())
+	_, watched := r.informers.State(mapKey(sm))
+	g.Expect(watched).To(BeFalse())
+	c := meta.FindStatusCondition(sm.Status.Conditions, bindingoperatorscoreoscomv1alpha1.ServiceResourceMapConditionSynced)
+	g.Expect(c).NotTo(BeNil())
+	g.Expect(c.Reason).To(Equal(ReasonTemplateNotFound))
+	g.Expect(r.checkReady(sm)).To(Succeed())
+}
@@ -18,21 +18,29 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	configv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/config/v1alpha1"
 	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
 	"github.com/openshift-app-service-poc/service-mapper/controllers"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/access"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/sharding"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -43,8 +51,10 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(bindingoperatorscoreoscomv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(configv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -52,11 +62,20 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var importBindingAnnotations bool
+	var configFile string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&importBindingAnnotations, "import-binding-annotations", false,
+		"Create ServiceResourceMaps from the Service Binding annotations found on CRDs. "+
+			"Same as enabling the ImportBindingAnnotations feature gate.")
+	flag.StringVar(&configFile, "config", "",
+		"The controller will load its initial configuration from this file. "+
+			"Omit this flag to use the default configuration values. "+
+			"Command-line flags override configuration from this file.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,44 +84,136 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "46e2453b.binding.operators.coreos.com",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
+	// flags set on the command line take precedence over the configuration file
+	options := ctrl.Options{Scheme: scheme}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "metrics-bind-address":
+			options.MetricsBindAddress = metricsAddr
+		case "health-probe-bind-address":
+			options.HealthProbeBindAddress = probeAddr
+		case "leader-elect":
+			options.LeaderElection = enableLeaderElection
+		}
 	})
+
+	var err error
+	config := configv1alpha1.ServiceMapperConfig{}
+	if configFile != "" {
+		options, err = options.AndFrom(ctrl.ConfigFile().AtPath(configFile).OfKind(&config))
+		if err != nil {
+			setupLog.Error(err, "unable to load the config file")
+			os.Exit(1)
+		}
+	}
+
+	config.Default()
+	if err := config.Validate(); err != nil {
+		setupLog.Error(err, "invalid config file", "file", configFile)
+		os.Exit(1)
+	}
+	if importBindingAnnotations {
+		if config.ServiceMapper.FeatureGates == nil {
+			config.ServiceMapper.FeatureGates = map[string]bool{}
+		}
+		config.ServiceMapper.FeatureGates[configv1alpha1.FeatureImportBindingAnnotations] = true
+	}
+
+	if options.MetricsBindAddress == "" {
+		options.MetricsBindAddress = metricsAddr
+	}
+	if options.HealthProbeBindAddress == "" {
+		options.HealthProbeBindAddress = probeAddr
+	}
+	if options.Port == 0 {
+		options.Port = 9443
+	}
+	if options.LeaderElectionID == "" {
+		options.LeaderElectionID = "46e2453b.binding.operators.coreos.com"
+	}
+	// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
+	// when the Manager ends. This requires the binary to immediately end when the
+	// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
+	// speeds up voluntary leader transitions as the new leader don't have to wait
+	// LeaseDuration time first.
+	//
+	// In the default scaffold provided, the program ends immediately after
+	// the manager stops, so would be fine to enable this option. However,
+	// if you are doing or is intended to do any operation such as perform cleanups
+	// after the manager stops then its usage might be unsafe.
+	// options.LeaderElectionReleaseOnCancel = true
+
+	// every controller gets its own rate limiter, as it tracks the failures of its queue
+	controllerOptions := func() controller.Options {
+		return controller.Options{
+			MaxConcurrentReconciles: config.ServiceMapper.MaxConcurrentReconciles,
+			RateLimiter:             config.ServiceMapper.RateLimiter.NewRateLimiter(),
+		}
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.ServiceResourceMapReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	var shardingOptions *sharding.Options
+	if config.ServiceMapper.Sharding.Enabled {
+		if shardingOptions, err = newShardingOptions(config.ServiceMapper.Sharding); err != nil {
+			setupLog.Error(err, "unable to configure sharding")
+			os.Exit(1)
+		}
+	}
+
+	serviceResourceMapReconciler := &controllers.ServiceResourceMapReconciler{
+		Client:                   mgr.GetClient(),
+		Scheme:                   mgr.GetScheme(),
+		Recorder:                 mgr.GetEventRecorderFor("service-mapper"),
+		Options:                  controllerOptions(),
+		ResyncPeriod:             config.ServiceMapper.InformerResyncPeriod.Duration,
+		CoalesceWindow:           config.ServiceMapper.CoalesceWindow.Duration,
+		CertificateExpiryWarning: config.ServiceMapper.CertificateExpiryWarning.Duration,
+		Namespace:                options.Namespace,
+		Sharding:                 shardingOptions,
+	}
+	if err = serviceResourceMapReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ServiceResourceMap")
 		os.Exit(1)
 	}
+	if config.ServiceMapper.Enabled(configv1alpha1.FeatureServiceProxyBinding) {
+		if err = (&controllers.ServiceProxyBindingReconciler{
+			Client:  mgr.GetClient(),
+			Scheme:  mgr.GetScheme(),
+			Options: controllerOptions(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ServiceProxyBinding")
+			os.Exit(1)
+		}
+	}
+	if config.ServiceMapper.Enabled(configv1alpha1.FeatureImportBindingAnnotations) {
+		if err = (&controllers.BindingAnnotationsReconciler{
+			Client:  mgr.GetClient(),
+			Scheme:  mgr.GetScheme(),
+			Options: controllerOptions(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "BindingAnnotations")
+			os.Exit(1)
+		}
+	}
+	// the webhook admits the NamespacedServiceResourceMaps set by users who
+	// may act as their ServiceAccount; running out of the cluster, it is
+	// disabled with ENABLE_WEBHOOKS=false
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		mgr.GetWebhookServer().Register(access.ValidateNamespacedServiceResourceMapPath,
+			&webhook.Admission{Handler: &access.ServiceAccountValidator{Client: mgr.GetClient()}})
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	if err := mgr.AddReadyzCheck("readyz", serviceResourceMapReconciler.ReadyCheck); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
@@ -113,3 +224,30 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newShardingOptions returns the sharding options of the replica, identified
+// by its hostname, i.e. its pod name
+func newShardingOptions(sh configv1alpha1.ShardingSpec) (*sharding.Options, error) {
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := sh.LeaseNamespace
+	if namespace == "" {
+		b, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("serviceMapper.sharding.leaseNamespace is required out of a cluster: %w", err)
+		}
+		namespace = strings.TrimSpace(string(b))
+	}
+
+	return &sharding.Options{
+		Identity:      identity,
+		Namespace:     namespace,
+		Key:           sh.Key,
+		LeaseDuration: sh.LeaseDuration.Duration,
+		RenewPeriod:   sh.RenewPeriod.Duration,
+		VirtualNodes:  sh.VirtualNodes,
+	}, nil
+}
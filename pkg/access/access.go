@@ -0,0 +1,165 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reviewer checks with SubjectAccessReviews whether the ServiceAccounts of
+// the tenants may read objects, caching the decisions for a while so that
+// rendering the SEDs of many instances does not review the same access again
+type Reviewer struct {
+	client client.Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	decisions map[request]decision
+}
+
+type request struct {
+	user, verb, group, resource, namespace, name string
+}
+
+type decision struct {
+	allowed bool
+	expiry  time.Time
+}
+
+// NewReviewer returns a Reviewer creating the SubjectAccessReviews with cli
+// and caching the decisions for ttl
+func NewReviewer(cli client.Client, ttl time.Duration) *Reviewer {
+	return &Reviewer{
+		client:    cli,
+		ttl:       ttl,
+		now:       time.Now,
+		decisions: map[request]decision{},
+	}
+}
+
+// CanGet returns true if the ServiceAccount sa of the namespace may get the
+// named object of the resource, e.g. `secrets`, in the namespace
+func (r *Reviewer) CanGet(ctx context.Context, namespace, sa string, gr schema.GroupResource, name string) (bool, error) {
+	return r.review(ctx, namespace, sa, "get", gr, name)
+}
+
+// CanWatch returns true if the ServiceAccount sa of the namespace may get,
+// list and watch the objects of the resource in the namespace
+func (r *Reviewer) CanWatch(ctx context.Context, namespace, sa string, gr schema.GroupResource) (bool, error) {
+	for _, verb := range []string{"get", "list", "watch"} {
+		allowed, err := r.review(ctx, namespace, sa, verb, gr, "")
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// review returns true if the ServiceAccount sa of the namespace may apply
+// the verb to the objects of the resource in the namespace, the named one
+// only if name is set
+func (r *Reviewer) review(ctx context.Context, namespace, sa, verb string, gr schema.GroupResource, name string) (bool, error) {
+	req := request{
+		user:      "system:serviceaccount:" + namespace + ":" + sa,
+		verb:      verb,
+		group:     gr.Group,
+		resource:  gr.Resource,
+		namespace: namespace,
+		name:      name,
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	d, ok := r.decisions[req]
+	r.mu.Unlock()
+	if ok && now.Before(d.expiry) {
+		return d.allowed, nil
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   req.user,
+			Groups: []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     gr.Group,
+				Resource:  gr.Resource,
+				Name:      name,
+			},
+		},
+	}
+	if err := r.client.Create(ctx, sar); err != nil {
+		return false, fmt.Errorf("error reviewing the access of '%s' to %s %s '%s/%s': %w", req.user, verb, gr, namespace, name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, d := range r.decisions {
+		if !now.Before(d.expiry) {
+			delete(r.decisions, k)
+		}
+	}
+	r.decisions[req] = decision{allowed: sar.Status.Allowed, expiry: now.Add(r.ttl)}
+	return sar.Status.Allowed, nil
+}
+
+// certificateGK is the kind of the cert-manager Certificates
+var certificateGK = schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"}
+
+// Client returns a client getting the Secrets, ConfigMaps, Services and
+// cert-manager Certificates of the namespace on behalf of the ServiceAccount
+// sa of the namespace: getting them fails with a Forbidden error unless sa
+// may get them, and getting the ones of other namespaces always fails. The
+// other objects are read with cli.
+func (r *Reviewer) Client(cli client.Client, namespace, sa string) client.Client {
+	return &reviewedClient{Client: cli, reviewer: r, namespace: namespace, sa: sa}
+}
+
+type reviewedClient struct {
+	client.Client
+	reviewer  *Reviewer
+	namespace string
+	sa        string
+}
+
+func (c *reviewedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	var gr schema.GroupResource
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		gr = corev1.Resource("secrets")
+	case *corev1.ConfigMap:
+		gr = corev1.Resource("configmaps")
+	case *corev1.Service:
+		gr = corev1.Resource("services")
+	case *unstructured.Unstructured:
+		if o.GroupVersionKind().GroupKind() != certificateGK {
+			return c.Client.Get(ctx, key, obj)
+		}
+		gr = schema.GroupResource{Group: certificateGK.Group, Resource: "certificates"}
+	default:
+		return c.Client.Get(ctx, key, obj)
+	}
+
+	if key.Namespace != c.namespace {
+		return apierrors.NewForbidden(gr, key.Name, fmt.Errorf("namespace '%s' is not readable from namespace '%s'", key.Namespace, c.namespace))
+	}
+
+	allowed, err := c.reviewer.CanGet(ctx, c.namespace, c.sa, gr, key.Name)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apierrors.NewForbidden(gr, key.Name, fmt.Errorf("ServiceAccount '%s' can not get it", c.sa))
+	}
+	return c.Client.Get(ctx, key, obj)
+}
@@ -0,0 +1,139 @@
+package access
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeAuthorizer allows the users to get the objects listed in allowed, and
+// gets every object, any other call panics
+type fakeAuthorizer struct {
+	client.Client
+
+	// allowed are the `user verb group/resource namespace/name` allowed
+	allowed map[string]bool
+	reviews int
+	gets    int
+}
+
+func (f *fakeAuthorizer) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	sar := obj.(*authorizationv1.SubjectAccessReview)
+	a := sar.Spec.ResourceAttributes
+	f.reviews++
+	sar.Status.Allowed = f.allowed[sar.Spec.User+" "+a.Verb+" "+a.Group+"/"+a.Resource+" "+a.Namespace+"/"+a.Name]
+	return nil
+}
+
+func (f *fakeAuthorizer) Get(context.Context, client.ObjectKey, client.Object) error {
+	f.gets++
+	return nil
+}
+
+func TestReviewedClient(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cli := &fakeAuthorizer{allowed: map[string]bool{
+		"system:serviceaccount:tenant:default get /secrets tenant/db-credentials":            true,
+		"system:serviceaccount:tenant:binder get /configmaps tenant/db-config":               true,
+		"system:serviceaccount:tenant:binder get /services tenant/db":                        true,
+		"system:serviceaccount:tenant:binder get cert-manager.io/certificates tenant/db-tls": true,
+	}}
+	reviewer := NewReviewer(cli, time.Minute)
+	now := time.Now()
+	reviewer.now = func() time.Time { return now }
+
+	get := func(c client.Client, obj client.Object, namespace, name string) error {
+		return c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, obj)
+	}
+
+	// objects the ServiceAccount may get are read
+	c := reviewer.Client(cli, "tenant", "default")
+	g.Expect(get(c, &corev1.Secret{}, "tenant", "db-credentials")).To(Succeed())
+	g.Expect(cli.gets).To(Equal(1))
+
+	// the others are forbidden, as are the objects of other namespaces
+	err := get(c, &corev1.ConfigMap{}, "tenant", "db-config")
+	g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "%v", err)
+	err = get(c, &corev1.Secret{}, "other", "db-credentials")
+	g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "%v", err)
+	g.Expect(cli.gets).To(Equal(1))
+	g.Expect(cli.reviews).To(Equal(2))
+
+	g.Expect(get(reviewer.Client(cli, "tenant", "binder"), &corev1.ConfigMap{}, "tenant", "db-config")).To(Succeed())
+
+	// as are the Services and cert-manager Certificates holding certificates
+	certificate := func() *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"})
+		return u
+	}
+	err = get(c, &corev1.Service{}, "tenant", "db")
+	g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "%v", err)
+	err = get(c, certificate(), "tenant", "db-tls")
+	g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "%v", err)
+	g.Expect(get(reviewer.Client(cli, "tenant", "binder"), &corev1.Service{}, "tenant", "db")).To(Succeed())
+	g.Expect(get(reviewer.Client(cli, "tenant", "binder"), certificate(), "tenant", "db-tls")).To(Succeed())
+	g.Expect(cli.reviews).To(Equal(7))
+
+	// the other objects are not reviewed
+	database := &unstructured.Unstructured{}
+	database.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Database"})
+	g.Expect(get(c, database, "other", "db")).To(Succeed())
+	g.Expect(cli.reviews).To(Equal(7))
+
+	// decisions are cached until they expire
+	g.Expect(get(c, &corev1.Secret{}, "tenant", "db-credentials")).To(Succeed())
+	g.Expect(cli.reviews).To(Equal(7))
+
+	now = now.Add(time.Minute)
+	delete(cli.allowed, "system:serviceaccount:tenant:default get /secrets tenant/db-credentials")
+	err = get(c, &corev1.Secret{}, "tenant", "db-credentials")
+	g.Expect(apierrors.IsForbidden(err)).To(BeTrue(), "%v", err)
+	g.Expect(cli.reviews).To(Equal(8))
+}
+
+func TestCanWatch(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cli := &fakeAuthorizer{allowed: map[string]bool{
+		"system:serviceaccount:tenant:binder get example.com/databases tenant/":   true,
+		"system:serviceaccount:tenant:binder list example.com/databases tenant/":  true,
+		"system:serviceaccount:tenant:binder watch example.com/databases tenant/": true,
+		"system:serviceaccount:tenant:default get example.com/databases tenant/":  true,
+	}}
+	reviewer := NewReviewer(cli, time.Minute)
+	gr := schema.GroupResource{Group: "example.com", Resource: "databases"}
+
+	allowed, err := reviewer.CanWatch(ctx, "tenant", "binder", gr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowed).To(BeTrue())
+	g.Expect(cli.reviews).To(Equal(3))
+
+	// every verb is required
+	allowed, err = reviewer.CanWatch(ctx, "tenant", "default", gr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowed).To(BeFalse())
+	g.Expect(cli.reviews).To(Equal(5))
+
+	// the resources of other groups are reviewed apart
+	allowed, err = reviewer.CanWatch(ctx, "tenant", "binder", schema.GroupResource{Group: "other.com", Resource: "databases"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowed).To(BeFalse())
+
+	// decisions are cached
+	allowed, err = reviewer.CanWatch(ctx, "tenant", "binder", gr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowed).To(BeTrue())
+	g.Expect(cli.reviews).To(Equal(6))
+}
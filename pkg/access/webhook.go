@@ -0,0 +1,87 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+// ValidateNamespacedServiceResourceMapPath is the path the
+// ServiceAccountValidator is served at
+const ValidateNamespacedServiceResourceMapPath = "/validate-binding-operators-coreos-com-v1alpha1-namespacedserviceresourcemap"
+
+//+kubebuilder:webhook:path=/validate-binding-operators-coreos-com-v1alpha1-namespacedserviceresourcemap,mutating=false,failurePolicy=fail,sideEffects=None,groups=binding.operators.coreos.com,resources=namespacedserviceresourcemaps,verbs=create;update,versions=v1alpha1,name=vnamespacedserviceresourcemap.kb.io,admissionReviewVersions=v1
+
+// ServiceAccountValidator admits the NamespacedServiceResourceMaps whose
+// spec is set by users who may act as their ServiceAccount, i.e. impersonate
+// it: the operator watches the instances and reads the referenced objects on
+// behalf of the ServiceAccount, which must not grant more than the user has.
+type ServiceAccountValidator struct {
+	Client client.Client
+
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &ServiceAccountValidator{}
+
+// InjectDecoder injects the decoder of the admission requests
+func (v *ServiceAccountValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle admits the creations and the updates of NamespacedServiceResourceMaps
+func (v *ServiceAccountValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var m bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap
+	if err := v.decoder.Decode(req, &m); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// the updates leaving the spec unchanged, e.g. of the labels, act as
+	// the ServiceAccount no more than before
+	if req.Operation == admissionv1.Update {
+		var past bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap
+		if err := v.decoder.DecodeRaw(req.OldObject, &past); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(past.Spec, m.Spec) {
+			return admission.Allowed("")
+		}
+	}
+
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, e := range req.UserInfo.Extra {
+		extra[k] = authorizationv1.ExtraValue(e)
+	}
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   req.UserInfo.Username,
+			UID:    req.UserInfo.UID,
+			Groups: req.UserInfo.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: req.Namespace,
+				Verb:      "impersonate",
+				Resource:  "serviceaccounts",
+				Name:      m.ServiceAccount(),
+			},
+		},
+	}
+	if err := v.Client.Create(ctx, sar); err != nil {
+		return admission.Errored(http.StatusInternalServerError,
+			fmt.Errorf("error reviewing the access of '%s' to ServiceAccount '%s': %w", req.UserInfo.Username, m.ServiceAccount(), err))
+	}
+	if !sar.Status.Allowed {
+		return admission.Denied(fmt.Sprintf("'%s' can not impersonate ServiceAccount '%s', set service_account_name to a ServiceAccount it can act as",
+			req.UserInfo.Username, m.ServiceAccount()))
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,66 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+func TestServiceAccountValidator(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(bindingoperatorscoreoscomv1alpha1.AddToScheme(scheme)).To(Succeed())
+	decoder, err := admission.NewDecoder(scheme)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cli := &fakeAuthorizer{allowed: map[string]bool{
+		"alice impersonate /serviceaccounts tenant/binder": true,
+	}}
+	v := &ServiceAccountValidator{Client: cli}
+	g.Expect(v.InjectDecoder(decoder)).To(Succeed())
+
+	nsrm := func(sa string, labels map[string]string) runtime.RawExtension {
+		m := &bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "db", Labels: labels},
+			Spec:       bindingoperatorscoreoscomv1alpha1.NamespacedServiceResourceMapSpec{ServiceAccountName: sa},
+		}
+		b, err := json.Marshal(m)
+		g.Expect(err).NotTo(HaveOccurred())
+		return runtime.RawExtension{Raw: b}
+	}
+	handle := func(user string, op admissionv1.Operation, past, future runtime.RawExtension) admission.Response {
+		return v.Handle(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: op,
+			Namespace: "tenant",
+			UserInfo:  authenticationv1.UserInfo{Username: user},
+			OldObject: past,
+			Object:    future,
+		}})
+	}
+
+	// users may create maps acting as the ServiceAccounts they can impersonate
+	g.Expect(handle("alice", admissionv1.Create, runtime.RawExtension{}, nsrm("binder", nil)).Allowed).To(BeTrue())
+	g.Expect(handle("bob", admissionv1.Create, runtime.RawExtension{}, nsrm("binder", nil)).Allowed).To(BeFalse())
+	g.Expect(handle("alice", admissionv1.Create, runtime.RawExtension{}, nsrm("", nil)).Allowed).To(BeFalse())
+	g.Expect(cli.reviews).To(Equal(3))
+
+	// and change their spec only if they can impersonate the ServiceAccount
+	g.Expect(handle("bob", admissionv1.Update, nsrm("binder", nil), nsrm("", nil)).Allowed).To(BeFalse())
+	g.Expect(handle("alice", admissionv1.Update, nsrm("", nil), nsrm("binder", nil)).Allowed).To(BeTrue())
+	g.Expect(cli.reviews).To(Equal(5))
+
+	// the other updates are not reviewed
+	g.Expect(handle("bob", admissionv1.Update, nsrm("binder", nil), nsrm("binder", map[string]string{"team": "a"})).Allowed).To(BeTrue())
+	g.Expect(cli.reviews).To(Equal(5))
+}
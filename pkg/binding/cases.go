@@ -0,0 +1,142 @@
+package binding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+// Condition operators
+const (
+	OperatorEquals    = "=="
+	OperatorNotEquals = "!="
+)
+
+// Condition is a parsed `when` condition of a case:
+//
+//	<jsonpath>
+//	<jsonpath> == <value>
+//	<jsonpath> != <value>
+//
+// The JSONPath may omit its braces, e.g. `.spec.engine`. Values are double
+// quoted strings or bare words.
+type Condition struct {
+	// Path is the JSONPath template of the compared field
+	Path string
+	// Operator is empty for conditions requiring the field to be set
+	Operator string
+	// Value is the value the field is compared to
+	Value string
+}
+
+// ParseCondition parses a `when` condition
+func ParseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+
+	// the path ends with its closing brace, or before the first space or
+	// operator when braces are omitted
+	var path, rest string
+	if strings.HasPrefix(s, "{") {
+		depth := 0
+		for i, c := range s {
+			if c == '{' {
+				depth++
+			} else if c == '}' {
+				depth--
+			}
+			if depth == 0 {
+				path, rest = s[:i+1], s[i+1:]
+				break
+			}
+		}
+		if path == "" {
+			return Condition{}, fmt.Errorf("unbalanced braces in condition '%s'", s)
+		}
+	} else {
+		path, rest = s, ""
+		if i := strings.IndexAny(s, " =!"); i >= 0 {
+			path, rest = s[:i], s[i:]
+		}
+		path = "{" + path + "}"
+	}
+	if _, err := jsonpath.Parse("", path); err != nil {
+		return Condition{}, fmt.Errorf("invalid jsonpath in condition '%s': %v", s, err)
+	}
+
+	c := Condition{Path: path}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return c, nil
+	}
+
+	switch {
+	case strings.HasPrefix(rest, OperatorEquals):
+		c.Operator = OperatorEquals
+	case strings.HasPrefix(rest, OperatorNotEquals):
+		c.Operator = OperatorNotEquals
+	default:
+		return Condition{}, fmt.Errorf("unsupported operator in condition '%s', expected == or !=", s)
+	}
+
+	v := strings.TrimSpace(rest[len(c.Operator):])
+	switch {
+	case v == "":
+		return Condition{}, fmt.Errorf("missing value in condition '%s'", s)
+	case strings.HasPrefix(v, `"`):
+		u, err := strconv.Unquote(v)
+		if err != nil {
+			return Condition{}, fmt.Errorf("invalid value in condition '%s': %v", s, err)
+		}
+		c.Value = u
+	case strings.ContainsAny(v, " \t"):
+		return Condition{}, fmt.Errorf("invalid value in condition '%s', quote values with spaces", s)
+	default:
+		c.Value = v
+	}
+	return c, nil
+}
+
+// Matches returns true if the instance matches the condition. Missing fields
+// are empty.
+func (c Condition) Matches(obj interface{}) bool {
+	v, err := executeJsonpath(c.Path, obj)
+	if err != nil {
+		v = ""
+	}
+
+	switch c.Operator {
+	case OperatorEquals:
+		return v == c.Value
+	case OperatorNotEquals:
+		return v != c.Value
+	}
+	return v != "" && v != "false"
+}
+
+// ValidateCases returns an error if the condition of a case of the
+// ServiceResourceMap is invalid
+func ValidateCases(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) error {
+	for i, c := range sm.Spec.Cases {
+		if _, err := ParseCondition(c.When); err != nil {
+			return fmt.Errorf("invalid case %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Cases returns the indexes of the cases of the ServiceResourceMap matched
+// by the instance. Cases with invalid conditions never match.
+func Cases(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, obj interface{}) []int {
+	var matched []int
+	for i, c := range sm.Spec.Cases {
+		cond, err := ParseCondition(c.When)
+		if err == nil && cond.Matches(obj) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,98 @@
+package binding
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		when    string
+		want    Condition
+		wantErr bool
+	}{
+		{when: ".spec.engine", want: Condition{Path: "{.spec.engine}"}},
+		{when: `.spec.engine == "mysql"`, want: Condition{Path: "{.spec.engine}", Operator: OperatorEquals, Value: "mysql"}},
+		{when: ".spec.engine != mysql", want: Condition{Path: "{.spec.engine}", Operator: OperatorNotEquals, Value: "mysql"}},
+		{when: ` .spec.port=="5432" `, want: Condition{Path: "{.spec.port}", Operator: OperatorEquals, Value: "5432"}},
+		{
+			when: `{.status.conditions[?(@.type=="Ready")].status} == "True"`,
+			want: Condition{Path: `{.status.conditions[?(@.type=="Ready")].status}`, Operator: OperatorEquals, Value: "True"},
+		},
+		{when: `.spec.engine == "my sql"`, want: Condition{Path: "{.spec.engine}", Operator: OperatorEquals, Value: "my sql"}},
+		{when: ".spec.engine == my sql", wantErr: true},
+		{when: ".spec.engine ~= mysql", wantErr: true},
+		{when: ".spec.engine ==", wantErr: true},
+		{when: `.spec.engine == "mysql`, wantErr: true},
+		{when: "{.spec.engine", wantErr: true},
+		{when: ".spec[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c, err := ParseCondition(tt.when)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(c).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{
+		"type": "postgresql",
+		"host": "path={.status.endpoint.address}",
+		"port": "5432",
+	})
+	sm.Spec.Cases = []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapCase{
+		{When: `.spec.engine == "mysql"`, ServiceMap: map[string]string{"type": "mysql", "port": "3306"}},
+		{When: `.spec.engine == "mariadb"`, ServiceMap: map[string]string{"type": "mysql", "port": "3306", "flavor": "mariadb"}},
+		{When: ".spec.tls", ServiceMap: map[string]string{"sslmode": "path={.spec.tls.mode}"}},
+	}
+	g.Expect(ValidateCases(sm)).To(Succeed())
+
+	obj := instance()
+	rules := func() map[string]string { return RulesFor(sm, Outputs(sm)[0], obj) }
+	g.Expect(rules()).To(Equal(sm.Spec.ServiceMap))
+
+	spec := obj["spec"].(map[string]interface{})
+	spec["engine"] = "mysql"
+	g.Expect(Cases(sm, obj)).To(Equal([]int{0}))
+	g.Expect(rules()).To(Equal(map[string]string{
+		"type": "mysql",
+		"host": "path={.status.endpoint.address}",
+		"port": "3306",
+	}))
+
+	// the values change with the matched cases, even if their rules are
+	// literal
+	vs := Extract(sm, obj)
+	spec["engine"] = "mariadb"
+	g.Expect(Extract(sm, obj)).NotTo(Equal(vs))
+	g.Expect(rules()).To(HaveKeyWithValue("flavor", "mariadb"))
+
+	spec["tls"] = map[string]interface{}{"mode": "require"}
+	g.Expect(Cases(sm, obj)).To(Equal([]int{1, 2}))
+
+	// the fields read by the conditions and the cases are kept
+	g.Expect(Fields(sm)).To(ConsistOf(
+		[]string{"status", "endpoint", "address"},
+		[]string{"spec", "engine"},
+		[]string{"spec", "engine"},
+		[]string{"spec", "tls"},
+		[]string{"spec", "tls", "mode"},
+	))
+
+	sm.Spec.Cases = append(sm.Spec.Cases, bindingoperatorscoreoscomv1alpha1.ServiceResourceMapCase{When: ".spec.engine ~= mysql"})
+	g.Expect(ValidateCases(sm)).To(MatchError(ContainSubstring("invalid case 3")))
+}
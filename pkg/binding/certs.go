@@ -0,0 +1,148 @@
+package binding
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift-app-service-poc/service-mapper/pkg/metrics"
+)
+
+// Object types of the rules sourcing certificates and CA bundles
+const (
+	// ObjectTypeServiceCA is a ConfigMap the service CA operator injects its
+	// CA bundle in, e.g. `openshift-service-ca.crt`
+	ObjectTypeServiceCA = "ServiceCA"
+	// ObjectTypeCertificate is a cert-manager Certificate, whose Secret is read
+	ObjectTypeCertificate = "Certificate"
+	// ObjectTypeService is a Service, whose serving certificate Secret is read
+	ObjectTypeService = "Service"
+)
+
+const (
+	// ServiceCAKey is the entry of the CA bundle injected by the service CA operator
+	ServiceCAKey = "service-ca.crt"
+	// AnnotationServingCertSecretName names the Secret the service CA
+	// operator issues the serving certificate of a Service in
+	AnnotationServingCertSecretName = "service.beta.openshift.io/serving-cert-secret-name"
+	// AnnotationServingCertSecretNameAlpha is the deprecated AnnotationServingCertSecretName
+	AnnotationServingCertSecretNameAlpha = "service.alpha.openshift.io/serving-cert-secret-name"
+
+	// ValidatePEM is the `validate` option requiring the values of a rule
+	// to be PEM encoded
+	ValidatePEM = "pem"
+)
+
+// CertificateGVK is the kind of the cert-manager Certificates
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+var (
+	errNoCertificateSecret = errors.New("no certificate Secret")
+	errInvalidPEM          = errors.New("invalid PEM")
+)
+
+// certificateSecretName returns the name of the Secret holding the
+// certificate of the Certificate or Service named name
+func certificateSecretName(ctx context.Context, cli client.Client, objectType, namespace, name string) (string, error) {
+	key := client.ObjectKey{Namespace: namespace, Name: name}
+	switch objectType {
+	case ObjectTypeCertificate:
+		c := &unstructured.Unstructured{}
+		c.SetGroupVersionKind(CertificateGVK)
+		err := cli.Get(ctx, key, c)
+		metrics.ReferenceLookups.WithLabelValues(objectType, metrics.Result(err)).Inc()
+		if err != nil {
+			return "", fmt.Errorf("can not retrieve Certificate '%s/%s': %w", namespace, name, err)
+		}
+
+		s, _, _ := unstructured.NestedString(c.Object, "spec", "secretName")
+		if s == "" {
+			return "", fmt.Errorf("%w: Certificate '%s/%s' has no spec.secretName", errNoCertificateSecret, namespace, name)
+		}
+		return s, nil
+	case ObjectTypeService:
+		svc := &corev1.Service{}
+		err := cli.Get(ctx, key, svc)
+		metrics.ReferenceLookups.WithLabelValues(objectType, metrics.Result(err)).Inc()
+		if err != nil {
+			return "", fmt.Errorf("can not retrieve Service '%s/%s': %w", namespace, name, err)
+		}
+
+		for _, a := range []string{AnnotationServingCertSecretName, AnnotationServingCertSecretNameAlpha} {
+			if s := svc.Annotations[a]; s != "" {
+				return s, nil
+			}
+		}
+		return "", fmt.Errorf("%w: Service '%s/%s' has no '%s' annotation", errNoCertificateSecret, namespace, name, AnnotationServingCertSecretName)
+	}
+	return "", fmt.Errorf("%w: invalid objectType: %s", errInvalidRule, objectType)
+}
+
+// parsePEM decodes the PEM blocks of v, which must hold at least one block
+// and nothing else but whitespaces, and returns the certificates among them.
+// Errors do not disclose the value.
+func parsePEM(v []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	n := 0
+	for rest := v; ; n++ {
+		var b *pem.Block
+		if b, rest = pem.Decode(rest); b == nil {
+			if len(bytes.TrimSpace(rest)) != 0 {
+				return nil, fmt.Errorf("data after block %d is not PEM encoded", n)
+			}
+			break
+		}
+
+		if b.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("block %d is not a valid certificate", n)
+		}
+		certs = append(certs, c)
+	}
+
+	if n == 0 {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	return certs, nil
+}
+
+// validatePEM returns an error if a value is not PEM encoded
+func validatePEM(vs Values) error {
+	for _, k := range vs.Keys() {
+		if _, err := parsePEM(vs[k]); err != nil {
+			return fmt.Errorf("%w: entry '%s': %v", errInvalidPEM, k, err)
+		}
+	}
+	return nil
+}
+
+// NotAfter returns the earliest expiry of the PEM encoded certificates found
+// in the entries of a Service Endpoint Definition, false if there are none
+func NotAfter(data map[string][]byte) (time.Time, bool) {
+	var t time.Time
+	for _, v := range data {
+		if !bytes.Contains(v, []byte("-----BEGIN CERTIFICATE-----")) {
+			continue
+		}
+
+		certs, _ := parsePEM(v)
+		for _, c := range certs {
+			if t.IsZero() || c.NotAfter.Before(t) {
+				t = c.NotAfter
+			}
+		}
+	}
+	return t, !t.IsZero()
+}
@@ -0,0 +1,155 @@
+package binding
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newCertificate returns a PEM encoded self-signed certificate expiring at notAfter
+func newCertificate(t *testing.T, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "db.ns.svc"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestParsePEM(t *testing.T) {
+	g := NewWithT(t)
+
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	crt := newCertificate(t, notAfter)
+	key := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{0x30, 0x82}})
+
+	certs, err := parsePEM(append(append(crt, '\n'), crt...))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(certs).To(HaveLen(2))
+	g.Expect(certs[0].NotAfter).To(BeTemporally("==", notAfter))
+
+	certs, err = parsePEM(key)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(certs).To(BeEmpty())
+
+	for _, v := range [][]byte{
+		nil,
+		[]byte("s3cr3t"),
+		append(crt, []byte("s3cr3t")...),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("s3cr3t")}),
+	} {
+		_, err := parsePEM(v)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).NotTo(ContainSubstring("s3cr3t"))
+	}
+}
+
+func TestNotAfter(t *testing.T) {
+	g := NewWithT(t)
+
+	first := time.Now().Add(time.Hour).Truncate(time.Second)
+	ca := append(newCertificate(t, first.Add(time.Hour)), newCertificate(t, first)...)
+
+	at, ok := NotAfter(map[string][]byte{
+		"ca.crt":   ca,
+		"tls.crt":  newCertificate(t, first.Add(2*time.Hour)),
+		"password": []byte("s3cr3t"),
+	})
+	g.Expect(ok).To(BeTrue())
+	g.Expect(at).To(BeTemporally("==", first))
+
+	_, ok = NotAfter(map[string][]byte{"password": []byte("s3cr3t")})
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestNewServiceEndpointDefinitionCertificates(t *testing.T) {
+	g := NewWithT(t)
+
+	ca := newCertificate(t, time.Now().Add(time.Hour))
+	crt := newCertificate(t, time.Now().Add(2*time.Hour))
+	serving := newCertificate(t, time.Now().Add(3*time.Hour))
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(CertificateGVK)
+	certificate.SetNamespace("ns")
+	certificate.SetName("db-cert")
+	certificate.Object["spec"] = map[string]interface{}{"secretName": "db-cert-tls"}
+
+	cli := &fakeClient{objs: []client.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "openshift-service-ca.crt", Namespace: "ns"},
+			Data:       map[string]string{ServiceCAKey: string(ca)},
+		},
+		certificate,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-cert-tls", Namespace: "ns"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{"ca.crt": ca, corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: []byte("key")},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "db",
+				Namespace:   "ns",
+				Annotations: map[string]string{AnnotationServingCertSecretNameAlpha: "db-serving-cert"},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-serving-cert", Namespace: "ns"},
+			Data:       map[string][]byte{corev1.TLSCertKey: serving},
+		},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "db-headless", Namespace: "ns"}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "ns"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		},
+	}}
+
+	obj := instance()
+	obj["spec"].(map[string]interface{})["certificate"] = "db-cert"
+	sm := newServiceResourceMap(map[string]string{
+		"ca":        "path=openshift-service-ca.crt,objectType=ServiceCA,validate=pem",
+		UnnamedKey:  "path={.spec.certificate},objectType=Certificate",
+		"serving":   "path={.metadata.name},objectType=Service,sourceKey=tls.crt,validate=pem",
+		"headless":  "path={.metadata.name}-headless,objectType=Service",
+		"password":  "path={.spec.secret},objectType=Secret,sourceKey=password,validate=pem",
+		"missingca": "path=missing,objectType=ServiceCA",
+	})
+
+	sed, failures := NewServiceEndpointDefinition(context.Background(), cli, sm, newServiceProxy(), Outputs(sm)[0], obj)
+	g.Expect(sed.Data).To(HaveKeyWithValue("ca", ca))
+	g.Expect(sed.Data).To(HaveKeyWithValue("ca.crt", ca))
+	g.Expect(sed.Data).To(HaveKeyWithValue(corev1.TLSCertKey, crt))
+	g.Expect(sed.Data).To(HaveKeyWithValue(corev1.TLSPrivateKeyKey, []byte("key")))
+	g.Expect(sed.Data).To(HaveKeyWithValue("serving", serving))
+	g.Expect(sed.Data).NotTo(HaveKey("password"))
+
+	g.Expect(failures).To(HaveLen(3))
+	g.Expect(failures[0].Key).To(Equal("headless"))
+	g.Expect(failures[0].Reason()).To(Equal("NoCertificateSecret"))
+	g.Expect(failures[1].Key).To(Equal("missingca"))
+	g.Expect(failures[1].Reason()).To(Equal("ReferenceNotFound"))
+	g.Expect(failures[2].Key).To(Equal("password"))
+	g.Expect(failures[2].Reason()).To(Equal("InvalidPEM"))
+	g.Expect(failures[2].Error()).NotTo(ContainSubstring("s3cr3t"))
+}
@@ -0,0 +1,248 @@
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var errCompose = errors.New("compose failed")
+
+// Composer builds a connection string from the values resolved by the other
+// rules of the Service Endpoint Definition. Errors must not disclose the
+// values.
+type Composer func(in composerInput) (string, error)
+
+// composers is the registry of the composers, by name
+var composers = map[string]Composer{
+	"postgresql-uri":  postgresqlURI,
+	"postgresql-jdbc": postgresqlJDBC,
+	"mysql-uri":       mysqlURI,
+	"mysql-jdbc":      mysqlJDBC,
+	"mongodb-uri":     mongodbURI,
+	"redis-uri":       redisURI,
+	"amqp-uri":        amqpURI,
+	"kafka-bootstrap": kafkaBootstrap,
+}
+
+// composerInput holds the values of the other rules, read by their
+// well-known keys
+type composerInput map[string]string
+
+// get returns the value of the first key set
+func (in composerInput) get(keys ...string) string {
+	for _, k := range keys {
+		if v := in[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (in composerInput) host() (string, error) {
+	h := in.get("host")
+	if h == "" {
+		return "", fmt.Errorf("missing key 'host'")
+	}
+	return h, nil
+}
+
+func (in composerInput) username() string {
+	return in.get("username", "user")
+}
+
+func (in composerInput) database() string {
+	return in.get("database", "db", "dbname")
+}
+
+func (in composerInput) enabled(key string) bool {
+	return strings.EqualFold(in[key], "true")
+}
+
+// userinfo returns the credentials of the URI, nil if none
+func (in composerInput) userinfo() *url.Userinfo {
+	u, p := in.username(), in.get("password")
+	switch {
+	case p != "":
+		return url.UserPassword(u, p)
+	case u != "":
+		return url.User(u)
+	}
+	return nil
+}
+
+// hostPort joins the host and the port key, unless the host has a port
+func hostPort(host, port string) string {
+	if port == "" {
+		return host
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+// hosts returns the comma separated hosts, each with the port key unless it
+// has its own port
+func (in composerInput) hosts() ([]string, error) {
+	h, err := in.host()
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, h := range strings.Split(h, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, hostPort(h, in["port"]))
+		}
+	}
+	return hosts, nil
+}
+
+// uri builds a URI whose path is the escaped segment, if any. The path
+// separator is kept before the query even without segment.
+func uri(scheme string, user *url.Userinfo, host, segment string, query url.Values) string {
+	u := url.URL{Scheme: scheme, User: user, Host: host}
+	switch {
+	case segment != "":
+		u.Path = "/" + segment
+		u.RawPath = "/" + url.PathEscape(segment)
+	case len(query) > 0:
+		u.Path = "/"
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// query returns the parameters set among the keys
+func (in composerInput) query(keys ...string) url.Values {
+	q := url.Values{}
+	for _, k := range keys {
+		if v := in[k]; v != "" {
+			q.Set(k, v)
+		}
+	}
+	return q
+}
+
+func postgresqlURI(in composerInput) (string, error) {
+	h, err := in.host()
+	if err != nil {
+		return "", err
+	}
+	return uri("postgresql", in.userinfo(), hostPort(h, in["port"]), in.database(), in.query("sslmode")), nil
+}
+
+func postgresqlJDBC(in composerInput) (string, error) {
+	h, err := in.host()
+	if err != nil {
+		return "", err
+	}
+	q := in.query("password", "sslmode")
+	if u := in.username(); u != "" {
+		q.Set("user", u)
+	}
+	return "jdbc:" + uri("postgresql", nil, hostPort(h, in["port"]), in.database(), q), nil
+}
+
+func mysqlURI(in composerInput) (string, error) {
+	h, err := in.host()
+	if err != nil {
+		return "", err
+	}
+	return uri("mysql", in.userinfo(), hostPort(h, in["port"]), in.database(), nil), nil
+}
+
+func mysqlJDBC(in composerInput) (string, error) {
+	h, err := in.host()
+	if err != nil {
+		return "", err
+	}
+	q := in.query("password")
+	if u := in.username(); u != "" {
+		q.Set("user", u)
+	}
+	return "jdbc:" + uri("mysql", nil, hostPort(h, in["port"]), in.database(), q), nil
+}
+
+// mongodbURI builds a `mongodb+srv://` URI if the `srv` key is true, a
+// `mongodb://` URI listing the comma separated hosts otherwise
+func mongodbURI(in composerInput) (string, error) {
+	q := in.query("replicaSet", "authSource")
+	if in.enabled("tls") {
+		q.Set("tls", "true")
+	}
+
+	if in.enabled("srv") {
+		h, err := in.host()
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(h, ",") || strings.Contains(h, ":") {
+			return "", fmt.Errorf("SRV URIs take a single host without port")
+		}
+		return uri("mongodb+srv", in.userinfo(), h, in.database(), q), nil
+	}
+
+	hosts, err := in.hosts()
+	if err != nil {
+		return "", err
+	}
+	return uri("mongodb", in.userinfo(), strings.Join(hosts, ","), in.database(), q), nil
+}
+
+// redisURI builds a `rediss://` URI if the `tls` key is true
+func redisURI(in composerInput) (string, error) {
+	h, err := in.host()
+	if err != nil {
+		return "", err
+	}
+	scheme := "redis"
+	if in.enabled("tls") {
+		scheme = "rediss"
+	}
+	return uri(scheme, in.userinfo(), hostPort(h, in["port"]), in.database(), nil), nil
+}
+
+// amqpURI builds an `amqps://` URI if the `tls` key is true, with the
+// escaped `vhost` key as path
+func amqpURI(in composerInput) (string, error) {
+	h, err := in.host()
+	if err != nil {
+		return "", err
+	}
+	scheme := "amqp"
+	if in.enabled("tls") {
+		scheme = "amqps"
+	}
+	return uri(scheme, in.userinfo(), hostPort(h, in["port"]), in["vhost"], nil), nil
+}
+
+// kafkaBootstrap lists the comma separated brokers with their port
+func kafkaBootstrap(in composerInput) (string, error) {
+	hosts, err := in.hosts()
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(hosts, ","), nil
+}
+
+// compose runs the composer of the rule on the values of the other rules
+func compose(name string, vs Values) (Value, error) {
+	c, ok := composers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown composer '%s'", errInvalidRule, name)
+	}
+
+	in := make(composerInput, len(vs))
+	for k, v := range vs {
+		in[k] = v.Reveal()
+	}
+	s, err := c(in)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errCompose, name, err)
+	}
+	return Value(s), nil
+}
@@ -0,0 +1,152 @@
+package binding
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/client-go/util/jsonpath"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+// Fields returns the paths of the instance fields read by the rules of the
+// ServiceResourceMap, or nil if the rules may read any field. A path selects
+// the whole field, e.g. `{.status.conditions[0].message}` reads
+// `status.conditions`.
+//
+// The fields read by the conditions and the rules of the cases, and by the
+// readiness, are included. The maps allowing overrides read any field, as
+// the overriding rules of the instances are not known in advance.
+func Fields(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) [][]string {
+	if sm.Spec.AllowOverrides {
+		return nil
+	}
+
+	paths := []string{}
+	for _, o := range Outputs(sm) {
+		paths = append(paths, rulePaths(o.Rules)...)
+	}
+	for _, c := range sm.Spec.Cases {
+		if cond, err := ParseCondition(c.When); err == nil {
+			paths = append(paths, cond.Path)
+		}
+		paths = append(paths, rulePaths(c.ServiceMap)...)
+	}
+
+	fs := [][]string{}
+	if rd := sm.Spec.Readiness; rd != nil {
+		if rd.ConditionType != "" {
+			fs = append(fs, []string{"status", "conditions"})
+		}
+		if cond, err := ParseCondition(rd.When); err == nil {
+			paths = append(paths, cond.Path)
+		}
+	}
+	for _, p := range paths {
+		f, ok := jsonpathFields(p)
+		if !ok {
+			return nil
+		}
+		fs = append(fs, f...)
+	}
+	return fs
+}
+
+// rulePaths returns the JSONPath templates read from the instance by the rules
+func rulePaths(rules map[string]string) []string {
+	var paths []string
+	for _, v := range rules {
+		// invalid rules fail whatever fields the instance has
+		r, err := ParseRule(v)
+		if err != nil {
+			continue
+		}
+		if r.Path != "" {
+			paths = append(paths, r.Path)
+		}
+		if p := r.SourceKeyPath(); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// jsonpathFields returns the leading fields of every expression of a
+// JSONPath template, false if an expression may read any field
+func jsonpathFields(template string) ([][]string, bool) {
+	p, err := jsonpath.Parse("", template)
+	if err != nil {
+		return nil, true
+	}
+
+	var fs [][]string
+	for _, n := range p.Root.Nodes {
+		l, ok := n.(*jsonpath.ListNode)
+		if !ok {
+			continue
+		}
+
+		var f []string
+	prefix:
+		for _, n := range l.Nodes {
+			switch n := n.(type) {
+			case *jsonpath.FieldNode:
+				if n.Value == "" {
+					break prefix
+				}
+				f = append(f, n.Value)
+			case *jsonpath.IdentifierNode, *jsonpath.RecursiveNode:
+				// range blocks and recursive descents read relative fields
+				return nil, false
+			default:
+				break prefix
+			}
+		}
+
+		if len(f) == 0 {
+			return nil, false
+		}
+		fs = append(fs, f)
+	}
+	return fs, true
+}
+
+// Extract returns the values read from the instance by the rules of every
+// output of the ServiceResourceMap, keyed by output and rule key. Instances
+// whose extracted values are equal render the same Service Endpoint
+// Definitions, as long as the objects they reference are unchanged. Rules
+// failing on the instance have no value. The overriding rules are extracted
+// too, keyed by their annotation, as are the conditions of the matched cases,
+// keyed by their index, and the readiness of the instance.
+func Extract(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, obj map[string]interface{}) Values {
+	vs := Values{}
+	if sm.Spec.Readiness != nil {
+		ready, _ := Ready(sm, obj)
+		vs["readiness"] = Value(strconv.FormatBool(ready))
+	}
+	for k, v := range Overrides(sm, obj) {
+		vs[AnnotationOverridePrefix+k] = Value(v)
+	}
+	for _, i := range Cases(sm, obj) {
+		vs[fmt.Sprintf("cases/%d", i)] = Value(sm.Spec.Cases[i].When)
+	}
+
+	for _, o := range Outputs(sm) {
+		for k, v := range RulesFor(sm, o, obj) {
+			r, err := ParseRule(v)
+			if err != nil || r.Path == "" {
+				continue
+			}
+
+			if s, err := executeJsonpath(r.Path, obj); err == nil {
+				vs[o.Name+"/"+k] = Value(s)
+			}
+			if p := r.SourceKeyPath(); p != "" {
+				if s, err := executeJsonpath(p, obj); err == nil {
+					vs[o.Name+"/"+k+"/sourceKey"] = Value(s)
+				}
+			}
+		}
+	}
+	return vs
+}
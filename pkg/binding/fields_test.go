@@ -0,0 +1,74 @@
+package binding
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+func TestFields(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{
+		"type":     "postgresql",
+		"host":     "path={.status.host}:{.spec.port}",
+		"password": "path={.spec.secret},objectType=Secret,sourceKey=password",
+		"token":    "path={.spec.secret},objectType=Secret,sourceKey={.spec.tokenKey}",
+		"ready":    `path={.status.conditions[?(@.type=="Ready")].status}`,
+		"invalid":  "path={.spec.secret},objectType=Pod",
+	})
+	sm.Spec.Outputs = []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{
+		{Name: "admin", ServiceMap: map[string]string{"username": "path={.spec.admin.name}"}},
+	}
+	g.Expect(Fields(sm)).To(ConsistOf(
+		[]string{"status", "host"},
+		[]string{"spec", "port"},
+		[]string{"spec", "secret"},
+		[]string{"spec", "secret"},
+		[]string{"spec", "tokenKey"},
+		[]string{"status", "conditions"},
+		[]string{"spec", "admin", "name"},
+	))
+
+	// literal rules read no field
+	g.Expect(Fields(newServiceResourceMap(map[string]string{"type": "postgresql"}))).To(BeEmpty())
+	g.Expect(Fields(newServiceResourceMap(map[string]string{"type": "postgresql"}))).NotTo(BeNil())
+
+	// expressions reading relative fields may read any field
+	for _, v := range []string{"path={.}", "path={..name}", "path={range .spec.users[*]}{.name}{end}", "path={.*.name}"} {
+		g.Expect(Fields(newServiceResourceMap(map[string]string{"v": v}))).To(BeNil(), v)
+	}
+
+	// as do the overriding rules, e.g. `path={.spec.customPort}`
+	sm.Spec.AllowOverrides = true
+	g.Expect(Fields(sm)).To(BeNil())
+}
+
+func TestExtract(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{
+		"type":     "postgresql",
+		"host":     "path={.status.host}",
+		"password": "path={.spec.secret},objectType=Secret,sourceKey=password",
+		"missing":  "path={.spec.missing}",
+	})
+	sm.Spec.Outputs = []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{
+		{Name: "admin", ServiceMap: map[string]string{"host": "path={.status.host}"}},
+	}
+
+	obj := instance()
+	obj["status"] = map[string]interface{}{"host": "db.example.com"}
+	vs := Extract(sm, obj)
+	g.Expect(vs.Keys()).To(Equal([]string{"/host", "/password", "admin/host"}))
+	g.Expect(vs["/password"].Reveal()).To(Equal("db-credentials"))
+
+	// fields not read by the rules don't change the extracted values
+	obj["status"].(map[string]interface{})["phase"] = "Ready"
+	g.Expect(Extract(sm, obj)).To(Equal(vs))
+
+	obj["status"].(map[string]interface{})["host"] = "other.example.com"
+	g.Expect(Extract(sm, obj)).NotTo(Equal(vs))
+}
@@ -0,0 +1,170 @@
+package binding
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+// InstancePlaceholder is replaced with the instance name in output names
+const InstancePlaceholder = "{instance}"
+
+// Output is a Service Endpoint Definition to generate for every instance
+type Output struct {
+	// Name of the output, empty for the default one
+	Name string
+	// SEDName is the name template of the Service Endpoint Definition
+	SEDName string
+	// ServiceProxy is true if the output is exposed by a dedicated ServiceProxy
+	ServiceProxy bool
+	// Rules are the service_map rules of the output
+	Rules map[string]string
+	// Env maps the output's keys to environment variable names
+	Env map[string]string
+}
+
+// Outputs returns the outputs declared by the ServiceResourceMap, starting
+// with the default one if the ServiceResourceMap has a service_map, cases or
+// extends templates
+func Outputs(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) []Output {
+	os := make([]Output, 0, len(sm.Spec.Outputs)+1)
+	if len(sm.Spec.ServiceMap) > 0 || len(sm.Spec.Cases) > 0 || len(sm.Spec.Extends) > 0 {
+		os = append(os, Output{
+			SEDName: sm.Spec.SEDName,
+			Rules:   sm.Spec.ServiceMap,
+			Env:     sm.Spec.Env,
+		})
+	}
+
+	for _, o := range sm.Spec.Outputs {
+		os = append(os, Output{
+			Name:         o.Name,
+			SEDName:      o.SEDName,
+			ServiceProxy: o.ServiceProxy,
+			Rules:        o.ServiceMap,
+			Env:          o.Env,
+		})
+	}
+	return os
+}
+
+// RulesFor returns the rules of the output for the instance. The rules of the
+// default output are merged, later rules replacing earlier ones, from
+//
+//  1. the service_map;
+//  2. the cases matched by the instance, in order;
+//  3. the overrides of the instance.
+//
+// The rules of the named outputs are the declared ones.
+func RulesFor(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, o Output, obj map[string]interface{}) map[string]string {
+	if o.Name != "" {
+		return o.Rules
+	}
+	cases := Cases(sm, obj)
+	overrides := Overrides(sm, obj)
+	if len(cases) == 0 && len(overrides) == 0 {
+		return o.Rules
+	}
+
+	rules := make(map[string]string, len(o.Rules)+len(overrides))
+	for k, v := range o.Rules {
+		rules[k] = v
+	}
+	for _, i := range cases {
+		for k, v := range sm.Spec.Cases[i].ServiceMap {
+			rules[k] = v
+		}
+	}
+	for k, v := range overrides {
+		rules[k] = v
+	}
+	return rules
+}
+
+// OutputFor returns the output with the given name, empty for the default one
+func OutputFor(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, name string) (Output, bool) {
+	for _, o := range Outputs(sm) {
+		if o.Name == name {
+			return o, true
+		}
+	}
+	return Output{}, false
+}
+
+// ValidateOutputs returns an error if an output of the ServiceResourceMap
+// has an invalid or duplicate name, or if its names do not depend on the
+// instance, as every instance would then write the same Secret
+func ValidateOutputs(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) error {
+	names, seds := map[string]struct{}{}, map[string]string{}
+	for _, o := range Outputs(sm) {
+		if o.Name != "" {
+			if errs := validation.IsDNS1123Label(o.Name); len(errs) != 0 {
+				return fmt.Errorf("invalid output name '%s': %s", o.Name, strings.Join(errs, ", "))
+			}
+			if _, ok := names[o.Name]; ok {
+				return fmt.Errorf("duplicate output '%s'", o.Name)
+			}
+			names[o.Name] = struct{}{}
+		}
+
+		if o.SEDName != "" && !strings.Contains(o.SEDName, InstancePlaceholder) {
+			return fmt.Errorf("invalid sed_name '%s' of %s: it must contain %s", o.SEDName, describe(o.Name), InstancePlaceholder)
+		}
+		// the names generated for a short instance name must be valid
+		if err := ValidateNames(o, "a"); err != nil {
+			return fmt.Errorf("invalid names of %s: %w", describe(o.Name), err)
+		}
+		sed := o.SecretName("a")
+		if other, ok := seds[sed]; ok {
+			return fmt.Errorf("%s and %s generate the same Secret", describe(other), describe(o.Name))
+		}
+		seds[sed] = o.Name
+	}
+	return nil
+}
+
+func describe(output string) string {
+	if output == "" {
+		return "the default output"
+	}
+	return fmt.Sprintf("output '%s'", output)
+}
+
+// ValidateNames returns an error if the name of the output's Service Endpoint
+// Definition or dedicated ServiceProxy for the instance is not a valid object
+// name, e.g. as the instance name is too long
+func ValidateNames(o Output, instance string) error {
+	names := []string{o.SecretName(instance)}
+	if o.ServiceProxy {
+		names = append(names, o.ServiceProxyName(instance))
+	}
+	for _, n := range names {
+		if errs := validation.IsDNS1123Subdomain(n); len(errs) != 0 {
+			return fmt.Errorf("invalid name '%s': %s", n, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// SecretName returns the name of the output's Service Endpoint Definition for the given instance
+func (o Output) SecretName(instance string) string {
+	if o.SEDName != "" {
+		return strings.ReplaceAll(o.SEDName, InstancePlaceholder, instance)
+	}
+
+	if o.Name == "" {
+		return instance + "-sed"
+	}
+	return instance + "-" + o.Name + "-sed"
+}
+
+// ServiceProxyName returns the name of the output's dedicated ServiceProxy for the given instance
+func (o Output) ServiceProxyName(instance string) string {
+	if o.Name == "" {
+		return instance
+	}
+	return instance + "-" + o.Name
+}
@@ -0,0 +1,68 @@
+package binding
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+func TestOutputs(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{"type": "postgresql"})
+	sm.Spec.Outputs = []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{
+		{Name: "admin", ServiceMap: map[string]string{"username": "admin"}},
+		{Name: "readonly", SEDName: "{instance}-ro-credentials", ServiceProxy: true, ServiceMap: map[string]string{"username": "reader"}},
+	}
+
+	os := Outputs(sm)
+	g.Expect(os).To(HaveLen(3))
+
+	g.Expect(os[0].Name).To(BeEmpty())
+	g.Expect(os[0].SecretName("db")).To(Equal("db-sed"))
+	g.Expect(os[0].ServiceProxyName("db")).To(Equal("db"))
+
+	g.Expect(os[1].SecretName("db")).To(Equal("db-admin-sed"))
+	g.Expect(os[1].Rules).To(Equal(map[string]string{"username": "admin"}))
+
+	g.Expect(os[2].SecretName("db")).To(Equal("db-ro-credentials"))
+	g.Expect(os[2].ServiceProxyName("db")).To(Equal("db-readonly"))
+	g.Expect(os[2].ServiceProxy).To(BeTrue())
+
+	sm.Spec.ServiceMap = nil
+	g.Expect(Outputs(sm)).To(HaveLen(2))
+}
+
+func TestValidateOutputs(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{"type": "postgresql"})
+	sm.Spec.Outputs = []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{
+		{Name: "admin", SEDName: "{instance}-admin"},
+		{Name: "readonly", ServiceProxy: true},
+	}
+	g.Expect(ValidateOutputs(sm)).To(Succeed())
+
+	for _, tc := range []struct {
+		output bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput
+		err    string
+	}{
+		{output: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{Name: "Read_Only"}, err: "invalid output name 'Read_Only'"},
+		{output: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{Name: "admin"}, err: "duplicate output 'admin'"},
+		{output: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{Name: "ro", SEDName: "shared"}, err: "it must contain {instance}"},
+		{output: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{Name: "ro", SEDName: "{instance}_ro"}, err: "invalid names of output 'ro'"},
+		{output: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{Name: "ro", SEDName: "{instance}-sed"}, err: "the default output and output 'ro' generate the same Secret"},
+	} {
+		sm := sm.DeepCopy()
+		sm.Spec.Outputs = append(sm.Spec.Outputs, tc.output)
+		g.Expect(ValidateOutputs(sm)).To(MatchError(ContainSubstring(tc.err)), tc.err)
+	}
+
+	// the names are checked again for every instance
+	o, _ := OutputFor(sm, "readonly")
+	g.Expect(ValidateNames(o, "db")).To(Succeed())
+	g.Expect(ValidateNames(o, strings.Repeat("db", 127))).To(MatchError(ContainSubstring("invalid name")))
+}
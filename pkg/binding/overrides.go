@@ -0,0 +1,37 @@
+package binding
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+// AnnotationOverridePrefix prefixes the annotations of the instances
+// overriding the service_map of their ServiceResourceMap:
+// `servicemapper.binding/override.<key>` holds a rule, in the service_map
+// grammar, replacing or adding the rule of the key
+const AnnotationOverridePrefix = "servicemapper.binding/override."
+
+// Overrides returns the rules set by the annotations of the instance, nil
+// unless the ServiceResourceMap allows overrides
+func Overrides(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, obj map[string]interface{}) map[string]string {
+	if !sm.Spec.AllowOverrides {
+		return nil
+	}
+
+	annotations, _, _ := unstructured.NestedStringMap(obj, "metadata", "annotations")
+	var rules map[string]string
+	for a, v := range annotations {
+		k := strings.TrimPrefix(a, AnnotationOverridePrefix)
+		if k == a || k == "" {
+			continue
+		}
+		if rules == nil {
+			rules = map[string]string{}
+		}
+		rules[k] = v
+	}
+	return rules
+}
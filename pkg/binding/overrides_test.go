@@ -0,0 +1,65 @@
+package binding
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+func TestOverrides(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{"type": "postgresql", "host": "path={.metadata.name}"})
+	sm.Spec.AllowOverrides = true
+	sm.Spec.Outputs = []bindingoperatorscoreoscomv1alpha1.ServiceResourceMapOutput{
+		{Name: "admin", ServiceMap: map[string]string{"host": "path={.metadata.name}"}},
+	}
+	obj := instance()
+	obj["metadata"].(map[string]interface{})["annotations"] = map[string]interface{}{
+		AnnotationOverridePrefix + "host":     "db.example.com",
+		AnnotationOverridePrefix + "password": "path={.spec.secret},objectType=Secret,sourceKey=password",
+		AnnotationOverridePrefix:              "ignored",
+		"example.com/other":                   "ignored",
+	}
+
+	g.Expect(Overrides(sm, obj)).To(Equal(map[string]string{
+		"host":     "db.example.com",
+		"password": "path={.spec.secret},objectType=Secret,sourceKey=password",
+	}))
+
+	// overrides apply to the default output only
+	outputs := Outputs(sm)
+	g.Expect(RulesFor(sm, outputs[0], obj)).To(Equal(map[string]string{
+		"type":     "postgresql",
+		"host":     "db.example.com",
+		"password": "path={.spec.secret},objectType=Secret,sourceKey=password",
+	}))
+	g.Expect(RulesFor(sm, outputs[1], obj)).To(Equal(outputs[1].Rules))
+
+	cli := &fakeClient{objs: []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "ns"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		},
+	}}
+	sed, failures := NewServiceEndpointDefinition(context.TODO(), cli, sm, newServiceProxy(), outputs[0], obj)
+	g.Expect(failures).To(BeEmpty())
+	g.Expect(stringData(sed)).To(HaveKeyWithValue("host", "db.example.com"))
+	g.Expect(stringData(sed)).To(HaveKeyWithValue("password", "s3cr3t"))
+
+	// changing an override changes the extracted values
+	vs := Extract(sm, obj)
+	obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})[AnnotationOverridePrefix+"host"] = "other.example.com"
+	g.Expect(Extract(sm, obj)).NotTo(Equal(vs))
+
+	// overrides are ignored unless allowed
+	sm.Spec.AllowOverrides = false
+	g.Expect(Overrides(sm, obj)).To(BeNil())
+	g.Expect(RulesFor(sm, outputs[0], obj)).To(Equal(outputs[0].Rules))
+}
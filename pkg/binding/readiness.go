@@ -0,0 +1,77 @@
+package binding
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+// ValidateReadiness returns an error if the readiness of the
+// ServiceResourceMap is empty or its condition is invalid
+func ValidateReadiness(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) error {
+	rd := sm.Spec.Readiness
+	if rd == nil {
+		return nil
+	}
+	if rd.ConditionType == "" && rd.When == "" {
+		return fmt.Errorf("invalid readiness: condition_type or when must be set")
+	}
+	if rd.When != "" {
+		if _, err := ParseCondition(rd.When); err != nil {
+			return fmt.Errorf("invalid readiness: %w", err)
+		}
+	}
+	return nil
+}
+
+// Ready returns true if the instance satisfies the readiness of the
+// ServiceResourceMap, always true if it has none. Otherwise, the message
+// tells what the instance is waiting for.
+func Ready(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, obj map[string]interface{}) (bool, string) {
+	rd := sm.Spec.Readiness
+	if rd == nil {
+		return true, ""
+	}
+
+	if rd.ConditionType != "" {
+		want := rd.ConditionStatus
+		if want == "" {
+			want = metav1.ConditionTrue
+		}
+		if got := conditionStatus(obj, rd.ConditionType); got != string(want) {
+			if got == "" {
+				return false, fmt.Sprintf("waiting for the instance condition '%s' to be '%s', not found", rd.ConditionType, want)
+			}
+			return false, fmt.Sprintf("waiting for the instance condition '%s' to be '%s', is '%s'", rd.ConditionType, want, got)
+		}
+	}
+
+	if rd.When != "" {
+		c, err := ParseCondition(rd.When)
+		if err != nil {
+			return false, fmt.Sprintf("invalid readiness: %v", err)
+		}
+		if !c.Matches(obj) {
+			return false, fmt.Sprintf("waiting for the instance to satisfy '%s'", rd.When)
+		}
+	}
+	return true, ""
+}
+
+// conditionStatus returns the status of the condition of the instance's
+// `status.conditions`, empty if not found
+func conditionStatus(obj map[string]interface{}, conditionType string) string {
+	conditions, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+	for _, c := range conditions {
+		c, ok := c.(map[string]interface{})
+		if !ok || c["type"] != conditionType {
+			continue
+		}
+		s, _ := c["status"].(string)
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package binding
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+func TestReady(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{"type": "postgresql"})
+	obj := instance()
+	g.Expect(Ready(sm, obj)).To(BeTrue())
+
+	sm.Spec.Readiness = &bindingoperatorscoreoscomv1alpha1.ServiceResourceMapReadiness{}
+	g.Expect(ValidateReadiness(sm)).To(MatchError(ContainSubstring("condition_type or when must be set")))
+	sm.Spec.Readiness.When = ".status.phase ~= available"
+	g.Expect(ValidateReadiness(sm)).To(HaveOccurred())
+
+	sm.Spec.Readiness = &bindingoperatorscoreoscomv1alpha1.ServiceResourceMapReadiness{
+		ConditionType:   "Degraded",
+		ConditionStatus: metav1.ConditionFalse,
+		When:            `.status.phase == "available"`,
+	}
+	g.Expect(ValidateReadiness(sm)).To(Succeed())
+	g.Expect(Fields(sm)).To(ConsistOf([]string{"status", "conditions"}, []string{"status", "phase"}))
+
+	ready, msg := Ready(sm, obj)
+	g.Expect(ready).To(BeFalse())
+	g.Expect(msg).To(Equal("waiting for the instance condition 'Degraded' to be 'False', not found"))
+
+	obj["status"] = map[string]interface{}{
+		"conditions": []interface{}{map[string]interface{}{"type": "Degraded", "status": "True"}},
+	}
+	_, msg = Ready(sm, obj)
+	g.Expect(msg).To(Equal("waiting for the instance condition 'Degraded' to be 'False', is 'True'"))
+
+	obj["status"].(map[string]interface{})["conditions"] = []interface{}{map[string]interface{}{"type": "Degraded", "status": "False"}}
+	_, msg = Ready(sm, obj)
+	g.Expect(msg).To(Equal(`waiting for the instance to satisfy '.status.phase == "available"'`))
+
+	// the readiness changes are not skipped as unchanged values
+	vs := Extract(sm, obj)
+	obj["status"].(map[string]interface{})["phase"] = "available"
+	g.Expect(Ready(sm, obj)).To(BeTrue())
+	g.Expect(Extract(sm, obj)).NotTo(Equal(vs))
+}
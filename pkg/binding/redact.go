@@ -0,0 +1,112 @@
+package binding
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Masked replaces resolved values wherever they are printed
+const Masked = "******"
+
+// hashKey keys the fingerprints of the values. It is drawn for every
+// process, so that the fingerprints found in logs can not be matched against
+// the fingerprints of guessed values, e.g. of short or common passwords.
+var hashKey = func() []byte {
+	k := make([]byte, sha256.Size)
+	if _, err := rand.Read(k); err != nil {
+		panic(fmt.Sprintf("can not draw the key of the value fingerprints: %v", err))
+	}
+	return k
+}()
+
+// Value is a value resolved from a service instance or from a referenced
+// Secret or ConfigMap. It is masked when formatted or marshalled, so that it
+// can not leak in logs or errors; Reveal or Bytes must be called explicitly
+// to read it. Values are bytes, so that binary entries such as keystores and
+// certificates are bound untouched.
+type Value []byte
+
+// Reveal returns the actual value as a string
+func (v Value) Reveal() string {
+	return string(v)
+}
+
+// Bytes returns the actual value
+func (v Value) Bytes() []byte {
+	return []byte(v)
+}
+
+// Hash returns a short fingerprint of the value, allowing to tell in the logs
+// of a process whether a value changed without disclosing it. The
+// fingerprints of the same value differ from one process to another.
+func (v Value) Hash() string {
+	h := hmac.New(sha256.New, hashKey)
+	h.Write(v)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func (v Value) String() string {
+	return Masked
+}
+
+func (v Value) GoString() string {
+	return Masked
+}
+
+// Format masks the value for every verb, including %x and %q
+func (v Value) Format(f fmt.State, _ rune) {
+	_, _ = io.WriteString(f, Masked)
+}
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Masked)
+}
+
+func (v Value) MarshalText() ([]byte, error) {
+	return []byte(Masked), nil
+}
+
+// Values are the entries of a Service Endpoint Definition
+type Values map[string]Value
+
+// Data returns the revealed values
+func (vs Values) Data() map[string][]byte {
+	d := make(map[string][]byte, len(vs))
+	for k, v := range vs {
+		d[k] = v.Bytes()
+	}
+	return d
+}
+
+// Keys returns the sorted names of the entries
+func (vs Values) Keys() []string {
+	ks := make([]string, 0, len(vs))
+	for k := range vs {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// Hashes returns the fingerprint of every entry, suitable for logging
+func (vs Values) Hashes() map[string]string {
+	hs := make(map[string]string, len(vs))
+	for k, v := range vs {
+		hs[k] = v.Hash()
+	}
+	return hs
+}
+
+func valuesOf(d map[string]string) Values {
+	vs := make(Values, len(d))
+	for k, v := range d {
+		vs[k] = Value(v)
+	}
+	return vs
+}
@@ -0,0 +1,86 @@
+package binding
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+const secretValue = "s3cr3t-p4ssw0rd"
+
+func TestValueIsMasked(t *testing.T) {
+	g := NewWithT(t)
+
+	v := Value(secretValue)
+	for _, f := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%10s"} {
+		g.Expect(fmt.Sprintf(f, v)).NotTo(ContainSubstring(secretValue), f)
+	}
+	g.Expect(fmt.Sprint(Values{"password": v})).NotTo(ContainSubstring(secretValue))
+
+	b, err := json.Marshal(map[string]interface{}{"password": v, "values": Values{"password": v}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).NotTo(ContainSubstring(secretValue))
+	g.Expect(string(b)).To(ContainSubstring(Masked))
+
+	g.Expect(v.Reveal()).To(Equal(secretValue))
+	g.Expect(v.Hash()).To(HaveLen(16))
+	g.Expect(v.Hash()).To(Equal(Value(secretValue).Hash()))
+	g.Expect(v.Hash()).NotTo(Equal(Value("other").Hash()))
+
+	// the fingerprint is keyed, the value can not be guessed from it
+	h := sha256.Sum256([]byte(secretValue))
+	g.Expect(v.Hash()).NotTo(Equal(hex.EncodeToString(h[:8])))
+}
+
+func TestValues(t *testing.T) {
+	g := NewWithT(t)
+
+	vs := Values{"username": Value("admin"), "password": Value(secretValue)}
+	g.Expect(vs.Keys()).To(Equal([]string{"password", "username"}))
+	g.Expect(vs.Data()).To(Equal(map[string][]byte{"username": []byte("admin"), "password": []byte(secretValue)}))
+	g.Expect(vs.Hashes()).To(HaveKeyWithValue("password", Value(secretValue).Hash()))
+}
+
+func TestNewServiceEndpointDefinitionDoesNotLogValues(t *testing.T) {
+	g := NewWithT(t)
+
+	cli := &fakeClient{objs: []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "ns"},
+			Data:       map[string][]byte{"password": []byte(secretValue)},
+		},
+	}}
+
+	buf := &bytes.Buffer{}
+	ctx := log.IntoContext(context.Background(), zap.New(zap.WriteTo(buf), zap.UseDevMode(true)))
+
+	sm := newServiceResourceMap(map[string]string{
+		"password": "path={.spec.secret},objectType=Secret,sourceKey=password",
+		"missing":  "path={.spec.secret},objectType=Secret,sourceKey=missing",
+		"other":    "path={.spec.configs},objectType=Secret",
+	})
+	sed, failures := NewServiceEndpointDefinition(ctx, cli, sm, newServiceProxy(), Outputs(sm)[0], instance())
+	g.Expect(stringData(sed)).To(HaveKeyWithValue("password", secretValue))
+	g.Expect(failures).To(HaveLen(2))
+	g.Expect(failures[0].Reason()).To(Equal("SourceKeyNotFound"))
+	g.Expect(failures[1].Reason()).To(Equal("ReferenceNotFound"))
+
+	out := buf.String()
+	g.Expect(out).To(ContainSubstring("rendered Service Endpoint Definition"))
+	g.Expect(out).To(ContainSubstring(Value(secretValue).Hash()))
+	g.Expect(out).To(ContainSubstring("missing"))
+	g.Expect(out).NotTo(ContainSubstring(secretValue))
+	g.Expect(out).NotTo(ContainSubstring("db-credentials"))
+	g.Expect(out).NotTo(ContainSubstring("db-config"))
+}
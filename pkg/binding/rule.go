@@ -0,0 +1,207 @@
+package binding
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// UnnamedKey is the key of rules whose name is taken from the referenced
+	// object, as `service.binding` annotations do in the Service Binding Operator
+	UnnamedKey = "service.binding"
+
+	ObjectTypeSecret    = "Secret"
+	ObjectTypeConfigMap = "ConfigMap"
+)
+
+// Rule is a parsed service_map value. The grammar is the one of the Service
+// Binding Operator annotations:
+//
+//	<literal value>
+//	path={<jsonpath>}
+//	path={<jsonpath>},objectType=<Secret|ConfigMap|ServiceCA|Certificate|Service>[,sourceKey=<key|{jsonpath}>]
+//	compose=<composer>
+//
+// JSONPath rules accept a `validate=pem` option, failing unless their values
+// are PEM encoded.
+//
+// followed by an optional pipeline of transforms applied to every value of
+// the rule, e.g. `path={.spec.url} | trimprefix("https://") | split(":")[0]`.
+type Rule struct {
+	// Path is the JSONPath template, without the `path=` prefix
+	Path string
+	// Value is the literal value of rules without Path
+	Value string
+	// ObjectType is the kind of object referenced by the name found at Path
+	ObjectType string
+	// SourceKey selects a single entry of the referenced object. A JSONPath
+	// template, e.g. `{.spec.passwordSecretRef.key}`, reads it from the instance.
+	SourceKey string
+	// Validate is the format the values must have: empty or ValidatePEM
+	Validate string
+	// Compose names the composer building the value from the other rules
+	Compose string
+	// Transforms are applied in order to the values of the rule
+	Transforms []Transform
+}
+
+// ParseRule parses a service_map value
+func ParseRule(v string) (Rule, error) {
+	base, steps := splitPipeline(v)
+	r, err := parseRule(base)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	for _, s := range steps {
+		t, err := ParseTransform(s)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid transform in rule '%s': %w", v, err)
+		}
+		r.Transforms = append(r.Transforms, t)
+	}
+	return r, nil
+}
+
+// parseRule parses a service_map value without transforms
+func parseRule(v string) (Rule, error) {
+	if strings.HasPrefix(v, "compose=") {
+		name := strings.TrimPrefix(v, "compose=")
+		if _, ok := composers[name]; !ok {
+			return Rule{}, fmt.Errorf("unknown composer '%s' in rule '%s'", name, v)
+		}
+		return Rule{Compose: name}, nil
+	}
+
+	if !isJsonpath(v) {
+		return Rule{Value: v}, nil
+	}
+
+	p, opts := splitPath(strings.TrimPrefix(v, "path="))
+	r := Rule{Path: p}
+	for _, o := range opts {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 {
+			return Rule{}, fmt.Errorf("invalid option '%s' in rule '%s'", o, v)
+		}
+
+		switch kv[0] {
+		case "objectType":
+			switch kv[1] {
+			case ObjectTypeSecret, ObjectTypeConfigMap, ObjectTypeServiceCA, ObjectTypeCertificate, ObjectTypeService:
+			default:
+				return Rule{}, fmt.Errorf("invalid objectType: %s", kv[1])
+			}
+			r.ObjectType = kv[1]
+		case "sourceKey":
+			r.SourceKey = kv[1]
+		case "validate":
+			if kv[1] != ValidatePEM {
+				return Rule{}, fmt.Errorf("invalid validate: %s", kv[1])
+			}
+			r.Validate = kv[1]
+		default:
+			return Rule{}, fmt.Errorf("unsupported option '%s' in rule '%s'", kv[0], v)
+		}
+	}
+
+	if r.SourceKey != "" && r.ObjectType == "" {
+		return Rule{}, fmt.Errorf("sourceKey requires objectType in rule '%s'", v)
+	}
+	return r, nil
+}
+
+// SourceKeyPath returns the JSONPath template of the source key, if it is
+// read from the instance
+func (r Rule) SourceKeyPath() string {
+	if strings.HasPrefix(r.SourceKey, "{") {
+		return r.SourceKey
+	}
+	return ""
+}
+
+// IsReference returns true if the rule reads its values from a Secret or a ConfigMap
+func (r Rule) IsReference() bool {
+	return r.ObjectType != ""
+}
+
+// String returns the rule in the service_map grammar
+func (r Rule) String() string {
+	s := r.Value
+	switch {
+	case r.Compose != "":
+		s = "compose=" + r.Compose
+	case r.Path != "":
+		s = "path=" + r.Path
+		if r.ObjectType != "" {
+			s += ",objectType=" + r.ObjectType
+		}
+		if r.SourceKey != "" {
+			s += ",sourceKey=" + r.SourceKey
+		}
+		if r.Validate != "" {
+			s += ",validate=" + r.Validate
+		}
+	}
+
+	for _, t := range r.Transforms {
+		s += " | " + t.String()
+	}
+	return s
+}
+
+// Rule types, as reported in metrics
+const (
+	RuleTypeLiteral  = "literal"
+	RuleTypeJsonpath = "jsonpath"
+	RuleTypeCompose  = "compose"
+	RuleTypeInvalid  = "invalid"
+)
+
+// Type returns the type of the rule: literal, jsonpath, compose or the
+// referenced object type
+func (r Rule) Type() string {
+	switch {
+	case r.Compose != "":
+		return RuleTypeCompose
+	case r.IsReference():
+		return r.ObjectType
+	case r.Path != "":
+		return RuleTypeJsonpath
+	}
+	return RuleTypeLiteral
+}
+
+// ruleType returns the type of the unparsed rule
+func ruleType(v string) string {
+	r, err := ParseRule(v)
+	if err != nil {
+		return RuleTypeInvalid
+	}
+	return r.Type()
+}
+
+// isCompose returns true if the unparsed rule is a compose rule
+func isCompose(v string) bool {
+	r, err := ParseRule(v)
+	return err == nil && r.Compose != ""
+}
+
+// splitPath splits the JSONPath template from the comma separated options
+// following it. Commas within braces belong to the template.
+func splitPath(v string) (string, []string) {
+	depth := 0
+	for i, c := range v {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				return v[:i], strings.Split(v[i+1:], ",")
+			}
+		}
+	}
+	return v, nil
+}
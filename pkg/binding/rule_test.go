@@ -0,0 +1,92 @@
+package binding
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    Rule
+		wantErr bool
+	}{
+		{rule: "postgresql", want: Rule{Value: "postgresql"}},
+		{rule: "path={.status.endpoint.address}", want: Rule{Path: "{.status.endpoint.address}"}},
+		{rule: "path={.metadata.name}.{.metadata.namespace}", want: Rule{Path: "{.metadata.name}.{.metadata.namespace}"}},
+		{rule: "path={.spec.ports[0,1]}", want: Rule{Path: "{.spec.ports[0,1]}"}},
+		{
+			rule: "path={.spec.masterUserPassword.name},objectType=Secret,sourceKey=password",
+			want: Rule{Path: "{.spec.masterUserPassword.name}", ObjectType: ObjectTypeSecret, SourceKey: "password"},
+		},
+		{
+			rule: "path={.spec.config},objectType=ConfigMap",
+			want: Rule{Path: "{.spec.config}", ObjectType: ObjectTypeConfigMap},
+		},
+		{
+			rule: "path=openshift-service-ca.crt,objectType=ServiceCA,validate=pem",
+			want: Rule{Path: "openshift-service-ca.crt", ObjectType: ObjectTypeServiceCA, Validate: ValidatePEM},
+		},
+		{
+			rule: "path={.spec.certificateRef},objectType=Certificate,sourceKey=ca.crt",
+			want: Rule{Path: "{.spec.certificateRef}", ObjectType: ObjectTypeCertificate, SourceKey: "ca.crt"},
+		},
+		{rule: "path={.spec.serviceName},objectType=Service", want: Rule{Path: "{.spec.serviceName}", ObjectType: ObjectTypeService}},
+		{rule: "path={.spec.ca},validate=pem", want: Rule{Path: "{.spec.ca}", Validate: ValidatePEM}},
+		{rule: "path={.spec.ca},validate=der", wantErr: true},
+		{rule: "a|b", want: Rule{Value: "a|b"}},
+		{rule: "postgresql | upper", want: Rule{Value: "postgresql", Transforms: []Transform{{Name: "upper", Index: -1}}}},
+		{rule: "dev | prod", want: Rule{Value: "dev | prod"}},
+		{rule: "a | b | upper", want: Rule{Value: "a | b", Transforms: []Transform{{Name: "upper", Index: -1}}}},
+		{rule: `read | write | trimsuffix("e")`, want: Rule{Value: "read | write", Transforms: []Transform{{Name: "trimsuffix", Args: []string{"e"}, Index: -1}}}},
+		{
+			rule: `path={.spec.url} | trimprefix("https://") | split(":")[0]`,
+			want: Rule{Path: "{.spec.url}", Transforms: []Transform{
+				{Name: "trimprefix", Args: []string{"https://"}, Index: -1},
+				{Name: "split", Args: []string{":"}, Index: 0},
+			}},
+		},
+		{
+			rule: `path={.spec.secret},objectType=Secret,sourceKey=password | b64dec | replace("\"", "'")`,
+			want: Rule{Path: "{.spec.secret}", ObjectType: ObjectTypeSecret, SourceKey: "password", Transforms: []Transform{
+				{Name: "b64dec", Index: -1},
+				{Name: "replace", Args: []string{`"`, "'"}, Index: -1},
+			}},
+		},
+		{rule: "compose=postgresql-uri", want: Rule{Compose: "postgresql-uri"}},
+		{
+			rule: `compose=mysql-jdbc | replace("mysql", "mariadb")`,
+			want: Rule{Compose: "mysql-jdbc", Transforms: []Transform{{Name: "replace", Args: []string{"mysql", "mariadb"}, Index: -1}}},
+		},
+		{rule: "compose=oracle-uri", wantErr: true},
+		{
+			rule: "path={.spec.secretRef.name},objectType=Secret,sourceKey={.spec.secretRef.key}",
+			want: Rule{Path: "{.spec.secretRef.name}", ObjectType: ObjectTypeSecret, SourceKey: "{.spec.secretRef.key}"},
+		},
+		{rule: "path={.spec.x} | unknown", wantErr: true},
+		{rule: `path={.spec.x} | trim("a", "b")`, wantErr: true},
+		{rule: `path={.spec.x} | split(":")[a]`, wantErr: true},
+		{rule: `path={.spec.x} | split(":"`, wantErr: true},
+		{rule: "path={.spec.x},objectType=Deployment", wantErr: true},
+		{rule: "path={.spec.x},sourceKey=password", wantErr: true},
+		{rule: "path={.spec.x},elementType=sliceOfMaps", wantErr: true},
+		{rule: "path={.spec.x},objectType", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r, err := ParseRule(tt.rule)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(r).To(Equal(tt.want))
+			g.Expect(r.String()).To(Equal(tt.rule))
+		})
+	}
+}
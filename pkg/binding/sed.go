@@ -3,45 +3,121 @@ package binding
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"k8s.io/client-go/util/jsonpath"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/prometheus/client_golang/prometheus"
 
-	"github.com/go-logr/logr"
 	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/metrics"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+var (
+	errInvalidRule       = errors.New("invalid rule")
+	errJsonpath          = errors.New("jsonpath evaluation failed")
+	errSourceKeyNotFound = errors.New("source key not found")
+)
+
+// NewServiceEndpointDefinition renders the Service Endpoint Definition of the
+// output o for the instance obj. The returned Secret follows the Service
+// Binding specification conventions: it has the `servicebinding.io/<type>`
+// type and always contains `type` and `provider`.
+//
+// Rules that can not be processed are left out of the Secret and returned as
+// RuleErrors, sorted by key.
 func NewServiceEndpointDefinition(ctx context.Context,
 	client client.Client,
 	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
 	sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy,
-	obj interface{}) *corev1.Secret {
+	o Output,
+	obj interface{}) (*corev1.Secret, []*RuleError) {
+	key := MapKey(sm)
+	defer prometheus.NewTimer(metrics.SEDRenderDuration.WithLabelValues(key)).ObserveDuration()
+	metrics.SEDRenders.WithLabelValues(key).Inc()
 
-	secrets := extractSecrets(ctx, client, sp.Namespace, sm.Spec.ServiceMap, obj)
+	content, _ := obj.(map[string]interface{})
+	secrets, failures := extractSecrets(ctx, client, key, sp.Namespace, RulesFor(sm, o, content), obj)
+	applySpecConventions(ctx, sm, secrets)
+	log.FromContext(ctx).V(1).Info("rendered Service Endpoint Definition",
+		"srm", key, "output", o.Name, "hashes", secrets.Hashes())
 
 	sed := corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      sp.Name + "-sed",
+			Name:      o.SecretName(sp.Spec.ServiceInstance.Name),
 			Namespace: sp.Namespace,
+			Labels:    SEDLabels(sm, sp, o),
 		},
-		StringData: secrets,
+		Type: secretType(secrets[TypeKey].Reveal()),
+		Data: secrets.Data(),
 	}
-	return &sed
+	return &sed, failures
+}
+
+// RuleError reports a service_map rule that could not be processed. Err may
+// name the objects the rule refers to: use Reason when logging.
+type RuleError struct {
+	Key  string
+	Rule string
+	Err  error
 }
 
-func extractSecrets(ctx context.Context, client client.Client, namespace string, rules map[string]string, obj interface{}) map[string]string {
-	secrets := map[string]string{}
-	l, _ := logr.FromContext(ctx)
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("can not process rule '%s': %v", e.Key, e.Err)
+}
 
-	for k, v := range rules {
-		ss, err := processTarget(ctx, client, namespace, k, v, obj)
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// Reason returns a description of the failure that discloses no names nor values
+func (e *RuleError) Reason() string {
+	switch {
+	case errors.Is(e.Err, errInvalidRule):
+		return "InvalidRule"
+	case errors.Is(e.Err, errJsonpath):
+		return "JsonpathFailed"
+	case errors.Is(e.Err, errSourceKeyNotFound):
+		return "SourceKeyNotFound"
+	case errors.Is(e.Err, errTransform):
+		return "TransformFailed"
+	case errors.Is(e.Err, errCompose):
+		return "ComposeFailed"
+	case errors.Is(e.Err, errNoCertificateSecret):
+		return "NoCertificateSecret"
+	case errors.Is(e.Err, errInvalidPEM):
+		return "InvalidPEM"
+	}
+
+	if r := apierrors.ReasonForError(e.Err); r != metav1.StatusReasonUnknown {
+		return "Reference" + string(r)
+	}
+	return "Unknown"
+}
+
+// extractSecrets processes the rules. Compose rules are processed last, in
+// key order, from the values of the other rules.
+func extractSecrets(ctx context.Context, client client.Client, srm, namespace string, rules map[string]string, obj interface{}) (Values, []*RuleError) {
+	secrets := Values{}
+	failures := []*RuleError{}
+	l := log.FromContext(ctx)
+
+	process := func(k, v string, resolved Values) {
+		ss, err := processTarget(ctx, client, namespace, k, v, obj, resolved)
 		if err != nil {
-			l.Info("can not process target", "rule", v, "error", err)
-			continue
+			f := &RuleError{Key: k, Rule: v, Err: err}
+			metrics.RuleFailures.WithLabelValues(srm, ruleType(v)).Inc()
+			l.Info("can not process rule", "srm", srm, "key", k, "type", ruleType(v), "reason", f.Reason())
+			failures = append(failures, f)
+			return
 		}
 
 		for k, v := range ss {
@@ -49,88 +125,142 @@ func extractSecrets(ctx context.Context, client client.Client, namespace string,
 		}
 	}
 
-	return secrets
+	var composed []string
+	for k, v := range rules {
+		if isCompose(v) {
+			composed = append(composed, k)
+			continue
+		}
+		process(k, v, nil)
+	}
+
+	// composers read the values of the other rules only, not the ones of
+	// each other
+	sort.Strings(composed)
+	resolved := make(Values, len(secrets))
+	for k, v := range secrets {
+		resolved[k] = v
+	}
+	for _, k := range composed {
+		process(k, rules[k], resolved)
+	}
+
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Key < failures[j].Key })
+	return secrets, failures
 }
 
-func processTarget(ctx context.Context, client client.Client, namespace, k, v string, obj interface{}) (map[string]string, error) {
-	ss := strings.Split(v, ",")
-	if len(ss) > 1 {
-		return processRefTarget(ctx, client, namespace, ss, obj)
+func processTarget(ctx context.Context, client client.Client, namespace, k, v string, obj interface{}, resolved Values) (Values, error) {
+	r, err := ParseRule(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidRule, err)
 	}
 
-	if isJsonpath(v) {
-		v, err := executeJsonpath(v, obj)
+	var vs Values
+	switch {
+	case r.Compose != "":
+		s, err := compose(r.Compose, resolved)
 		if err != nil {
 			return nil, err
 		}
-		return map[string]string{k: v}, nil
+		vs = Values{k: s}
+	case r.IsReference():
+		if vs, err = processRefTarget(ctx, client, namespace, k, r, obj); err != nil {
+			return nil, err
+		}
+	case r.Path != "":
+		v, err := executeJsonpath(r.Path, obj)
+		if err != nil {
+			return nil, err
+		}
+		vs = Values{k: Value(v)}
+	default:
+		vs = Values{k: Value(r.Value)}
 	}
-	return map[string]string{k: v}, nil
-}
 
-func processRefTarget(ctx context.Context, cli client.Client, namespace string, ss []string, obj interface{}) (map[string]string, error) {
-	jp, refType := ss[0], ss[1]
+	if vs, err = transform(r.Transforms, vs); err != nil {
+		return nil, err
+	}
+	if r.Validate == ValidatePEM {
+		if err := validatePEM(vs); err != nil {
+			return nil, err
+		}
+	}
+	return vs, nil
+}
 
-	refObj, err := executeJsonpath(jp, obj)
+func processRefTarget(ctx context.Context, cli client.Client, namespace, k string, r Rule, obj interface{}) (Values, error) {
+	refObj, err := executeJsonpath(r.Path, obj)
 	if err != nil {
 		return nil, err
 	}
 
-	switch refType {
-	case "objectType=Secret":
+	// the kind of the object the entries are read from
+	var kind string
+	var d Values
+	switch r.ObjectType {
+	case ObjectTypeSecret, ObjectTypeCertificate, ObjectTypeService:
+		kind = ObjectTypeSecret
+		if r.ObjectType != ObjectTypeSecret {
+			if refObj, err = certificateSecretName(ctx, cli, r.ObjectType, namespace, refObj); err != nil {
+				return nil, err
+			}
+		}
+
 		s := corev1.Secret{}
 		skey := client.ObjectKey{Namespace: namespace, Name: refObj}
-		if err := cli.Get(ctx, skey, &s); err != nil {
+		err := cli.Get(ctx, skey, &s)
+		metrics.ReferenceLookups.WithLabelValues(kind, metrics.Result(err)).Inc()
+		if err != nil {
 			return nil, fmt.Errorf("can not retrieve Secret '%s/%s': %w", namespace, refObj, err)
 		}
 
-		d := make(map[string]string, len(s.Data))
+		d = make(Values, len(s.Data))
 		for k, v := range s.Data {
-			d[k] = string(v)
+			d[k] = Value(v)
 		}
-
-		return d, nil
-	case "objectType=ConfigMap":
+	case ObjectTypeConfigMap, ObjectTypeServiceCA:
+		kind = ObjectTypeConfigMap
 		cm := corev1.ConfigMap{}
 		cmkey := client.ObjectKey{Namespace: namespace, Name: refObj}
-		if err := cli.Get(ctx, cmkey, &cm); err != nil {
+		err := cli.Get(ctx, cmkey, &cm)
+		metrics.ReferenceLookups.WithLabelValues(kind, metrics.Result(err)).Inc()
+		if err != nil {
 			return nil, fmt.Errorf("can not retrieve ConfigMap '%s/%s': %w", namespace, refObj, err)
 		}
 
-		return cm.Data, nil
-	}
-
-	return nil, fmt.Errorf("invalid objectType: %s", refType)
-}
-
-func processSecretTarget(ctx context.Context, cli client.Client, namespace string, ss []string, refObj string) (map[string]string, error) {
-	s := corev1.Secret{}
-	skey := client.ObjectKey{Namespace: namespace, Name: refObj}
-	if err := cli.Get(ctx, skey, &s); err != nil {
-		return nil, fmt.Errorf("can not retrieve Secret '%s/%s': %w", namespace, refObj, err)
-	}
-
-	sk := func() string {
-		if len(ss) != 3 {
-			return ""
+		d = valuesOf(cm.Data)
+		for k, v := range cm.BinaryData {
+			d[k] = Value(v)
 		}
-
-		if ssfs := strings.Split(ss[2], "="); len(ssfs) == 2 {
-			return ssfs[1]
+		// the CA bundle is bound under the key of the rule
+		if r.ObjectType == ObjectTypeServiceCA && r.SourceKey == "" {
+			r.SourceKey = ServiceCAKey
 		}
+	default:
+		return nil, fmt.Errorf("%w: invalid objectType: %s", errInvalidRule, r.ObjectType)
+	}
 
-		return ""
-	}()
+	if r.SourceKey == "" {
+		return d, nil
+	}
 
-	d := make(map[string]string, len(s.Data))
-	for k, v := range s.Data {
-		if sk == "" || sk == k {
-			d[k] = string(v)
+	sourceKey := r.SourceKey
+	if p := r.SourceKeyPath(); p != "" {
+		if sourceKey, err = executeJsonpath(p, obj); err != nil {
+			return nil, err
 		}
 	}
 
-	return d, nil
-
+	// only the entry selected by sourceKey is bound, named after the rule's
+	// key or after the source key itself for unnamed rules
+	v, ok := d[sourceKey]
+	if !ok {
+		return nil, fmt.Errorf("%w: key '%s' not found in %s '%s/%s'", errSourceKeyNotFound, sourceKey, kind, namespace, refObj)
+	}
+	if k == UnnamedKey {
+		k = sourceKey
+	}
+	return Values{k: v}, nil
 }
 
 func isJsonpath(v string) bool {
@@ -141,13 +271,13 @@ func executeJsonpath(v string, data interface{}) (string, error) {
 	jp := jsonpath.New("")
 
 	if err := jp.Parse(v); err != nil {
-		return "", fmt.Errorf("invalid jsonpath '%s': %w", v, err)
+		return "", fmt.Errorf("%w: invalid jsonpath '%s': %v", errInvalidRule, v, err)
 	}
 
 	buf := new(bytes.Buffer)
 	if err := jp.Execute(buf, data); err != nil {
-		return "", fmt.Errorf("can not extract data using jsonpath '%s': %w", v, err)
+		return "", fmt.Errorf("%w: '%s': %v", errJsonpath, v, err)
 	}
 
-	return strings.TrimPrefix(buf.String(), "path="), nil
+	return buf.String(), nil
 }
@@ -0,0 +1,268 @@
+package binding
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/metrics"
+)
+
+// fakeClient serves Secrets, ConfigMaps, Services and unstructured objects
+// from memory, any other call panics
+type fakeClient struct {
+	client.Client
+	objs []client.Object
+}
+
+func (f *fakeClient) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	for _, o := range f.objs {
+		if client.ObjectKeyFromObject(o) != key {
+			continue
+		}
+
+		switch t := obj.(type) {
+		case *corev1.Secret:
+			if s, ok := o.(*corev1.Secret); ok {
+				s.DeepCopyInto(t)
+				return nil
+			}
+		case *corev1.ConfigMap:
+			if cm, ok := o.(*corev1.ConfigMap); ok {
+				cm.DeepCopyInto(t)
+				return nil
+			}
+		case *corev1.Service:
+			if svc, ok := o.(*corev1.Service); ok {
+				svc.DeepCopyInto(t)
+				return nil
+			}
+		case *unstructured.Unstructured:
+			if u, ok := o.(*unstructured.Unstructured); ok && u.GroupVersionKind() == t.GroupVersionKind() {
+				u.DeepCopyInto(t)
+				return nil
+			}
+		}
+	}
+	return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+func newServiceResourceMap(rules map[string]string) *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap {
+	return &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "srm"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceKindReference: bindingoperatorscoreoscomv1alpha1.ServiceKindReference{
+				ApiGroup: "postgresql.example.com/v1",
+				Kind:     "databases",
+			},
+			ServiceMap: rules,
+		},
+	}
+}
+
+func newServiceProxy() *bindingoperatorscoreoscomv1alpha1.ServiceProxy {
+	return &bindingoperatorscoreoscomv1alpha1.ServiceProxy{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns"},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceProxySpec{
+			ServiceResourceMapRef: "srm",
+			ServiceInstance:       bindingoperatorscoreoscomv1alpha1.NamespacedName{Name: "db", Namespace: "ns"},
+		},
+	}
+}
+
+func instance() map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "db", "namespace": "ns"},
+		"spec": map[string]interface{}{
+			"user":      "admin",
+			"secret":    "db-credentials",
+			"configs":   "db-config",
+			"secretKey": "token",
+		},
+	}
+}
+
+// stringData returns the entries of a SED as strings
+func stringData(sed *corev1.Secret) map[string]string {
+	d := make(map[string]string, len(sed.Data))
+	for k, v := range sed.Data {
+		d[k] = string(v)
+	}
+	return d
+}
+
+func TestNewServiceEndpointDefinition(t *testing.T) {
+	cli := &fakeClient{objs: []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "ns"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t"), "token": []byte("t0k3n")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-config", Namespace: "ns"},
+			Data:       map[string]string{"sslmode": "require"},
+		},
+	}}
+
+	tests := []struct {
+		name  string
+		rules map[string]string
+		want  map[string]string
+	}{
+		{
+			name:  "literal and jsonpath",
+			rules: map[string]string{"type": "postgresql", "host": "path={.metadata.name}.{.metadata.namespace}"},
+			want:  map[string]string{"type": "postgresql", "provider": "postgresql.example.com", "host": "db.ns"},
+		},
+		{
+			name:  "whole secret",
+			rules: map[string]string{UnnamedKey: "path={.spec.secret},objectType=Secret"},
+			want:  map[string]string{"password": "s3cr3t", "token": "t0k3n", "type": "databases", "provider": "postgresql.example.com"},
+		},
+		{
+			name:  "named source key",
+			rules: map[string]string{"pwd": "path={.spec.secret},objectType=Secret,sourceKey=password"},
+			want:  map[string]string{"pwd": "s3cr3t", "type": "databases", "provider": "postgresql.example.com"},
+		},
+		{
+			name:  "unnamed source key",
+			rules: map[string]string{UnnamedKey: "path={.spec.secret},objectType=Secret,sourceKey=password"},
+			want:  map[string]string{"password": "s3cr3t", "type": "databases", "provider": "postgresql.example.com"},
+		},
+		{
+			name:  "source key read from the instance",
+			rules: map[string]string{"pwd": "path={.spec.secret},objectType=Secret,sourceKey={.spec.secretKey}"},
+			want:  map[string]string{"pwd": "t0k3n", "type": "databases", "provider": "postgresql.example.com"},
+		},
+		{
+			name:  "config map",
+			rules: map[string]string{"ssl": "path={.spec.configs},objectType=ConfigMap,sourceKey=sslmode"},
+			want:  map[string]string{"ssl": "require", "type": "databases", "provider": "postgresql.example.com"},
+		},
+		{
+			name:  "missing source key",
+			rules: map[string]string{"user": "path={.spec.user}", "pwd": "path={.spec.secret},objectType=Secret,sourceKey=missing"},
+			want:  map[string]string{"user": "admin", "type": "databases", "provider": "postgresql.example.com"},
+		},
+		{
+			name:  "invalid key",
+			rules: map[string]string{"provider": "aws", "user name": "path={.spec.user}"},
+			want:  map[string]string{"provider": "aws", "type": "databases"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			sm := newServiceResourceMap(tt.rules)
+			sed, _ := NewServiceEndpointDefinition(context.Background(), cli, sm, newServiceProxy(), Outputs(sm)[0], instance())
+			g.Expect(sed.Name).To(Equal("db-sed"))
+			g.Expect(sed.Namespace).To(Equal("ns"))
+			g.Expect(stringData(sed)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestNewServiceEndpointDefinitionSpecConventions(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{"type": "postgresql"})
+	sed, _ := NewServiceEndpointDefinition(context.Background(), &fakeClient{}, sm, newServiceProxy(), Outputs(sm)[0], instance())
+	g.Expect(sed.Type).To(Equal(corev1.SecretType("servicebinding.io/postgresql")))
+	g.Expect(sed.Labels).To(Equal(map[string]string{
+		LabelServiceProxy:       "db",
+		LabelServiceResourceMap: "srm",
+		LabelInstanceName:       "db",
+	}))
+
+	sm = newServiceResourceMap(map[string]string{"type": "not a type"})
+	sed, _ = NewServiceEndpointDefinition(context.Background(), &fakeClient{}, sm, newServiceProxy(), Outputs(sm)[0], instance())
+	g.Expect(sed.Type).To(Equal(corev1.SecretTypeOpaque))
+
+	// the SEDs and the metrics of NamespacedServiceResourceMaps are told
+	// apart from the ones of ServiceResourceMaps of the same name
+	renders := func(key string) float64 {
+		var m dto.Metric
+		g.Expect(metrics.SEDRenders.WithLabelValues(key).Write(&m)).To(Succeed())
+		return m.GetCounter().GetValue()
+	}
+	before := renders("srm")
+	sm = newServiceResourceMap(map[string]string{"type": "postgresql"})
+	sm.Namespace = "tenant"
+	sed, _ = NewServiceEndpointDefinition(context.Background(), &fakeClient{}, sm, newServiceProxy(), Outputs(sm)[0], instance())
+	g.Expect(sed.Labels).To(Equal(map[string]string{
+		LabelServiceProxy:                "db",
+		LabelServiceResourceMap:          "srm",
+		LabelServiceResourceMapNamespace: "tenant",
+		LabelInstanceName:                "db",
+	}))
+	g.Expect(renders("tenant/srm")).To(Equal(1.0))
+	g.Expect(renders("srm")).To(Equal(before))
+}
+
+func TestNewServiceEndpointDefinitionRuleErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	sm := newServiceResourceMap(map[string]string{
+		"username": "path={.spec.user}",
+		"password": "path={.spec.missing},objectType=Secret,sourceKey=password",
+		"host":     "path={.spec.host",
+	})
+	sed, failures := NewServiceEndpointDefinition(context.Background(), &fakeClient{}, sm, newServiceProxy(), Outputs(sm)[0], instance())
+	g.Expect(stringData(sed)).To(HaveKeyWithValue("username", "admin"))
+	g.Expect(stringData(sed)).NotTo(HaveKey("password"))
+	g.Expect(stringData(sed)).NotTo(HaveKey("host"))
+
+	g.Expect(failures).To(HaveLen(2))
+	g.Expect(failures[0].Key).To(Equal("host"))
+	g.Expect(failures[1].Key).To(Equal("password"))
+	g.Expect(failures[1].Rule).To(Equal("path={.spec.missing},objectType=Secret,sourceKey=password"))
+}
+
+func TestNewServiceEndpointDefinitionBinary(t *testing.T) {
+	g := NewWithT(t)
+
+	// DER and PKCS#12 payloads are not valid UTF-8
+	crt := []byte{0x30, 0x82, 0x01, 0x0a, 0xff, 0xfe, 0x00, 0x80}
+	key := []byte{0x30, 0x82, 0x04, 0xa4, 0x02, 0x01, 0x00, 0xc3, 0x28}
+	ca := []byte{0x30, 0x82, 0x03, 0x1f, 0xa0, 0x03, 0xe2, 0x82}
+	keystore := []byte{0x30, 0x82, 0x0a, 0x2b, 0xed, 0xa0, 0x80, 0xf8}
+	cli := &fakeClient{objs: []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-tls", Namespace: "ns"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: key, "ca.crt": ca},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-config", Namespace: "ns"},
+			BinaryData: map[string][]byte{"keystore.p12": keystore},
+		},
+	}}
+
+	obj := instance()
+	obj["spec"].(map[string]interface{})["tls"] = "db-tls"
+	sm := newServiceResourceMap(map[string]string{
+		UnnamedKey: "path={.spec.tls},objectType=Secret",
+		"ca":       "path={.spec.tls},objectType=Secret,sourceKey=ca.crt",
+		"keystore": "path={.spec.configs},objectType=ConfigMap,sourceKey=keystore.p12",
+		"encoded":  "path={.spec.tls},objectType=Secret,sourceKey=tls.key | b64enc | b64dec",
+	})
+	sed, failures := NewServiceEndpointDefinition(context.Background(), cli, sm, newServiceProxy(), Outputs(sm)[0], obj)
+	g.Expect(failures).To(BeEmpty())
+	g.Expect(sed.StringData).To(BeEmpty())
+	g.Expect(sed.Data).To(HaveKeyWithValue(corev1.TLSCertKey, crt))
+	g.Expect(sed.Data).To(HaveKeyWithValue(corev1.TLSPrivateKeyKey, key))
+	g.Expect(sed.Data).To(HaveKeyWithValue("ca.crt", ca))
+	g.Expect(sed.Data).To(HaveKeyWithValue("ca", ca))
+	g.Expect(sed.Data).To(HaveKeyWithValue("keystore", keystore))
+	g.Expect(sed.Data).To(HaveKeyWithValue("encoded", key))
+}
@@ -0,0 +1,91 @@
+package binding
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+// Well-known entries and Secret type of the Service Binding specification,
+// see https://github.com/servicebinding/spec#provisioned-service
+const (
+	TypeKey          = "type"
+	ProviderKey      = "provider"
+	SecretTypePrefix = "servicebinding.io/"
+)
+
+// Labels linking a Service Endpoint Definition to the resources it was generated from
+const (
+	LabelServiceProxy       = "servicemapper.binding/service-proxy"
+	LabelServiceResourceMap = "servicemapper.binding/service-resource-map"
+	// LabelServiceResourceMapNamespace is set for NamespacedServiceResourceMaps
+	LabelServiceResourceMapNamespace = "servicemapper.binding/service-resource-map-namespace"
+	LabelInstanceName                = "servicemapper.binding/instance-name"
+	LabelOutput                      = "servicemapper.binding/output"
+)
+
+// MapKey identifies the map in logs and metrics: the name of
+// ServiceResourceMaps, `<namespace>/<name>` for NamespacedServiceResourceMaps
+func MapKey(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap) string {
+	if sm.Namespace == "" {
+		return sm.Name
+	}
+	return sm.Namespace + "/" + sm.Name
+}
+
+// secretType returns the Secret type for the given binding type, falling
+// back to Opaque if the resulting type would not be a qualified name
+func secretType(t string) corev1.SecretType {
+	st := SecretTypePrefix + t
+	if t == "" || len(validation.IsQualifiedName(st)) != 0 {
+		return corev1.SecretTypeOpaque
+	}
+	return corev1.SecretType(st)
+}
+
+// SEDLabels returns the labels of the Service Endpoint Definition. Values that
+// are not valid label values, e.g. names longer than 63 characters, are skipped.
+func SEDLabels(sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, sp *bindingoperatorscoreoscomv1alpha1.ServiceProxy, o Output) map[string]string {
+	ls := map[string]string{}
+	for k, v := range map[string]string{
+		LabelServiceProxy:                sp.Name,
+		LabelServiceResourceMap:          sm.Name,
+		LabelServiceResourceMapNamespace: sm.Namespace,
+		LabelInstanceName:                sp.Spec.ServiceInstance.Name,
+		LabelOutput:                      o.Name,
+	} {
+		if v == "" {
+			continue
+		}
+		if len(validation.IsValidLabelValue(v)) == 0 {
+			ls[k] = v
+		}
+	}
+	return ls
+}
+
+// applySpecConventions drops the entries whose name is not a valid file name,
+// as entries are projected as files in the workloads, and makes sure the
+// mandatory `type` and `provider` entries are present
+func applySpecConventions(ctx context.Context, sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, secrets Values) {
+	l := log.FromContext(ctx)
+
+	for k := range secrets {
+		if errs := validation.IsConfigMapKey(k); len(errs) != 0 {
+			l.Info("dropping invalid Service Endpoint Definition entry", "key", k, "errors", errs)
+			delete(secrets, k)
+		}
+	}
+
+	if len(secrets[TypeKey]) == 0 {
+		secrets[TypeKey] = Value(sm.Spec.ServiceKindReference.Kind)
+	}
+	if len(secrets[ProviderKey]) == 0 {
+		secrets[ProviderKey] = Value(strings.Split(sm.Spec.ServiceKindReference.ApiGroup, "/")[0])
+	}
+}
@@ -0,0 +1,32 @@
+package binding
+
+import (
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+// Extend returns a copy of the ServiceResourceMap whose service_map inherits
+// the rules of the templates, in the order of its `extends`: the rules of
+// later templates replace the ones of earlier templates, and the rules of the
+// service_map replace the rules of all of them. sm is returned as is if it
+// extends no template.
+func Extend(
+	sm *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap,
+	templates []bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate) *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap {
+	if len(templates) == 0 {
+		return sm
+	}
+
+	rules := map[string]string{}
+	for _, t := range templates {
+		for k, v := range t.Spec.ServiceMap {
+			rules[k] = v
+		}
+	}
+	for k, v := range sm.Spec.ServiceMap {
+		rules[k] = v
+	}
+
+	extended := sm.DeepCopy()
+	extended.Spec.ServiceMap = rules
+	return extended
+}
@@ -0,0 +1,45 @@
+package binding
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+func TestExtend(t *testing.T) {
+	g := NewWithT(t)
+
+	template := func(name string, rules map[string]string) bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate {
+		return bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       bindingoperatorscoreoscomv1alpha1.ServiceMapTemplateSpec{ServiceMap: rules},
+		}
+	}
+	postgresql := template("postgresql", map[string]string{
+		"type":     "postgresql",
+		"provider": "example",
+		"uri":      "path=postgresql://{.status.host}:5432",
+	})
+	rds := template("rds", map[string]string{
+		"provider": "aws",
+	})
+
+	sm := newServiceResourceMap(map[string]string{"uri": "path=postgresql://{.status.endpoint}"})
+	sm.Spec.Extends = []string{"postgresql", "rds"}
+	extended := Extend(sm, []bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate{postgresql, rds})
+	g.Expect(extended.Spec.ServiceMap).To(Equal(map[string]string{
+		"type":     "postgresql",
+		"provider": "aws",
+		"uri":      "path=postgresql://{.status.endpoint}",
+	}))
+	g.Expect(sm.Spec.ServiceMap).To(HaveLen(1))
+
+	// maps without service_map inherit the default output
+	bare := newServiceResourceMap(nil)
+	g.Expect(Outputs(Extend(bare, []bindingoperatorscoreoscomv1alpha1.ServiceMapTemplate{rds}))).To(HaveLen(1))
+
+	g.Expect(Extend(sm, nil)).To(BeIdenticalTo(sm))
+}
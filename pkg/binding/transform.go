@@ -0,0 +1,293 @@
+package binding
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var errTransform = errors.New("transform failed")
+
+// Transform is a step of the pipeline transforming the values of a rule:
+//
+//	<name>[(<arg>, ...)][[<index>]]
+//
+// Arguments are double quoted strings or bare words. The index selects one
+// of the values returned by transforms splitting the value.
+type Transform struct {
+	Name string
+	Args []string
+	// Index selects one of the values returned by the transform, -1 if none
+	Index int
+}
+
+// TransformFunc transforms a value given the arguments of the transform. It
+// returns several values if it splits the value. Errors must not disclose
+// the value.
+type TransformFunc func(v string, args []string) ([]string, error)
+
+type transformDef struct {
+	minArgs, maxArgs int
+	fn               TransformFunc
+}
+
+// transforms is the registry of the transforms, by name
+var transforms = map[string]transformDef{
+	"b64dec":     {0, 0, b64dec},
+	"b64enc":     {0, 0, single(func(v string, _ []string) (string, error) { return base64.StdEncoding.EncodeToString([]byte(v)), nil })},
+	"urlenc":     {0, 0, single(func(v string, _ []string) (string, error) { return url.QueryEscape(v), nil })},
+	"urldec":     {0, 0, urldec},
+	"trim":       {0, 1, trim},
+	"trimprefix": {1, 1, single(func(v string, args []string) (string, error) { return strings.TrimPrefix(v, args[0]), nil })},
+	"trimsuffix": {1, 1, single(func(v string, args []string) (string, error) { return strings.TrimSuffix(v, args[0]), nil })},
+	"upper":      {0, 0, single(func(v string, _ []string) (string, error) { return strings.ToUpper(v), nil })},
+	"lower":      {0, 0, single(func(v string, _ []string) (string, error) { return strings.ToLower(v), nil })},
+	"replace":    {2, 2, single(func(v string, args []string) (string, error) { return strings.ReplaceAll(v, args[0], args[1]), nil })},
+	"split":      {1, 1, func(v string, args []string) ([]string, error) { return strings.Split(v, args[0]), nil }},
+	"default":    {1, 1, single(defaultTo)},
+	"int":        {0, 0, single(toInt)},
+	"bool":       {0, 0, single(toBool)},
+}
+
+func single(fn func(string, []string) (string, error)) TransformFunc {
+	return func(v string, args []string) ([]string, error) {
+		s, err := fn(v, args)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+}
+
+func b64dec(v string, _ []string) ([]string, error) {
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		if b, err = base64.RawStdEncoding.DecodeString(v); err != nil {
+			return nil, fmt.Errorf("invalid base64 data")
+		}
+	}
+	return []string{string(b)}, nil
+}
+
+func urldec(v string, _ []string) ([]string, error) {
+	s, err := url.QueryUnescape(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL encoding")
+	}
+	return []string{s}, nil
+}
+
+func trim(v string, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return []string{strings.TrimSpace(v)}, nil
+	}
+	return []string{strings.Trim(v, args[0])}, nil
+}
+
+func defaultTo(v string, args []string) (string, error) {
+	if v == "" {
+		return args[0], nil
+	}
+	return v, nil
+}
+
+// toInt formats a number as an integer, without exponent, e.g. `5.432e+03`
+// as `5432`. The fractional part is truncated.
+func toInt(v string, _ []string) (string, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("not a number")
+	}
+	return strconv.FormatFloat(math.Trunc(f), 'f', -1, 64), nil
+}
+
+func toBool(v string, _ []string) (string, error) {
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return "", fmt.Errorf("not a boolean")
+	}
+	return strconv.FormatBool(b), nil
+}
+
+// startsTransform tells whether s starts with the name of a transform,
+// followed by its arguments, its index, a space or nothing
+func startsTransform(s string) bool {
+	s = strings.TrimLeft(s, " \t")
+	i := strings.IndexAny(s, "([ \t")
+	if i < 0 {
+		i = len(s)
+	}
+	_, ok := transforms[s[:i]]
+	return ok
+}
+
+// ParseTransform parses a step of a transform pipeline
+func ParseTransform(s string) (Transform, error) {
+	s = strings.TrimSpace(s)
+	t := Transform{Index: -1}
+
+	// index
+	if strings.HasSuffix(s, "]") {
+		i := strings.LastIndex(s, "[")
+		if i < 0 {
+			return Transform{}, fmt.Errorf("invalid transform '%s'", s)
+		}
+		n, err := strconv.Atoi(s[i+1 : len(s)-1])
+		if err != nil || n < 0 {
+			return Transform{}, fmt.Errorf("invalid index in transform '%s'", s)
+		}
+		t.Index, s = n, s[:i]
+	}
+
+	// arguments
+	if strings.HasSuffix(s, ")") {
+		i := strings.Index(s, "(")
+		if i < 0 {
+			return Transform{}, fmt.Errorf("invalid transform '%s'", s)
+		}
+		args, err := parseArgs(s[i+1 : len(s)-1])
+		if err != nil {
+			return Transform{}, fmt.Errorf("invalid arguments in transform '%s': %v", s, err)
+		}
+		t.Args, s = args, s[:i]
+	}
+
+	t.Name = strings.TrimSpace(s)
+	def, ok := transforms[t.Name]
+	switch {
+	case !ok:
+		return Transform{}, fmt.Errorf("unknown transform '%s'", t.Name)
+	case len(t.Args) < def.minArgs || len(t.Args) > def.maxArgs:
+		return Transform{}, fmt.Errorf("transform '%s' takes %d to %d arguments, got %d", t.Name, def.minArgs, def.maxArgs, len(t.Args))
+	}
+	return t, nil
+}
+
+// parseArgs parses comma separated arguments, double quoted or bare
+func parseArgs(s string) ([]string, error) {
+	var args []string
+	for s = strings.TrimSpace(s); s != ""; {
+		var arg string
+		if strings.HasPrefix(s, `"`) {
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			arg, _ = strconv.Unquote(q)
+			s = strings.TrimSpace(s[len(q):])
+		} else {
+			i := strings.Index(s, ",")
+			if i < 0 {
+				i = len(s)
+			}
+			arg = strings.TrimSpace(s[:i])
+			s = s[i:]
+			if arg == "" {
+				return nil, fmt.Errorf("empty argument")
+			}
+		}
+		args = append(args, arg)
+
+		if s == "" {
+			break
+		}
+		if !strings.HasPrefix(s, ",") {
+			return nil, fmt.Errorf("expected ',' before '%s'", s)
+		}
+		s = strings.TrimSpace(s[1:])
+		if s == "" {
+			return nil, fmt.Errorf("empty argument")
+		}
+	}
+	return args, nil
+}
+
+// Apply runs the transform on the value
+func (t Transform) Apply(v string) (string, error) {
+	out, err := transforms[t.Name].fn(v, t.Args)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", errTransform, t.Name, err)
+	}
+
+	if t.Index >= 0 {
+		if t.Index >= len(out) {
+			return "", fmt.Errorf("%w: %s: index %d out of %d values", errTransform, t.Name, t.Index, len(out))
+		}
+		return out[t.Index], nil
+	}
+	if len(out) != 1 {
+		return "", fmt.Errorf("%w: %s: returned %d values, select one with [<index>]", errTransform, t.Name, len(out))
+	}
+	return out[0], nil
+}
+
+// String returns the transform in the pipeline grammar
+func (t Transform) String() string {
+	s := t.Name
+	if len(t.Args) > 0 {
+		quoted := make([]string, len(t.Args))
+		for i, a := range t.Args {
+			quoted[i] = strconv.Quote(a)
+		}
+		s += "(" + strings.Join(quoted, ", ") + ")"
+	}
+	if t.Index >= 0 {
+		s += "[" + strconv.Itoa(t.Index) + "]"
+	}
+	return s
+}
+
+// transform runs the pipeline on every value
+func transform(pipeline []Transform, vs Values) (Values, error) {
+	if len(pipeline) == 0 {
+		return vs, nil
+	}
+
+	out := make(Values, len(vs))
+	for k, v := range vs {
+		s := v.Reveal()
+		for _, t := range pipeline {
+			var err error
+			if s, err = t.Apply(s); err != nil {
+				return nil, err
+			}
+		}
+		out[k] = Value(s)
+	}
+	return out, nil
+}
+
+// splitPipeline splits a rule from its transforms: the pipes preceded by a
+// space, outside braces and quotes, separate them. In literal values, only
+// the pipes followed by the name of a transform do, the others are part of
+// the value, e.g. `dev | prod`.
+func splitPipeline(v string) (string, []string) {
+	var parts []string
+	literal := !isJsonpath(v) && !strings.HasPrefix(v, "compose=")
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\' && quoted:
+			// escaped character of a quoted argument
+			i++
+		case c == '"' && depth == 0:
+			quoted = !quoted
+		case c == '{' && !quoted:
+			depth++
+		case c == '}' && !quoted:
+			depth--
+		case c == '|' && depth == 0 && !quoted && i > 0 && (v[i-1] == ' ' || v[i-1] == '\t') && (!literal || startsTransform(v[i+1:])):
+			parts = append(parts, v[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, v[start:])
+	if len(parts) == 1 {
+		return v, nil
+	}
+	return strings.TrimRight(parts[0], " \t"), parts[1:]
+}
@@ -0,0 +1,83 @@
+package binding
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		pipeline string
+		value    string
+		want     string
+		wantErr  string
+	}{
+		{pipeline: "b64dec", value: "czNjcjN0", want: "s3cr3t"},
+		{pipeline: "b64dec", value: "czNjcjN0Lg", want: "s3cr3t."},
+		{pipeline: "b64dec", value: "not base64!", wantErr: "b64dec: invalid base64 data"},
+		{pipeline: "b64enc", value: "s3cr3t", want: "czNjcjN0"},
+		{pipeline: "urlenc", value: "p@ss word/1", want: "p%40ss+word%2F1"},
+		{pipeline: "urldec", value: "p%40ss+word%2F1", want: "p@ss word/1"},
+		{pipeline: "trim", value: " db \n", want: "db"},
+		{pipeline: `trim("/")`, value: "/db/", want: "db"},
+		{pipeline: `trimprefix("https://") | trimsuffix("/")`, value: "https://db.example.com/", want: "db.example.com"},
+		{pipeline: "upper", value: "db", want: "DB"},
+		{pipeline: "lower", value: "DB", want: "db"},
+		{pipeline: `replace(".", "-")`, value: "db.example.com", want: "db-example-com"},
+		{pipeline: `split(":")[0]`, value: "db.example.com:5432", want: "db.example.com"},
+		{pipeline: `split(":")[1] | int`, value: "db.example.com:5432", want: "5432"},
+		{pipeline: `split(":")[2]`, value: "db.example.com:5432", wantErr: "split: index 2 out of 2 values"},
+		{pipeline: `split(":")`, value: "db.example.com:5432", wantErr: "split: returned 2 values, select one with [<index>]"},
+		{pipeline: "default(5432)", value: "", want: "5432"},
+		{pipeline: "default(5432)", value: "3306", want: "3306"},
+		{pipeline: "int", value: "5.432e+03", want: "5432"},
+		{pipeline: "int", value: "3.9", want: "3"},
+		{pipeline: "int", value: "secret", wantErr: "int: not a number"},
+		{pipeline: "bool", value: "True", want: "true"},
+		{pipeline: "bool", value: "secret", wantErr: "bool: not a boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pipeline, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r, err := ParseRule("path={.spec.value} | " + tt.pipeline)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			vs, err := transform(r.Transforms, Values{"v": Value(tt.value)})
+			if tt.wantErr != "" {
+				g.Expect(errors.Is(err, errTransform)).To(BeTrue())
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+				// the errors do not disclose the values
+				g.Expect(err.Error()).NotTo(ContainSubstring(tt.value))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(vs["v"].Reveal()).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestNewServiceEndpointDefinitionTransforms(t *testing.T) {
+	g := NewWithT(t)
+
+	obj := instance()
+	obj["spec"].(map[string]interface{})["url"] = "https://db.example.com:5432"
+	sm := newServiceResourceMap(map[string]string{
+		"type": "postgresql | upper",
+		"host": `path={.spec.url} | trimprefix("https://") | split(":")[0]`,
+		"port": `path={.spec.url} | split(":")[2] | int`,
+		"user": `path={.spec.user} | int`,
+	})
+
+	sed, failures := NewServiceEndpointDefinition(context.TODO(), &fakeClient{}, sm, newServiceProxy(), Outputs(sm)[0], obj)
+	g.Expect(stringData(sed)).To(HaveKeyWithValue("type", "POSTGRESQL"))
+	g.Expect(stringData(sed)).To(HaveKeyWithValue("host", "db.example.com"))
+	g.Expect(stringData(sed)).To(HaveKeyWithValue("port", "5432"))
+	g.Expect(failures).To(HaveLen(1))
+	g.Expect(failures[0].Key).To(Equal("user"))
+	g.Expect(failures[0].Reason()).To(Equal("TransformFailed"))
+}
@@ -0,0 +1,148 @@
+package claims
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationPin pins a service instance to the map claiming it, named by its
+// name, or by `<namespace>/<name>` for NamespacedServiceResourceMaps
+const AnnotationPin = "servicemapper.binding/service-resource-map"
+
+// Map is a map selecting an instance
+type Map struct {
+	// Key is the name of the map, `<namespace>/<name>` if namespaced
+	Key        string
+	Namespaced bool
+	Priority   int32
+	Created    metav1.Time
+}
+
+// Winner returns the map claiming an instance among the maps selecting it:
+// the map pinned by the instance if it selects it, otherwise the first map
+// when ordered by
+//
+//   - scope: namespaced maps first;
+//   - priority: highest first;
+//   - age: oldest first;
+//   - key: lowest first.
+//
+// maps must not be empty.
+func Winner(maps []Map, pin string) Map {
+	for _, m := range maps {
+		if pin != "" && m.Key == pin {
+			return m
+		}
+	}
+
+	w := maps[0]
+	for _, m := range maps[1:] {
+		if before(m, w) {
+			w = m
+		}
+	}
+	return w
+}
+
+func before(a, b Map) bool {
+	switch {
+	case a.Namespaced != b.Namespaced:
+		return a.Namespaced
+	case a.Priority != b.Priority:
+		return a.Priority > b.Priority
+	case !a.Created.Equal(&b.Created):
+		return a.Created.Before(&b.Created)
+	}
+	return a.Key < b.Key
+}
+
+// Contested records, for each map, the instances it selects that are claimed
+// by another map
+type Contested struct {
+	mu sync.Mutex
+	// claims maps the keys of the maps to their instances claimed by other
+	// maps, and to the keys of these maps
+	claims map[string]map[string]string
+}
+
+// NewContested returns an empty Contested
+func NewContested() *Contested {
+	return &Contested{claims: map[string]map[string]string{}}
+}
+
+// Set records that the instance selected by the map is claimed by the
+// winner, or by the map itself if winner is empty or the map. It returns
+// true if the instances claimed from the map changed.
+func (c *Contested) Set(key, instance, winner string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	claimed := c.claims[key]
+	if winner == "" || winner == key {
+		if _, ok := claimed[instance]; !ok {
+			return false
+		}
+		delete(claimed, instance)
+		if len(claimed) == 0 {
+			delete(c.claims, key)
+		}
+		return true
+	}
+
+	if claimed[instance] == winner {
+		return false
+	}
+	if claimed == nil {
+		claimed = map[string]string{}
+		c.claims[key] = claimed
+	}
+	claimed[instance] = winner
+	return true
+}
+
+// Forget removes the records of the map
+func (c *Contested) Forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.claims, key)
+}
+
+// Of returns the instances selected by the map and claimed by another map
+func (c *Contested) Of(key string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	claimed := make(map[string]string, len(c.claims[key]))
+	for i, w := range c.claims[key] {
+		claimed[i] = w
+	}
+	return claimed
+}
+
+// Message describes the instances claimed by other maps, listing at most
+// max instances
+func Message(claimed map[string]string, max int) string {
+	instances := make([]string, 0, len(claimed))
+	for i := range claimed {
+		instances = append(instances, i)
+	}
+	sort.Strings(instances)
+
+	var b strings.Builder
+	b.WriteString("instances claimed by other maps:")
+	for n, i := range instances {
+		if n == max {
+			fmt.Fprintf(&b, " and %d more", len(instances)-max)
+			break
+		}
+		if n > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, " %s by '%s'", i, claimed[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package claims
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWinner(t *testing.T) {
+	old := metav1.NewTime(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	recent := metav1.NewTime(old.Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		maps   []Map
+		pin    string
+		winner string
+	}{
+		{
+			name:   "single",
+			maps:   []Map{{Key: "a"}},
+			winner: "a",
+		},
+		{
+			name:   "highest priority",
+			maps:   []Map{{Key: "a", Priority: 1, Created: old}, {Key: "b", Priority: 10, Created: recent}},
+			winner: "b",
+		},
+		{
+			name:   "oldest on equal priorities",
+			maps:   []Map{{Key: "a", Created: recent}, {Key: "b", Created: old}},
+			winner: "b",
+		},
+		{
+			name:   "lowest key on equal ages",
+			maps:   []Map{{Key: "b", Created: old}, {Key: "a", Created: old}},
+			winner: "a",
+		},
+		{
+			name:   "namespaced first",
+			maps:   []Map{{Key: "a", Priority: 10, Created: old}, {Key: "ns/b", Namespaced: true, Created: recent}},
+			winner: "ns/b",
+		},
+		{
+			name:   "pinned",
+			maps:   []Map{{Key: "ns/a", Namespaced: true}, {Key: "b"}},
+			pin:    "b",
+			winner: "b",
+		},
+		{
+			name:   "pinned map not selecting the instance",
+			maps:   []Map{{Key: "a", Priority: 1}, {Key: "b"}},
+			pin:    "c",
+			winner: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(Winner(tt.maps, tt.pin).Key).To(Equal(tt.winner))
+		})
+	}
+}
+
+func TestContested(t *testing.T) {
+	g := NewWithT(t)
+
+	c := NewContested()
+	g.Expect(c.Set("a", "ns/db", "a")).To(BeFalse())
+	g.Expect(c.Set("a", "ns/db", "b")).To(BeTrue())
+	g.Expect(c.Set("a", "ns/db", "b")).To(BeFalse())
+	g.Expect(c.Set("a", "ns/cache", "ns/c")).To(BeTrue())
+	g.Expect(c.Of("a")).To(Equal(map[string]string{"ns/db": "b", "ns/cache": "ns/c"}))
+	g.Expect(Message(c.Of("a"), 10)).To(Equal("instances claimed by other maps: ns/cache by 'ns/c', ns/db by 'b'"))
+	g.Expect(Message(c.Of("a"), 1)).To(Equal("instances claimed by other maps: ns/cache by 'ns/c' and 1 more"))
+
+	g.Expect(c.Set("a", "ns/db", "")).To(BeTrue())
+	g.Expect(c.Of("a")).To(Equal(map[string]string{"ns/cache": "ns/c"}))
+
+	c.Forget("a")
+	g.Expect(c.Of("a")).To(BeEmpty())
+}
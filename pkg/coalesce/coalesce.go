@@ -0,0 +1,118 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Func is the work scheduled for a key
+type Func func(ctx context.Context)
+
+// Coalescer runs the work scheduled for a key once the window following its
+// first scheduling has elapsed. Work scheduled meanwhile replaces the pending
+// one, so that a burst of changes is handled once, and the work of a key
+// never runs concurrently.
+//
+// Coalescer is a manager.Runnable requiring leader election, as the informers
+// scheduling the work. Pending work is dropped when it stops.
+type Coalescer struct {
+	window  time.Duration
+	workers int
+	queue   workqueue.DelayingInterface
+
+	mu      sync.Mutex
+	pending map[string]Func
+}
+
+// New returns a Coalescer running the work with the given number of workers
+func New(window time.Duration, workers int) *Coalescer {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Coalescer{
+		window:  window,
+		workers: workers,
+		queue:   workqueue.NewDelayingQueue(),
+		pending: map[string]Func{},
+	}
+}
+
+// Schedule runs fn once the window has elapsed, unless other work is
+// scheduled for the key meanwhile. It returns false if fn replaced pending
+// work.
+func (c *Coalescer) Schedule(key string, fn Func) bool {
+	c.mu.Lock()
+	_, waiting := c.pending[key]
+	c.pending[key] = fn
+	c.mu.Unlock()
+
+	if !waiting {
+		c.queue.AddAfter(key, c.window)
+	}
+	return !waiting
+}
+
+// Now runs fn as soon as possible, replacing the pending work of the key. It
+// returns false if fn replaced pending work.
+func (c *Coalescer) Now(key string, fn Func) bool {
+	c.mu.Lock()
+	_, waiting := c.pending[key]
+	c.pending[key] = fn
+	c.mu.Unlock()
+
+	c.queue.Add(key)
+	return !waiting
+}
+
+// Pending returns the number of keys whose work is waiting to run
+func (c *Coalescer) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// Start runs the workers until the context is cancelled
+func (c *Coalescer) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	wg.Add(c.workers)
+	for w := 0; w < c.workers; w++ {
+		go func() {
+			defer wg.Done()
+			for c.process(ctx) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	c.queue.ShutDown()
+	wg.Wait()
+	return nil
+}
+
+// NeedLeaderElection makes the Coalescer run on the elected replica only
+func (c *Coalescer) NeedLeaderElection() bool {
+	return true
+}
+
+func (c *Coalescer) process(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	// the key is queued again when work is scheduled while it runs, and may
+	// be queued with no pending work when Now overtook Schedule
+	c.mu.Lock()
+	fn, ok := c.pending[key.(string)]
+	delete(c.pending, key.(string))
+	c.mu.Unlock()
+
+	if ok {
+		fn(ctx)
+	}
+	return true
+}
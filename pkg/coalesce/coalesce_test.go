@@ -0,0 +1,101 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// runs records the work run by a Coalescer
+type runs struct {
+	mu   sync.Mutex
+	runs []string
+}
+
+func (r *runs) fn(name string) Func {
+	return func(context.Context) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.runs = append(r.runs, name)
+	}
+}
+
+func (r *runs) Runs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.runs...)
+}
+
+func TestCoalescer(t *testing.T) {
+	g := NewWithT(t)
+
+	c := New(100*time.Millisecond, 2)
+	g.Expect(c.NeedLeaderElection()).To(BeTrue())
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		_ = c.Start(ctx)
+	}()
+
+	// the work scheduled within the window replaces the pending one
+	r := &runs{}
+	g.Expect(c.Schedule("a", r.fn("a1"))).To(BeTrue())
+	g.Expect(c.Schedule("a", r.fn("a2"))).To(BeFalse())
+	g.Expect(c.Schedule("b", r.fn("b1"))).To(BeTrue())
+	g.Expect(c.Pending()).To(Equal(2))
+	g.Consistently(r.Runs, 50*time.Millisecond).Should(BeEmpty())
+	g.Eventually(r.Runs).Should(ConsistOf("a2", "b1"))
+	g.Expect(c.Pending()).To(BeZero())
+
+	// the work run now replaces the pending one
+	g.Expect(c.Schedule("a", r.fn("a3"))).To(BeTrue())
+	g.Expect(c.Now("a", r.fn("a4"))).To(BeFalse())
+	g.Eventually(r.Runs, 50*time.Millisecond).Should(ContainElement("a4"))
+	g.Consistently(r.Runs, 200*time.Millisecond).ShouldNot(ContainElement("a3"))
+
+	cancel()
+	g.Eventually(stopped).Should(BeClosed())
+}
+
+func TestCoalescerRunsKeysSequentially(t *testing.T) {
+	g := NewWithT(t)
+
+	c := New(0, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.Start(ctx)
+	}()
+
+	var mu sync.Mutex
+	running, overlaps, done := 0, 0, 0
+	fn := func(context.Context) {
+		mu.Lock()
+		running++
+		if running > 1 {
+			overlaps++
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		done++
+		mu.Unlock()
+	}
+	for i := 0; i < 50; i++ {
+		c.Now("key", fn)
+		time.Sleep(100 * time.Microsecond)
+	}
+
+	g.Eventually(c.Pending).Should(BeZero())
+	mu.Lock()
+	defer mu.Unlock()
+	g.Expect(overlaps).To(BeZero())
+	g.Expect(done).To(BeNumerically(">", 0))
+}
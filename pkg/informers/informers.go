@@ -0,0 +1,522 @@
+package informers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift-app-service-poc/service-mapper/pkg/metrics"
+)
+
+// Handler handles the events of the instances watched by an informer. The
+// context is cancelled when the informer stops.
+type Handler interface {
+	OnAdd(ctx context.Context, obj *unstructured.Unstructured)
+	OnUpdate(ctx context.Context, past, future *unstructured.Unstructured)
+	OnDelete(ctx context.Context, obj *unstructured.Unstructured)
+}
+
+// HandlerFuncs is an adapter to use functions as a Handler
+type HandlerFuncs struct {
+	AddFunc    func(ctx context.Context, obj *unstructured.Unstructured)
+	UpdateFunc func(ctx context.Context, past, future *unstructured.Unstructured)
+	DeleteFunc func(ctx context.Context, obj *unstructured.Unstructured)
+}
+
+func (h HandlerFuncs) OnAdd(ctx context.Context, obj *unstructured.Unstructured) {
+	if h.AddFunc != nil {
+		h.AddFunc(ctx, obj)
+	}
+}
+
+func (h HandlerFuncs) OnUpdate(ctx context.Context, past, future *unstructured.Unstructured) {
+	if h.UpdateFunc != nil {
+		h.UpdateFunc(ctx, past, future)
+	}
+}
+
+func (h HandlerFuncs) OnDelete(ctx context.Context, obj *unstructured.Unstructured) {
+	if h.DeleteFunc != nil {
+		h.DeleteFunc(ctx, obj)
+	}
+}
+
+// NotifyFunc is called with the name of a registration whose State changed
+type NotifyFunc func(ctx context.Context, name string)
+
+// State is the state of the informer of a registration
+type State struct {
+	// Synced is true once the informer has synced and the instances it
+	// found have been handed to the registration
+	Synced bool
+	// Err is the last error of the informer's list and watch, nil once they
+	// succeed again
+	Err error
+}
+
+// Options select the instances watched for a registration and the fields
+// kept in the cache
+type Options struct {
+	// Namespace restricts the watched instances to a namespace, the
+	// namespace of the Manager if empty
+	Namespace string
+	// LabelSelector restricts the watched instances to the matching ones
+	LabelSelector string
+	// FieldSelector restricts the watched instances to the matching ones
+	FieldSelector string
+	// Fields are the paths of the instance fields read by the handler. The
+	// other fields are pruned from the cache, except the metadata. A nil
+	// Fields keeps whole instances.
+	Fields [][]string
+}
+
+// key identifies the informers shared by registrations
+type key struct {
+	gvr           schema.GroupVersionResource
+	namespace     string
+	labelSelector string
+	fieldSelector string
+}
+
+// Manager runs the dynamic informers watching the service instances: one
+// informer per GroupVersionResource, namespace and selectors, shared by every
+// registration for them. Shared informers keep the fields read by any of
+// their registrations, and are replaced by a new informer when a
+// registration reads fields they prune.
+//
+// Manager is a manager.Runnable requiring leader election, so informers only
+// run on the elected replica and are all stopped when the manager stops.
+// Registrations made before the Manager is started are kept until then.
+//
+// Events are only handed to a registration once its informer has synced: the
+// instances found by the initial list are then replayed as additions.
+type Manager struct {
+	client    dynamic.Interface
+	resync    time.Duration
+	namespace string
+	notify    NotifyFunc
+
+	mu sync.Mutex
+	// ctx is the context of the running Manager, nil until it is started
+	ctx           context.Context
+	informers     map[key]*informer
+	registrations map[string]key
+	wg            sync.WaitGroup
+}
+
+type informer struct {
+	key
+	fields   fieldSet
+	informer cache.SharedIndexInformer
+	// cancel stops the informer, nil until it runs
+	cancel        context.CancelFunc
+	err           error
+	registrations map[string]*registration
+}
+
+type registration struct {
+	handler Handler
+	synced  bool
+}
+
+// NewManager returns a Manager whose informers watch the given namespace, or
+// all namespaces if empty, unless the options of a registration select its
+// namespace. notify, if not nil, is called when the State of a
+// registration changes.
+func NewManager(client dynamic.Interface, resync time.Duration, namespace string, notify NotifyFunc) *Manager {
+	return &Manager{
+		client:        client,
+		resync:        resync,
+		namespace:     namespace,
+		notify:        notify,
+		informers:     map[key]*informer{},
+		registrations: map[string]key{},
+	}
+}
+
+// Start runs the registered informers until the context is cancelled, then
+// waits for all of them to stop
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	m.ctx = ctx
+	for _, i := range m.informers {
+		m.run(i)
+	}
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	m.ctx = nil
+	for _, i := range m.informers {
+		m.stop(i)
+	}
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	return nil
+}
+
+// NeedLeaderElection makes the Manager run on the elected replica only
+func (m *Manager) NeedLeaderElection() bool {
+	return true
+}
+
+// Started returns true if the Manager is running
+func (m *Manager) Started() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ctx != nil
+}
+
+// Register makes h handle the events of the instances of gvr selected by the
+// options, replacing the handler of any previous registration with the same
+// name. It returns true if the name was not registered for gvr and the same
+// namespace and selectors yet.
+//
+// The instances are handed to new registrations as additions as soon as the
+// informer has synced, which may be before Register returns. Registrations
+// reading fields pruned by the informer wait for the informer replacing it
+// to sync, as do the other registrations of the informer.
+func (m *Manager) Register(name string, gvr schema.GroupVersionResource, opts Options, h Handler) bool {
+	m.mu.Lock()
+
+	k := key{gvr: gvr, namespace: opts.Namespace, labelSelector: opts.LabelSelector, fieldSelector: opts.FieldSelector}
+	if k.namespace == "" {
+		k.namespace = m.namespace
+	}
+	if past, ok := m.registrations[name]; ok && past != k {
+		m.unregister(name)
+	}
+
+	fields := newFieldSet(opts.Fields)
+	i, ok := m.informers[k]
+	switch {
+	case !ok:
+		i = m.newInformer(k, fields)
+		m.informers[k] = i
+		if m.ctx != nil {
+			m.run(i)
+		}
+	case !i.fields.covers(fields):
+		i = m.replace(i, fields)
+	}
+
+	if r, ok := i.registrations[name]; ok {
+		r.handler = h
+		m.mu.Unlock()
+		return false
+	}
+
+	r := &registration{handler: h}
+	i.registrations[name] = r
+	m.registrations[name] = k
+
+	// the informer already synced, the registration won't be notified
+	var replay []interface{}
+	ctx := m.ctx
+	if i.cancel != nil && i.informer.HasSynced() {
+		r.synced = true
+		replay = i.informer.GetStore().List()
+	}
+	m.mu.Unlock()
+
+	i.replay(ctx, h, replay)
+	return true
+}
+
+// Replay hands every instance known by the informer of a synced registration
+// to its handler as an addition
+func (m *Manager) Replay(name string) {
+	m.mu.Lock()
+	k, ok := m.registrations[name]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+
+	i := m.informers[k]
+	r := i.registrations[name]
+	var replay []interface{}
+	if r.synced {
+		replay = i.informer.GetStore().List()
+	}
+	ctx, h := m.ctx, r.handler
+	m.mu.Unlock()
+
+	i.replay(ctx, h, replay)
+}
+
+// Unregister removes the registration, stopping its informer if it is no
+// longer used. It returns the handler of the registration, nil if the name
+// was not registered.
+func (m *Manager) Unregister(name string) Handler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.unregister(name)
+}
+
+// State returns the state of the informer of a registration, false if the
+// name is not registered
+func (m *Manager) State(name string) (State, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.registrations[name]
+	if !ok {
+		return State{}, false
+	}
+	i := m.informers[k]
+	return State{Synced: i.registrations[name].synced, Err: i.err}, true
+}
+
+// Handler returns the handler of a registration, nil if the name is not
+// registered
+func (m *Manager) Handler(name string) Handler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.registrations[name]
+	if !ok {
+		return nil
+	}
+	return m.informers[k].registrations[name].handler
+}
+
+func (m *Manager) unregister(name string) Handler {
+	k, ok := m.registrations[name]
+	if !ok {
+		return nil
+	}
+	delete(m.registrations, name)
+
+	i := m.informers[k]
+	h := i.registrations[name].handler
+	delete(i.registrations, name)
+	if len(i.registrations) == 0 {
+		m.stop(i)
+		delete(m.informers, k)
+	}
+	return h
+}
+
+// replace stops the informer and moves its registrations to a new informer
+// keeping the given fields too, m.mu must be held
+func (m *Manager) replace(i *informer, fields fieldSet) *informer {
+	m.stop(i)
+
+	n := m.newInformer(i.key, i.fields.union(fields))
+	n.registrations, i.registrations = i.registrations, map[string]*registration{}
+	for _, r := range n.registrations {
+		r.synced = false
+	}
+
+	m.informers[i.key] = n
+	if m.ctx != nil {
+		m.run(n)
+	}
+	return n
+}
+
+func (m *Manager) newInformer(k key, fields fieldSet) *informer {
+	i := &informer{
+		key:           k,
+		fields:        fields,
+		registrations: map[string]*registration{},
+	}
+
+	// successful lists and watches clear the errors of the previous ones
+	ri := m.client.Resource(k.gvr).Namespace(k.namespace)
+	selectors := func(o *metav1.ListOptions) {
+		o.LabelSelector = k.labelSelector
+		o.FieldSelector = k.fieldSelector
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(o metav1.ListOptions) (runtime.Object, error) {
+			selectors(&o)
+			l, err := ri.List(context.TODO(), o)
+			if err == nil {
+				m.setError(i, nil)
+			}
+			return l, err
+		},
+		WatchFunc: func(o metav1.ListOptions) (watch.Interface, error) {
+			selectors(&o)
+			w, err := ri.Watch(context.TODO(), o)
+			if err == nil {
+				m.setError(i, nil)
+			}
+			return w, err
+		},
+	}
+
+	i.informer = cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, m.resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	_ = i.informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		cache.DefaultWatchErrorHandler(r, err)
+		m.setError(i, err)
+	})
+	_ = i.informer.SetTransform(fields.transform)
+	i.informer.AddEventHandler(m.dispatcher(i))
+	return i
+}
+
+// run starts the informer, m.mu must be held
+func (m *Manager) run(i *informer) {
+	c, cancel := context.WithCancel(m.ctx)
+	i.cancel = cancel
+
+	log.FromContext(m.ctx).Info("starting informer", "GroupVersionResource", i.gvr, "namespace", i.namespace,
+		"labelSelector", i.labelSelector, "fieldSelector", i.fieldSelector)
+	metrics.ActiveInformers.Inc()
+	m.wg.Add(2)
+	go func() {
+		defer m.wg.Done()
+		defer metrics.ActiveInformers.Dec()
+		i.informer.Run(c.Done())
+	}()
+	go func() {
+		defer m.wg.Done()
+		if cache.WaitForCacheSync(c.Done(), i.informer.HasSynced) {
+			m.synced(c, i)
+		}
+	}()
+}
+
+// stop stops the informer if running, m.mu must be held
+func (m *Manager) stop(i *informer) {
+	if i.cancel != nil {
+		i.cancel()
+	}
+}
+
+// synced hands the instances found by the initial list to the registrations
+// waiting for the informer to sync
+func (m *Manager) synced(ctx context.Context, i *informer) {
+	m.mu.Lock()
+	replay := i.informer.GetStore().List()
+	waiting := map[string]Handler{}
+	for n, r := range i.registrations {
+		if !r.synced {
+			r.synced = true
+			waiting[n] = r.handler
+		}
+	}
+	m.mu.Unlock()
+
+	for n, h := range waiting {
+		i.replay(ctx, h, replay)
+		m.notifyAll(ctx, n)
+	}
+}
+
+// setError records the last error of the informer's list and watch,
+// notifying the registrations when it starts or stops failing
+func (m *Manager) setError(i *informer, err error) {
+	m.mu.Lock()
+	changed := (i.err == nil) != (err == nil)
+	i.err = err
+	ctx := m.ctx
+	names := make([]string, 0, len(i.registrations))
+	for n := range i.registrations {
+		names = append(names, n)
+	}
+	m.mu.Unlock()
+
+	if changed && ctx != nil {
+		m.notifyAll(ctx, names...)
+	}
+}
+
+func (m *Manager) notifyAll(ctx context.Context, names ...string) {
+	if m.notify == nil {
+		return
+	}
+	for _, n := range names {
+		m.notify(ctx, n)
+	}
+}
+
+func (i *informer) replay(ctx context.Context, h Handler, objs []interface{}) {
+	for _, obj := range objs {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			h.OnAdd(i.context(ctx), u)
+		}
+	}
+}
+
+// context returns the context handed to the handlers of the informer
+func (i *informer) context(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return log.IntoContext(ctx, log.Log.WithName("informers").WithValues("GroupVersionResource", i.gvr))
+}
+
+// dispatcher forwards the events of the informer to the synced registrations
+func (m *Manager) dispatcher(i *informer) cache.ResourceEventHandler {
+	gvrLabel := i.gvr.GroupVersion().String() + "/" + i.gvr.Resource
+
+	handlers := func(event string) (context.Context, []Handler) {
+		metrics.InformerEvents.WithLabelValues(gvrLabel, event).Inc()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		hs := make([]Handler, 0, len(i.registrations))
+		for _, r := range i.registrations {
+			if r.synced {
+				hs = append(hs, r.handler)
+			}
+		}
+		return i.context(m.ctx), hs
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ctx, hs := handlers("add")
+			for _, h := range hs {
+				h.OnAdd(ctx, obj.(*unstructured.Unstructured))
+			}
+		},
+		UpdateFunc: func(past, future interface{}) {
+			ctx, hs := handlers("update")
+			for _, h := range hs {
+				h.OnUpdate(ctx, past.(*unstructured.Unstructured), future.(*unstructured.Unstructured))
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			ctx, hs := handlers("delete")
+			u, err := deleted(obj)
+			if err != nil {
+				log.FromContext(ctx).Error(err, "can not handle deletion")
+				return
+			}
+			for _, h := range hs {
+				h.OnDelete(ctx, u)
+			}
+		},
+	}
+}
+
+// deleted returns the deleted instance, unwrapping the final state of
+// instances whose deletion was missed by the watch
+func deleted(obj interface{}) (*unstructured.Unstructured, error) {
+	if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = t.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object of type %T", obj)
+	}
+	return u, nil
+}
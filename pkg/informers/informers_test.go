@@ -0,0 +1,418 @@
+package informers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+var gvr = schema.GroupVersionResource{Group: "postgresql.example.com", Version: "v1", Resource: "databases"}
+
+// fakeDynamic lists the given instances and serves the watches from a
+// FakeWatcher, any other call panics
+type fakeDynamic struct {
+	dynamic.Interface
+	dynamic.NamespaceableResourceInterface
+
+	objs    []unstructured.Unstructured
+	watcher *watch.FakeWatcher
+	watches int
+	// namespace is the namespace of the last informer
+	namespace string
+	// selectors are the label and field selectors of the last list
+	selectors []string
+	// failures is the number of watches failing before one succeeds
+	failures int
+	mu       sync.Mutex
+}
+
+func (f *fakeDynamic) Resource(schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return f
+}
+
+func (f *fakeDynamic) Namespace(ns string) dynamic.ResourceInterface {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.namespace = ns
+	return f
+}
+
+func (f *fakeDynamic) List(_ context.Context, o metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	f.mu.Lock()
+	f.selectors = []string{o.LabelSelector, o.FieldSelector}
+	f.mu.Unlock()
+
+	items := make([]unstructured.Unstructured, 0, len(f.objs))
+	for _, u := range f.objs {
+		items = append(items, *u.DeepCopy())
+	}
+	l := &unstructured.UnstructuredList{Items: items}
+	l.SetAPIVersion("v1")
+	l.SetKind("List")
+	l.SetResourceVersion("1")
+	return l, nil
+}
+
+func (f *fakeDynamic) Watch(context.Context, metav1.ListOptions) (watch.Interface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watches++
+	if f.failures > 0 {
+		f.failures--
+		return nil, errors.New("watch failed")
+	}
+	return f.watcher, nil
+}
+
+func (f *fakeDynamic) Watches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.watches
+}
+
+func (f *fakeDynamic) Selectors() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.selectors
+}
+
+func (f *fakeDynamic) LastNamespace() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.namespace
+}
+
+func instance(name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("postgresql.example.com/v1")
+	u.SetKind("Database")
+	u.SetNamespace("ns")
+	u.SetName(name)
+	u.SetResourceVersion("1")
+	return u
+}
+
+// recorder records the name of the instances it handles
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) handler() Handler {
+	record := func(e string, u *unstructured.Unstructured) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, e+":"+u.GetName())
+	}
+	return HandlerFuncs{
+		AddFunc:    func(_ context.Context, u *unstructured.Unstructured) { record("add", u) },
+		UpdateFunc: func(_ context.Context, _, u *unstructured.Unstructured) { record("update", u) },
+		DeleteFunc: func(_ context.Context, u *unstructured.Unstructured) { record("delete", u) },
+	}
+}
+
+func (r *recorder) Events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.events...)
+}
+
+func (r *recorder) notify(_ context.Context, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "notify:"+name)
+}
+
+func TestManager(t *testing.T) {
+	g := NewWithT(t)
+
+	client := &fakeDynamic{objs: []unstructured.Unstructured{*instance("db")}, watcher: watch.NewFake()}
+	notifications := &recorder{}
+	m := NewManager(client, 0, "", notifications.notify)
+	g.Expect(m.NeedLeaderElection()).To(BeTrue())
+	state := func(name string) State {
+		s, _ := m.State(name)
+		return s
+	}
+
+	// registrations made before the election are kept until the start
+	first := &recorder{}
+	g.Expect(m.Register("first", gvr, Options{}, first.handler())).To(BeTrue())
+	g.Expect(m.Started()).To(BeFalse())
+	g.Expect(state("first")).To(Equal(State{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		g.Expect(m.Start(ctx)).To(Succeed())
+	}()
+
+	// the instances are handed once synced, and the registration is notified
+	g.Eventually(notifications.Events).Should(Equal([]string{"notify:first"}))
+	g.Expect(first.Events()).To(ContainElement("add:db"))
+	g.Expect(state("first")).To(Equal(State{Synced: true}))
+	g.Expect(m.Started()).To(BeTrue())
+
+	// instances already known are handed to new registrations on registration
+	second := &recorder{}
+	g.Expect(m.Register("second", gvr, Options{}, second.handler())).To(BeTrue())
+	g.Expect(second.Events()).To(Equal([]string{"add:db"}))
+	g.Expect(m.Register("second", gvr, Options{}, second.handler())).To(BeFalse())
+	g.Expect(second.Events()).To(Equal([]string{"add:db"}))
+	m.Replay("second")
+	g.Expect(second.Events()).To(Equal([]string{"add:db", "add:db"}))
+	g.Expect(state("second")).To(Equal(State{Synced: true}))
+
+	// both registrations share the informer
+	client.watcher.Add(instance("other"))
+	g.Eventually(first.Events).Should(ContainElement("add:other"))
+	g.Eventually(second.Events).Should(Equal([]string{"add:db", "add:db", "add:other"}))
+
+	g.Expect(m.Handler("first")).NotTo(BeNil())
+	g.Expect(m.Unregister("first")).NotTo(BeNil())
+	g.Expect(m.Unregister("first")).To(BeNil())
+	g.Expect(m.Handler("first")).To(BeNil())
+	_, ok := m.State("first")
+	g.Expect(ok).To(BeFalse())
+
+	client.watcher.Delete(instance("db"))
+	g.Eventually(second.Events).Should(ContainElement("delete:db"))
+	g.Consistently(first.Events, 100*time.Millisecond).ShouldNot(ContainElement("delete:db"))
+
+	cancel()
+	g.Eventually(stopped).Should(BeClosed())
+	g.Expect(m.Started()).To(BeFalse())
+	g.Expect(client.Watches()).To(Equal(1))
+}
+
+func TestManagerWatchErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	client := &fakeDynamic{watcher: watch.NewFake(), failures: 1}
+	notifications := &recorder{}
+	m := NewManager(client, 0, "", notifications.notify)
+	m.Register("srm", gvr, Options{}, HandlerFuncs{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = m.Start(ctx)
+	}()
+
+	// the failure is reported until the informer lists and watches again
+	g.Eventually(func() error {
+		s, _ := m.State("srm")
+		return s.Err
+	}).Should(HaveOccurred())
+	g.Eventually(func() error {
+		s, _ := m.State("srm")
+		return s.Err
+	}, 5*time.Second).ShouldNot(HaveOccurred())
+	g.Expect(notifications.Events()).To(ContainElement("notify:srm"))
+	g.Expect(client.Watches()).To(Equal(2))
+}
+
+func TestManagerProjection(t *testing.T) {
+	g := NewWithT(t)
+
+	db := instance("db")
+	g.Expect(unstructured.SetNestedField(db.Object, "db-credentials", "spec", "secret")).To(Succeed())
+	g.Expect(unstructured.SetNestedField(db.Object, "10.0.0.1", "status", "host")).To(Succeed())
+	client := &fakeDynamic{objs: []unstructured.Unstructured{*db}, watcher: watch.NewFake()}
+	m := NewManager(client, 0, "", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = m.Start(ctx)
+	}()
+
+	// the selectors are passed to the list and watch, the fields not read
+	// are pruned
+	var mu sync.Mutex
+	var cached *unstructured.Unstructured
+	h := HandlerFuncs{AddFunc: func(_ context.Context, u *unstructured.Unstructured) {
+		mu.Lock()
+		defer mu.Unlock()
+		cached = u
+	}}
+	last := func() map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if cached == nil {
+			return nil
+		}
+		return cached.Object
+	}
+
+	opts := Options{LabelSelector: "app=db", FieldSelector: "metadata.name=db", Fields: [][]string{{"spec", "secret"}}}
+	g.Expect(m.Register("first", gvr, opts, h)).To(BeTrue())
+	g.Eventually(last).Should(HaveKeyWithValue("spec", map[string]interface{}{"secret": "db-credentials"}))
+	g.Expect(last()).NotTo(HaveKey("status"))
+	g.Expect(last()).To(HaveKey("metadata"))
+	g.Expect(client.Selectors()).To(Equal([]string{"app=db", "metadata.name=db"}))
+
+	// registrations reading pruned fields replace the informer
+	opts.Fields = [][]string{{"status"}}
+	g.Expect(m.Register("second", gvr, opts, h)).To(BeTrue())
+	g.Eventually(last).Should(HaveKey("status"))
+	g.Expect(last()).To(HaveKey("spec"))
+	g.Eventually(func() State {
+		s, _ := m.State("first")
+		return s
+	}).Should(Equal(State{Synced: true}))
+
+	// registrations with other selectors use another informer
+	g.Expect(m.Register("second", gvr, Options{}, h)).To(BeTrue())
+	g.Eventually(client.Selectors).Should(Equal([]string{"", ""}))
+	g.Expect(client.LastNamespace()).To(BeEmpty())
+
+	// as do registrations restricted to a namespace
+	g.Expect(m.Register("tenant/third", gvr, Options{Namespace: "tenant"}, h)).To(BeTrue())
+	g.Expect(client.LastNamespace()).To(Equal("tenant"))
+	g.Eventually(func() State {
+		s, _ := m.State("tenant/third")
+		return s
+	}).Should(Equal(State{Synced: true}))
+}
+
+func TestFieldSet(t *testing.T) {
+	g := NewWithT(t)
+
+	s := newFieldSet([][]string{{"spec", "host"}, {"spec", "port"}, {"status"}, {"status", "host"}})
+	g.Expect(s).To(Equal(fieldSet{"spec": {"host": nil, "port": nil}, "status": nil}))
+	g.Expect(newFieldSet(nil)).To(BeNil())
+	g.Expect(newFieldSet([][]string{})).To(Equal(fieldSet{}))
+
+	g.Expect(s.covers(newFieldSet([][]string{{"spec", "host"}, {"status", "ready"}}))).To(BeTrue())
+	g.Expect(s.covers(newFieldSet([][]string{{"spec"}}))).To(BeFalse())
+	g.Expect(s.covers(nil)).To(BeFalse())
+	g.Expect(fieldSet(nil).covers(s)).To(BeTrue())
+
+	g.Expect(s.union(newFieldSet([][]string{{"spec", "user", "name"}}))).To(Equal(
+		fieldSet{"spec": {"host": nil, "port": nil, "user": {"name": nil}}, "status": nil}))
+	g.Expect(s.union(nil)).To(BeNil())
+
+	u := instance("db")
+	u.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+	u.Object["spec"] = map[string]interface{}{"host": "db", "password": "secret", "ports": []interface{}{5432}}
+	u.Object["status"] = map[string]interface{}{"ready": true}
+	u.Object["data"] = "pruned"
+	obj, err := s.transform(u)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(obj).To(BeIdenticalTo(u))
+	g.Expect(u.Object).To(HaveKeyWithValue("spec", map[string]interface{}{"host": "db"}))
+	g.Expect(u.Object).To(HaveKeyWithValue("status", map[string]interface{}{"ready": true}))
+	g.Expect(u.Object).NotTo(HaveKey("data"))
+	g.Expect(u.GetName()).To(Equal("db"))
+	g.Expect(u.GetManagedFields()).To(BeEmpty())
+
+	tombstone := cache.DeletedFinalStateUnknown{Key: "ns/db"}
+	g.Expect(s.transform(tombstone)).To(Equal(tombstone))
+}
+
+func TestDeleted(t *testing.T) {
+	g := NewWithT(t)
+
+	u, err := deleted(instance("db"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u.GetName()).To(Equal("db"))
+
+	u, err = deleted(cache.DeletedFinalStateUnknown{Key: "ns/db", Obj: instance("db")})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u.GetName()).To(Equal("db"))
+
+	_, err = deleted("db")
+	g.Expect(err).To(HaveOccurred())
+}
+
+// deployment returns an instance shaped like a Deployment, with its managed
+// fields and pod template
+func deployment(i int) *unstructured.Unstructured {
+	u := instance(fmt.Sprintf("deployment-%d", i))
+	u.SetLabels(map[string]string{"app": u.GetName(), "tier": "backend"})
+	u.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply, APIVersion: "apps/v1",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{},"f:selector":{},"f:template":{"f:metadata":{"f:labels":{"f:app":{}}},"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{".":{},"f:env":{},"f:image":{},"f:name":{},"f:ports":{}}}}}}}`)}},
+		{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "apps/v1", Subresource: "status",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:availableReplicas":{},"f:conditions":{},"f:observedGeneration":{},"f:readyReplicas":{},"f:replicas":{},"f:updatedReplicas":{}}}`)}},
+	})
+
+	env := []interface{}{}
+	for e := 0; e < 10; e++ {
+		env = append(env, map[string]interface{}{"name": fmt.Sprintf("ENV_%d", e), "value": fmt.Sprintf("value-%d", e)})
+	}
+	u.Object["spec"] = map[string]interface{}{
+		"replicas": int64(3),
+		"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": u.GetName()}},
+		"template": map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": u.GetName()}},
+			"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name":  "app",
+					"image": "quay.io/example/app:1.0.0",
+					"env":   env,
+					"ports": []interface{}{map[string]interface{}{"containerPort": int64(8080), "protocol": "TCP"}},
+				}},
+			},
+		},
+	}
+	u.Object["status"] = map[string]interface{}{
+		"replicas":      int64(3),
+		"readyReplicas": int64(3),
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable"},
+			map[string]interface{}{"type": "Progressing", "status": "True", "reason": "NewReplicaSetAvailable"},
+		},
+	}
+	return u
+}
+
+// BenchmarkCacheMemory reports the heap used by the cache of an informer
+// watching 10k Deployments: untransformed, keeping whole instances but their
+// managed fields, and keeping the fields read by `path={.spec.replicas}`
+func BenchmarkCacheMemory(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		transform cache.TransformFunc
+	}{
+		{name: "untransformed", transform: func(obj interface{}) (interface{}, error) { return obj, nil }},
+		{name: "whole", transform: newFieldSet(nil).transform},
+		{name: "projected", transform: newFieldSet([][]string{{"spec", "replicas"}}).transform},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var heap uint64
+			for n := 0; n < b.N; n++ {
+				store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+				before := heapAlloc()
+				for i := 0; i < 10000; i++ {
+					obj, _ := bc.transform(deployment(i))
+					_ = store.Add(obj)
+				}
+				heap += heapAlloc() - before
+				runtime.KeepAlive(store)
+			}
+			b.ReportMetric(float64(heap)/float64(b.N), "B/10k-instances")
+		})
+	}
+}
+
+func heapAlloc() uint64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
@@ -0,0 +1,116 @@
+package informers
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fieldSet is a tree of the fields kept in the informers' caches. A nil
+// subtree keeps the whole field, and a nil fieldSet keeps whole instances.
+type fieldSet map[string]fieldSet
+
+// newFieldSet returns the fieldSet keeping the given paths, nil if paths is nil
+func newFieldSet(paths [][]string) fieldSet {
+	if paths == nil {
+		return nil
+	}
+
+	s := fieldSet{}
+	for _, p := range paths {
+		s.add(p)
+	}
+	return s
+}
+
+func (s fieldSet) add(path []string) {
+	for i, f := range path {
+		sub, ok := s[f]
+		switch {
+		case ok && sub == nil:
+			// the whole field is already kept
+			return
+		case i == len(path)-1:
+			s[f] = nil
+		case !ok:
+			sub = fieldSet{}
+			s[f] = sub
+		}
+		s = sub
+	}
+}
+
+// covers returns true if every field kept by o is kept by s
+func (s fieldSet) covers(o fieldSet) bool {
+	if s == nil {
+		return true
+	}
+	if o == nil {
+		return false
+	}
+
+	for f, sub := range o {
+		ssub, ok := s[f]
+		if !ok || !ssub.covers(sub) {
+			return false
+		}
+	}
+	return true
+}
+
+// union returns the fieldSet keeping the fields kept by s or o
+func (s fieldSet) union(o fieldSet) fieldSet {
+	if s == nil || o == nil {
+		return nil
+	}
+
+	u := fieldSet{}
+	for f, sub := range s {
+		u[f] = sub.union(fieldSet{})
+	}
+	for f, sub := range o {
+		if usub, ok := u[f]; ok {
+			u[f] = usub.union(sub)
+		} else {
+			u[f] = sub.union(fieldSet{})
+		}
+	}
+	return u
+}
+
+// transform prunes the instances of the fields not kept by s. The type and
+// metadata are always kept, except the managed fields.
+func (s fieldSet) transform(obj interface{}) (interface{}, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+
+	u.SetManagedFields(nil)
+	if s == nil {
+		return u, nil
+	}
+
+	for f, v := range u.Object {
+		if f == "apiVersion" || f == "kind" || f == "metadata" {
+			continue
+		}
+		s.prune(f, v, u.Object)
+	}
+	return u, nil
+}
+
+// prune removes the field f of the parent object if it is not kept
+func (s fieldSet) prune(f string, v interface{}, parent map[string]interface{}) {
+	sub, ok := s[f]
+	switch {
+	case !ok:
+		delete(parent, f)
+	case sub == nil:
+	default:
+		// the fields of lists are kept whole
+		if m, ok := v.(map[string]interface{}); ok {
+			for sf, sv := range m {
+				sub.prune(sf, sv, m)
+			}
+		}
+	}
+}
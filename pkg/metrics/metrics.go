@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "service_mapper"
+
+var (
+	// SEDRenders counts the Service Endpoint Definitions rendered, by ServiceResourceMap
+	SEDRenders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sed_renders_total",
+		Help:      "Number of Service Endpoint Definitions rendered",
+	}, []string{"srm"})
+
+	// SEDRenderDuration observes the time spent rendering Service Endpoint Definitions
+	SEDRenderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sed_render_duration_seconds",
+		Help:      "Time spent rendering Service Endpoint Definitions, reference lookups included",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"srm"})
+
+	// RuleFailures counts the rules that could not be processed, by
+	// ServiceResourceMap and rule type
+	RuleFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rule_failures_total",
+		Help:      "Number of service_map rules that could not be processed",
+	}, []string{"srm", "rule_type"})
+
+	// ReferenceLookups counts the Secrets and ConfigMaps retrieved by
+	// reference rules, by object type and result
+	ReferenceLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reference_lookups_total",
+		Help:      "Number of Secrets and ConfigMaps retrieved by reference rules",
+	}, []string{"object_type", "result"})
+
+	// InformerEvents counts the events received by the dynamic informers, by
+	// GroupVersionResource and event type
+	InformerEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "informer_events_total",
+		Help:      "Number of events received by the informers on mapped resources",
+	}, []string{"gvr", "event"})
+
+	// ActiveInformers is the number of running dynamic informers
+	ActiveInformers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_informers",
+		Help:      "Number of running informers on mapped resources",
+	})
+
+	// InstanceToSEDLatency observes the time from the reception of an
+	// instance change, the coalesce window included, to the write of its
+	// Service Endpoint Definitions
+	InstanceToSEDLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "instance_to_sed_latency_seconds",
+		Help:      "Time from the reception of an instance change to the write of its Service Endpoint Definitions",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"srm"})
+
+	// SkippedInstanceUpdates counts the instance changes that did not update
+	// the Service Endpoint Definitions, by ServiceResourceMap and reason
+	SkippedInstanceUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "skipped_instance_updates_total",
+		Help:      "Number of instance changes that did not update the Service Endpoint Definitions",
+	}, []string{"srm", "reason"})
+)
+
+// Reasons of the skipped instance updates
+const (
+	// SkipCoalesced is the reason of the changes replaced by a later change
+	// within the coalesce window
+	SkipCoalesced = "coalesced"
+	// SkipUnchanged is the reason of the changes not changing the values read
+	// by the rules
+	SkipUnchanged = "unchanged"
+)
+
+// Result labels
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+// Result returns the result label for the given error
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		SEDRenders,
+		SEDRenderDuration,
+		RuleFailures,
+		ReferenceLookups,
+		InformerEvents,
+		ActiveInformers,
+		InstanceToSEDLatency,
+		SkippedInstanceUpdates,
+	)
+}
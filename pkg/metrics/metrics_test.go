@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func TestRegistry(t *testing.T) {
+	g := NewWithT(t)
+
+	// vectors are only gathered once they have a child
+	SEDRenders.WithLabelValues("srm")
+	SEDRenderDuration.WithLabelValues("srm")
+	RuleFailures.WithLabelValues("srm", "path")
+	ReferenceLookups.WithLabelValues("Secret", ResultSuccess)
+	InformerEvents.WithLabelValues("v1/pods", "add")
+	InstanceToSEDLatency.WithLabelValues("srm")
+	SkippedInstanceUpdates.WithLabelValues("srm", SkipCoalesced)
+
+	mfs, err := metrics.Registry.Gather()
+	g.Expect(err).NotTo(HaveOccurred())
+	labels := map[string][]string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			var ls []string
+			for _, l := range m.Label {
+				ls = append(ls, l.GetName())
+			}
+			labels[mf.GetName()] = ls
+		}
+	}
+
+	for name, ls := range map[string][]string{
+		"service_mapper_sed_renders_total":               {"srm"},
+		"service_mapper_sed_render_duration_seconds":     {"srm"},
+		"service_mapper_rule_failures_total":             {"rule_type", "srm"},
+		"service_mapper_reference_lookups_total":         {"object_type", "result"},
+		"service_mapper_informer_events_total":           {"event", "gvr"},
+		"service_mapper_active_informers":                nil,
+		"service_mapper_instance_to_sed_latency_seconds": {"srm"},
+		"service_mapper_skipped_instance_updates_total":  {"reason", "srm"},
+	} {
+		g.Expect(labels).To(HaveKeyWithValue(name, ls), name)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	g := NewWithT(t)
+
+	value := func(c prometheus.Counter) float64 {
+		var m dto.Metric
+		g.Expect(c.Write(&m)).To(Succeed())
+		return m.GetCounter().GetValue()
+	}
+
+	for _, c := range []prometheus.Counter{
+		SEDRenders.WithLabelValues("counters"),
+		RuleFailures.WithLabelValues("counters", "path"),
+		ReferenceLookups.WithLabelValues("ConfigMap", ResultError),
+		InformerEvents.WithLabelValues("v1/services", "update"),
+		SkippedInstanceUpdates.WithLabelValues("counters", SkipUnchanged),
+	} {
+		before := value(c)
+		c.Inc()
+		g.Expect(value(c)).To(Equal(before + 1))
+	}
+
+	var m dto.Metric
+	InstanceToSEDLatency.WithLabelValues("counters").Observe(0.5)
+	g.Expect(InstanceToSEDLatency.WithLabelValues("counters").(prometheus.Histogram).Write(&m)).To(Succeed())
+	g.Expect(m.GetHistogram().GetSampleCount()).To(Equal(uint64(1)))
+	g.Expect(m.GetHistogram().GetSampleSum()).To(Equal(0.5))
+}
+
+func TestResult(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(Result(nil)).To(Equal(ResultSuccess))
+	g.Expect(Result(errors.New("not found"))).To(Equal(ResultError))
+}
@@ -0,0 +1,100 @@
+package projection
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/pointer"
+)
+
+// ProjectEnv injects, in every container and init container of the pod
+// template, the environment variables of env (Secret key to variable name) as
+// optional references to the Secret's keys, so that the pods start when a key
+// is missing, e.g. as its rule failed. Variables defined by a container itself
+// are left untouched, unless they were previously injected in this container,
+// that is listed in owned as `<container>/<variable>`. The injected variables
+// are returned in the same form.
+func ProjectEnv(t *corev1.PodTemplateSpec, secret string, env map[string]string, owned []string) []string {
+	os := make(map[string]struct{}, len(owned))
+	for _, n := range owned {
+		os[n] = struct{}{}
+	}
+
+	declared := make(map[string]struct{}, len(env))
+	for _, n := range env {
+		declared[n] = struct{}{}
+	}
+
+	// keys are sorted by variable name so that the resulting pod template is stable
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return env[keys[i]] < env[keys[j]] })
+
+	injected := map[string]struct{}{}
+	project := func(c *corev1.Container) {
+		// variables no longer declared are removed from the containers they
+		// were injected in
+		for n := range os {
+			v := strings.TrimPrefix(n, c.Name+"/")
+			if _, ok := declared[v]; v != n && !ok {
+				c.Env = removeEnv(c.Env, v)
+			}
+		}
+
+		for _, k := range keys {
+			n := env[k]
+			if len(validation.IsEnvVarName(n)) != 0 {
+				continue
+			}
+
+			_, own := os[c.Name+"/"+n]
+			if !own && hasEnv(c, n) {
+				continue
+			}
+
+			c.Env = append(removeEnv(c.Env, n), corev1.EnvVar{
+				Name: n,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+						Key:                  k,
+						Optional:             pointer.Bool(true),
+					},
+				},
+			})
+			injected[c.Name+"/"+n] = struct{}{}
+		}
+	}
+	for i := range t.Spec.InitContainers {
+		project(&t.Spec.InitContainers[i])
+	}
+	for i := range t.Spec.Containers {
+		project(&t.Spec.Containers[i])
+	}
+
+	ns := make([]string, 0, len(injected))
+	for n := range injected {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// UnprojectEnv removes the injected environment variables, listed as
+// `<container>/<variable>`, from the containers they were injected in
+func UnprojectEnv(t *corev1.PodTemplateSpec, injected []string) {
+	ProjectEnv(t, "", nil, injected)
+}
+
+func hasEnv(c *corev1.Container, name string) bool {
+	for _, e := range c.Env {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package projection
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+func secretEnv(name, secret, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+				Key:                  key,
+				Optional:             pointer.Bool(true),
+			},
+		},
+	}
+}
+
+func TestProjectEnv(t *testing.T) {
+	g := NewWithT(t)
+
+	pt := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", Env: []corev1.EnvVar{{Name: "DATABASE_USER", Value: "custom"}}},
+			},
+		},
+	}
+
+	env := map[string]string{"uri": "DATABASE_URL", "username": "DATABASE_USER", "password": "not a name"}
+	injected := ProjectEnv(pt, "db-sed", env, nil)
+	g.Expect(injected).To(Equal([]string{"app/DATABASE_URL"}))
+	g.Expect(pt.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+		{Name: "DATABASE_USER", Value: "custom"},
+		secretEnv("DATABASE_URL", "db-sed", "uri"),
+	}))
+
+	// projecting again is stable
+	g.Expect(ProjectEnv(pt, "db-sed", env, injected)).To(Equal(injected))
+	g.Expect(pt.Spec.Containers[0].Env).To(HaveLen(2))
+
+	// variables no longer declared are removed
+	injected = ProjectEnv(pt, "db-sed", map[string]string{"host": "DATABASE_HOST"}, injected)
+	g.Expect(injected).To(Equal([]string{"app/DATABASE_HOST"}))
+	g.Expect(pt.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+		{Name: "DATABASE_USER", Value: "custom"},
+		secretEnv("DATABASE_HOST", "db-sed", "host"),
+	}))
+
+	UnprojectEnv(pt, injected)
+	g.Expect(pt.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{{Name: "DATABASE_USER", Value: "custom"}}))
+}
+
+func TestProjectEnvPerContainer(t *testing.T) {
+	g := NewWithT(t)
+
+	pt := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate"}},
+			Containers: []corev1.Container{
+				{Name: "app"},
+				{Name: "sidecar", Env: []corev1.EnvVar{{Name: "DATABASE_HOST", Value: "localhost"}}},
+			},
+		},
+	}
+
+	// the variable is injected in the containers not defining it
+	env := map[string]string{"host": "DATABASE_HOST"}
+	injected := ProjectEnv(pt, "db-sed", env, nil)
+	g.Expect(injected).To(Equal([]string{"app/DATABASE_HOST", "migrate/DATABASE_HOST"}))
+
+	// and the containers defining it keep their value once it is owned in others
+	injected = ProjectEnv(pt, "db-sed", env, injected)
+	g.Expect(injected).To(Equal([]string{"app/DATABASE_HOST", "migrate/DATABASE_HOST"}))
+	g.Expect(pt.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{secretEnv("DATABASE_HOST", "db-sed", "host")}))
+	g.Expect(pt.Spec.Containers[1].Env).To(Equal([]corev1.EnvVar{{Name: "DATABASE_HOST", Value: "localhost"}}))
+
+	// nor lose it when the variable is no longer declared or is removed
+	g.Expect(ProjectEnv(pt, "db-sed", map[string]string{"port": "DATABASE_PORT"}, injected)).
+		To(Equal([]string{"app/DATABASE_PORT", "migrate/DATABASE_PORT", "sidecar/DATABASE_PORT"}))
+	g.Expect(pt.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{secretEnv("DATABASE_PORT", "db-sed", "port")}))
+	g.Expect(pt.Spec.Containers[1].Env).To(Equal([]corev1.EnvVar{
+		{Name: "DATABASE_HOST", Value: "localhost"},
+		secretEnv("DATABASE_PORT", "db-sed", "port"),
+	}))
+
+	UnprojectEnv(pt, []string{"app/DATABASE_PORT", "migrate/DATABASE_PORT", "sidecar/DATABASE_PORT"})
+	g.Expect(pt.Spec.InitContainers[0].Env).To(BeEmpty())
+	g.Expect(pt.Spec.Containers[0].Env).To(BeEmpty())
+	g.Expect(pt.Spec.Containers[1].Env).To(Equal([]corev1.EnvVar{{Name: "DATABASE_HOST", Value: "localhost"}}))
+}
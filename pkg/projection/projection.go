@@ -0,0 +1,279 @@
+package projection
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Workload projection conventions of the Service Binding specification, see
+// https://github.com/servicebinding/spec#workload-projection
+const (
+	ServiceBindingRootEnv     = "SERVICE_BINDING_ROOT"
+	DefaultServiceBindingRoot = "/bindings"
+
+	// VolumePrefix prefixes the names of the volumes projecting bindings
+	VolumePrefix = "servicebinding-"
+
+	// AnnotationInjectedRoot lists the containers of a pod template whose
+	// SERVICE_BINDING_ROOT environment variable has been injected, so that it
+	// can be removed together with the last binding
+	AnnotationInjectedRoot = "servicemapper.binding/injected-binding-root"
+
+	// AnnotationProjectedVolumes lists the volumes of a pod template, and
+	// the volume mounts of the same names, projecting bindings. Volumes of
+	// the workload are never modified nor removed, even if their names start
+	// with VolumePrefix.
+	AnnotationProjectedVolumes = "servicemapper.binding/projected-volumes"
+)
+
+// ErrVolumeConflict is returned when the pod template already has a volume
+// with the name of the volume projecting a binding
+var ErrVolumeConflict = errors.New("volume defined by the workload")
+
+// ErrBindingRoot is returned when a container defines SERVICE_BINDING_ROOT
+// otherwise than as an absolute path, e.g. with valueFrom, so that the
+// bindings can not be mounted under it
+var ErrBindingRoot = errors.New("SERVICE_BINDING_ROOT is not an absolute path")
+
+// podTemplatePaths locates the pod template of the supported workloads
+var podTemplatePaths = map[schema.GroupKind][]string{
+	{Group: "apps", Kind: "Deployment"}:  {"spec", "template"},
+	{Group: "apps", Kind: "StatefulSet"}: {"spec", "template"},
+	{Group: "apps", Kind: "DaemonSet"}:   {"spec", "template"},
+	{Group: "batch", Kind: "CronJob"}:    {"spec", "jobTemplate", "spec", "template"},
+}
+
+// IsSupported returns true if bindings can be projected into the given kind of workload
+func IsSupported(gk schema.GroupKind) bool {
+	_, ok := podTemplatePaths[gk]
+	return ok
+}
+
+// PodTemplate returns the pod template of the workload
+func PodTemplate(u *unstructured.Unstructured) (*corev1.PodTemplateSpec, error) {
+	p, ok := podTemplatePaths[u.GroupVersionKind().GroupKind()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported workload kind: %s", u.GroupVersionKind())
+	}
+
+	m, ok, err := unstructured.NestedMap(u.Object, p...)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("pod template not found at '.%s' in %s '%s'", strings.Join(p, "."), u.GetKind(), u.GetName())
+	}
+
+	var t corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SetPodTemplate replaces the pod template of the workload
+func SetPodTemplate(u *unstructured.Unstructured, t *corev1.PodTemplateSpec) error {
+	p, ok := podTemplatePaths[u.GroupVersionKind().GroupKind()]
+	if !ok {
+		return fmt.Errorf("unsupported workload kind: %s", u.GroupVersionKind())
+	}
+
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(t)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(u.Object, m, p...)
+}
+
+// VolumeName returns the name of the volume projecting the binding
+func VolumeName(binding string) string {
+	n := VolumePrefix + binding
+	if len(n) <= 63 {
+		return n
+	}
+	return fmt.Sprintf("%s%x", VolumePrefix, sha256.Sum256([]byte(binding)))[:63]
+}
+
+// Project mounts the Secret into every container and init container of the
+// pod template at `$SERVICE_BINDING_ROOT/<binding>`. SERVICE_BINDING_ROOT is
+// set to DefaultServiceBindingRoot in the containers not defining it.
+// ErrVolumeConflict is returned if the workload defines a volume with the
+// name of the projected volume, and ErrBindingRoot if a container defines
+// SERVICE_BINDING_ROOT otherwise than as an absolute path.
+func Project(t *corev1.PodTemplateSpec, binding, secret string) error {
+	vn := VolumeName(binding)
+	projected := annotatedSet(t, AnnotationProjectedVolumes)
+	if _, ok := projected[vn]; !ok && hasVolume(t, vn) {
+		return fmt.Errorf("%w: can not project binding '%s' as volume '%s'", ErrVolumeConflict, binding, vn)
+	}
+	for _, cs := range [][]corev1.Container{t.Spec.InitContainers, t.Spec.Containers} {
+		for i := range cs {
+			if root, _ := bindingRoot(&cs[i]); !path.IsAbs(root) {
+				return fmt.Errorf("%w: can not project binding '%s' into container '%s', set %s to a literal absolute path",
+					ErrBindingRoot, binding, cs[i].Name, ServiceBindingRootEnv)
+			}
+		}
+	}
+
+	v := corev1.Volume{
+		Name: vn,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: secret}}},
+				},
+			},
+		},
+	}
+	t.Spec.Volumes = append(removeVolume(t.Spec.Volumes, vn), v)
+	projected[vn] = struct{}{}
+	setAnnotatedSet(t, AnnotationProjectedVolumes, projected)
+
+	injected := annotatedSet(t, AnnotationInjectedRoot)
+	project := func(c *corev1.Container) {
+		root, ok := bindingRoot(c)
+		if !ok {
+			c.Env = append(c.Env, corev1.EnvVar{Name: ServiceBindingRootEnv, Value: root})
+			injected[c.Name] = struct{}{}
+		}
+
+		c.VolumeMounts = append(removeVolumeMount(c.VolumeMounts, vn), corev1.VolumeMount{
+			Name:      vn,
+			MountPath: path.Join(root, binding),
+			ReadOnly:  true,
+		})
+	}
+	for i := range t.Spec.InitContainers {
+		project(&t.Spec.InitContainers[i])
+	}
+	for i := range t.Spec.Containers {
+		project(&t.Spec.Containers[i])
+	}
+
+	setAnnotatedSet(t, AnnotationInjectedRoot, injected)
+	return nil
+}
+
+// Unproject reverts Project. SERVICE_BINDING_ROOT is removed when it was
+// injected and no other binding is projected. The pod template is left
+// untouched if the binding is not projected.
+func Unproject(t *corev1.PodTemplateSpec, binding string) {
+	vn := VolumeName(binding)
+	projected := annotatedSet(t, AnnotationProjectedVolumes)
+	if _, ok := projected[vn]; !ok {
+		return
+	}
+	t.Spec.Volumes = removeVolume(t.Spec.Volumes, vn)
+	delete(projected, vn)
+	setAnnotatedSet(t, AnnotationProjectedVolumes, projected)
+
+	last := len(projected) == 0
+	injected := annotatedSet(t, AnnotationInjectedRoot)
+
+	unproject := func(c *corev1.Container) {
+		c.VolumeMounts = removeVolumeMount(c.VolumeMounts, vn)
+		if _, ok := injected[c.Name]; ok && last {
+			c.Env = removeEnv(c.Env, ServiceBindingRootEnv)
+		}
+	}
+	for i := range t.Spec.InitContainers {
+		unproject(&t.Spec.InitContainers[i])
+	}
+	for i := range t.Spec.Containers {
+		unproject(&t.Spec.Containers[i])
+	}
+
+	if last {
+		delete(t.Annotations, AnnotationInjectedRoot)
+	}
+}
+
+// annotatedSet returns the names listed by the annotation of the pod template
+func annotatedSet(t *corev1.PodTemplateSpec, annotation string) map[string]struct{} {
+	set := map[string]struct{}{}
+	if a := t.Annotations[annotation]; a != "" {
+		for _, n := range strings.Split(a, ",") {
+			set[n] = struct{}{}
+		}
+	}
+	return set
+}
+
+// setAnnotatedSet lists the names in the annotation of the pod template, or
+// removes it if there are none
+func setAnnotatedSet(t *corev1.PodTemplateSpec, annotation string, set map[string]struct{}) {
+	if len(set) == 0 {
+		delete(t.Annotations, annotation)
+		return
+	}
+
+	ns := make([]string, 0, len(set))
+	for n := range set {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+
+	if t.Annotations == nil {
+		t.Annotations = map[string]string{}
+	}
+	t.Annotations[annotation] = strings.Join(ns, ",")
+}
+
+func hasVolume(t *corev1.PodTemplateSpec, name string) bool {
+	for _, v := range t.Spec.Volumes {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bindingRoot returns the container's SERVICE_BINDING_ROOT, and whether it
+// was already defined. It is empty if defined with valueFrom.
+func bindingRoot(c *corev1.Container) (string, bool) {
+	for _, e := range c.Env {
+		if e.Name == ServiceBindingRootEnv {
+			return e.Value, true
+		}
+	}
+	return DefaultServiceBindingRoot, false
+}
+
+func removeVolume(vs []corev1.Volume, name string) []corev1.Volume {
+	r := vs[:0]
+	for _, v := range vs {
+		if v.Name != name {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+func removeVolumeMount(vms []corev1.VolumeMount, name string) []corev1.VolumeMount {
+	r := vms[:0]
+	for _, vm := range vms {
+		if vm.Name != name {
+			r = append(r, vm)
+		}
+	}
+	return r
+}
+
+func removeEnv(es []corev1.EnvVar, name string) []corev1.EnvVar {
+	r := es[:0]
+	for _, e := range es {
+		if e.Name != name {
+			r = append(r, e)
+		}
+	}
+	return r
+}
@@ -0,0 +1,135 @@
+package projection
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func podTemplate() *corev1.PodTemplateSpec {
+	return &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers: []corev1.Container{
+				{Name: "app"},
+				{Name: "custom", Env: []corev1.EnvVar{{Name: ServiceBindingRootEnv, Value: "/custom"}}},
+			},
+		},
+	}
+}
+
+func TestProject(t *testing.T) {
+	g := NewWithT(t)
+
+	pt := podTemplate()
+	g.Expect(Project(pt, "db", "db-sed")).To(Succeed())
+	g.Expect(Project(pt, "db", "db-sed")).To(Succeed())
+
+	g.Expect(pt.Spec.Volumes).To(HaveLen(1))
+	g.Expect(pt.Spec.Volumes[0].Name).To(Equal("servicebinding-db"))
+	g.Expect(pt.Spec.Volumes[0].Projected.Sources[0].Secret.Name).To(Equal("db-sed"))
+
+	for _, c := range append(pt.Spec.InitContainers, pt.Spec.Containers[0]) {
+		g.Expect(c.Env).To(ConsistOf(corev1.EnvVar{Name: ServiceBindingRootEnv, Value: DefaultServiceBindingRoot}))
+		g.Expect(c.VolumeMounts).To(ConsistOf(corev1.VolumeMount{Name: "servicebinding-db", MountPath: "/bindings/db", ReadOnly: true}))
+	}
+	g.Expect(pt.Spec.Containers[1].Env).To(HaveLen(1))
+	g.Expect(pt.Spec.Containers[1].VolumeMounts).To(ConsistOf(corev1.VolumeMount{Name: "servicebinding-db", MountPath: "/custom/db", ReadOnly: true}))
+	g.Expect(pt.Annotations).To(HaveKeyWithValue(AnnotationInjectedRoot, "app,init"))
+	g.Expect(pt.Annotations).To(HaveKeyWithValue(AnnotationProjectedVolumes, "servicebinding-db"))
+
+	// volumes of the workload are not replaced
+	pt = podTemplate()
+	pt.Spec.Volumes = []corev1.Volume{{Name: "servicebinding-db"}}
+	g.Expect(Project(pt, "db", "db-sed")).To(MatchError(ErrVolumeConflict))
+	g.Expect(pt.Spec.Volumes).To(Equal([]corev1.Volume{{Name: "servicebinding-db"}}))
+
+	// nor are the bindings mounted under a root that is not a literal absolute path
+	for _, e := range []corev1.EnvVar{
+		{Name: ServiceBindingRootEnv, ValueFrom: &corev1.EnvVarSource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "app"}, Key: "root"},
+		}},
+		{Name: ServiceBindingRootEnv, Value: "bindings"},
+	} {
+		pt = podTemplate()
+		pt.Spec.Containers[1].Env = []corev1.EnvVar{e}
+		err := Project(pt, "db", "db-sed")
+		g.Expect(errors.Is(err, ErrBindingRoot)).To(BeTrue(), "%v", err)
+		g.Expect(err).To(MatchError(ContainSubstring("container 'custom'")))
+		g.Expect(pt.Spec.Volumes).To(BeEmpty())
+		g.Expect(pt.Annotations).To(BeEmpty())
+	}
+}
+
+func TestUnproject(t *testing.T) {
+	g := NewWithT(t)
+
+	pt := podTemplate()
+	// volumes of the workload are kept, whatever their names
+	pt.Spec.Volumes = []corev1.Volume{{Name: "servicebinding-config"}}
+	g.Expect(Project(pt, "db", "db-sed")).To(Succeed())
+	g.Expect(Project(pt, "cache", "cache-sed")).To(Succeed())
+
+	Unproject(pt, "config")
+	g.Expect(pt.Spec.Volumes).To(HaveLen(3))
+
+	Unproject(pt, "db")
+	g.Expect(pt.Spec.Volumes).To(HaveLen(2))
+	g.Expect(pt.Spec.Containers[0].Env).To(HaveLen(1))
+	g.Expect(pt.Spec.Containers[0].VolumeMounts).To(HaveLen(1))
+
+	Unproject(pt, "cache")
+	g.Expect(pt.Spec.Volumes).To(Equal([]corev1.Volume{{Name: "servicebinding-config"}}))
+	g.Expect(pt.Spec.InitContainers[0].Env).To(BeEmpty())
+	g.Expect(pt.Spec.Containers[0].Env).To(BeEmpty())
+	g.Expect(pt.Spec.Containers[0].VolumeMounts).To(BeEmpty())
+	g.Expect(pt.Spec.Containers[1].Env).To(HaveLen(1))
+	g.Expect(pt.Annotations).NotTo(HaveKey(AnnotationInjectedRoot))
+	g.Expect(pt.Annotations).NotTo(HaveKey(AnnotationProjectedVolumes))
+}
+
+func TestPodTemplate(t *testing.T) {
+	g := NewWithT(t)
+
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "CronJob",
+		"metadata":   map[string]interface{}{"name": "job"},
+		"spec": map[string]interface{}{
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{map[string]interface{}{"name": "job"}},
+						},
+					},
+				},
+			},
+		},
+	}}
+
+	pt, err := PodTemplate(u)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pt.Spec.Containers).To(HaveLen(1))
+
+	g.Expect(Project(pt, "db", "db-sed")).To(Succeed())
+	g.Expect(SetPodTemplate(u, pt)).To(Succeed())
+
+	pt, err = PodTemplate(u)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pt.Spec.Containers[0].VolumeMounts).To(HaveLen(1))
+
+	u.SetKind("Job")
+	_, err = PodTemplate(u)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestVolumeName(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(VolumeName("db")).To(Equal("servicebinding-db"))
+	g.Expect(VolumeName(string(make([]byte, 100)))).To(HaveLen(63))
+}
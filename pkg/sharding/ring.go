@@ -0,0 +1,74 @@
+package sharding
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// Ring assigns keys to members by consistent hashing. Every member has
+// virtual nodes on a ring of hashes and owns the range of hashes preceding
+// each of them, so that a member joining or leaving only moves the keys of
+// its ranges.
+type Ring struct {
+	members []string
+	hashes  []uint64
+	owners  map[uint64]string
+}
+
+// NewRing returns the ring of the given members with vnodes virtual nodes each
+func NewRing(members []string, vnodes int) *Ring {
+	if vnodes < 1 {
+		vnodes = 1
+	}
+
+	r := &Ring{
+		members: append([]string{}, members...),
+		owners:  make(map[uint64]string, len(members)*vnodes),
+	}
+	sort.Strings(r.members)
+	for _, m := range r.members {
+		for v := 0; v < vnodes; v++ {
+			h := hash(m + "#" + strconv.Itoa(v))
+			// the lowest member wins the unlikely collisions
+			if _, ok := r.owners[h]; ok {
+				continue
+			}
+			r.owners[h] = m
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Owner returns the member owning the key, empty if the ring has no member
+func (r *Ring) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// has returns true if the member is on the ring
+func (r *Ring) has(member string) bool {
+	i := sort.SearchStrings(r.members, member)
+	return i < len(r.members) && r.members[i] == member
+}
+
+// Members returns the sorted members of the ring
+func (r *Ring) Members() []string {
+	return append([]string{}, r.members...)
+}
+
+func hash(s string) uint64 {
+	h := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(h[:8])
+}
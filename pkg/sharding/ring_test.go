@@ -0,0 +1,54 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func keys(n int) []string {
+	ks := make([]string, n)
+	for i := range ks {
+		ks[i] = fmt.Sprintf("6f1c1e8a-%04d-4c2e-9a53-%012d", i%10000, i)
+	}
+	return ks
+}
+
+func TestRing(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(NewRing(nil, 64).Owner("key")).To(BeEmpty())
+	g.Expect(NewRing([]string{"b", "a"}, 64).Members()).To(Equal([]string{"a", "b"}))
+
+	// keys are spread evenly over the members
+	r := NewRing([]string{"replica-0", "replica-1", "replica-2"}, 64)
+	owned := map[string]int{}
+	for _, k := range keys(30000) {
+		owned[r.Owner(k)]++
+	}
+	g.Expect(owned).To(HaveLen(3))
+	for m, n := range owned {
+		g.Expect(n).To(BeNumerically("~", 10000, 2500), m)
+	}
+
+	// the ring doesn't depend on the order of the members
+	g.Expect(NewRing([]string{"replica-2", "replica-0", "replica-1"}, 64).Owner("key")).To(Equal(r.Owner("key")))
+}
+
+func TestRingRebalance(t *testing.T) {
+	g := NewWithT(t)
+
+	before := NewRing([]string{"replica-0", "replica-1", "replica-2"}, 64)
+	after := NewRing([]string{"replica-0", "replica-1", "replica-2", "replica-3"}, 64)
+
+	// only the keys taken by the new member move
+	moved := 0
+	for _, k := range keys(30000) {
+		if o := after.Owner(k); o != before.Owner(k) {
+			g.Expect(o).To(Equal("replica-3"))
+			moved++
+		}
+	}
+	g.Expect(moved).To(BeNumerically("~", 7500, 2500))
+}
@@ -0,0 +1,302 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// LabelShard marks the Leases of the replicas sharing the instances
+const LabelShard = "servicemapper.binding/shard"
+
+// Keys of the instances hashed to pick their replica
+const (
+	KeyUID       = "uid"
+	KeyNamespace = "namespace"
+)
+
+// Options configure a Sharder
+type Options struct {
+	// Identity identifies the replica, e.g. its pod name
+	Identity string
+	// Namespace holds the Leases of the replicas
+	Namespace string
+	// Key is the attribute of the instances hashed to pick their replica,
+	// KeyUID or KeyNamespace
+	Key string
+	// LeaseDuration is the time a replica is a member after its last renewal
+	LeaseDuration time.Duration
+	// RenewPeriod is the time between two renewals of the replica's Lease,
+	// and between two listings of the members
+	RenewPeriod time.Duration
+	// VirtualNodes is the number of virtual nodes of each replica on the ring
+	VirtualNodes int
+}
+
+// RebalanceFunc is called when the members change, with the new ring
+type RebalanceFunc func(ctx context.Context, ring *Ring)
+
+// Sharder spreads the service instances over the replicas of the operator.
+// Every replica renews a Lease labelled with LabelShard, and the replicas
+// whose Lease is not expired are the members of a consistent hashing Ring.
+//
+// Sharder is a manager.Runnable running on every replica. It owns no key
+// until it has listed the members, nor once its own Lease expired without
+// being renewed, as the other replicas then take its keys over. It deletes
+// its Lease when it stops so that the other replicas take its keys over
+// without waiting for the Lease to expire; the keys of a replica that
+// crashed are owned by none until its Lease expires.
+//
+// The other replicas see the members change up to RenewPeriod later, so the
+// keys moving from a replica that is still a member are only owned once
+// RenewPeriod elapsed: until then, that replica may still handle them.
+type Sharder struct {
+	client    client.Client
+	reader    client.Reader
+	opts      Options
+	rebalance RebalanceFunc
+	now       func() time.Time
+
+	mu   sync.RWMutex
+	ring *Ring
+	// deadline is the expiry of the Lease as last renewed by the replica
+	deadline time.Time
+	// previous is the ring the other members may still use until handover,
+	// nil once they all use ring
+	previous *Ring
+	handover time.Time
+}
+
+// New returns a Sharder writing its Lease with cli and listing the members
+// with reader, which should not be cached. rebalance, if not nil, is called
+// when the members change.
+func New(cli client.Client, reader client.Reader, opts Options, rebalance RebalanceFunc) *Sharder {
+	return &Sharder{
+		client:    cli,
+		reader:    reader,
+		opts:      opts,
+		rebalance: rebalance,
+		now:       time.Now,
+	}
+}
+
+// Start renews the Lease and lists the members until the context is
+// cancelled, then deletes the Lease
+func (s *Sharder) Start(ctx context.Context) error {
+	l := log.FromContext(ctx).WithName("sharding").WithValues("identity", s.opts.Identity)
+
+	t := time.NewTicker(s.opts.RenewPeriod)
+	defer t.Stop()
+	for {
+		if err := s.sync(ctx); err != nil {
+			l.Error(err, "can not sync the shard members")
+		}
+
+		select {
+		case <-ctx.Done():
+			dctx, cancel := context.WithTimeout(context.Background(), s.opts.RenewPeriod)
+			defer cancel()
+			if err := s.client.Delete(dctx, s.lease()); err != nil && !apierrors.IsNotFound(err) {
+				l.Error(err, "can not delete the Lease")
+			}
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// NeedLeaderElection makes the Sharder run on every replica
+func (s *Sharder) NeedLeaderElection() bool {
+	return false
+}
+
+// Ready returns true once the members have been listed
+func (s *Sharder) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ring != nil
+}
+
+// Owns returns true if the replica owns the key. A replica whose Lease has
+// expired owns no key, even if it could not list the members since, and the
+// keys of the other members are owned once they have seen the members change.
+func (s *Sharder) Owns(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.now()
+	if s.ring == nil || !now.Before(s.deadline) || s.ring.Owner(key) != s.opts.Identity {
+		return false
+	}
+	if s.previous == nil || !now.Before(s.handover) {
+		return true
+	}
+	p := s.previous.Owner(key)
+	return p == "" || p == s.opts.Identity || !s.ring.has(p)
+}
+
+// OwnsObject returns true if the replica owns the instance
+func (s *Sharder) OwnsObject(obj metav1.Object) bool {
+	if s.opts.Key == KeyNamespace {
+		return s.Owns(obj.GetNamespace())
+	}
+	return s.Owns(string(obj.GetUID()))
+}
+
+// Members returns the replicas sharing the instances
+func (s *Sharder) Members() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ring == nil {
+		return nil
+	}
+	return s.ring.Members()
+}
+
+// sync renews the Lease, then rebuilds the ring if the members changed. The
+// keys moving from other members are handed over RenewPeriod later, when
+// they have seen the change: rebalance is called again then.
+func (s *Sharder) sync(ctx context.Context) error {
+	if err := s.renew(ctx); err != nil {
+		return err
+	}
+
+	members, err := s.members(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	if s.ring != nil && reflect.DeepEqual(s.ring.Members(), members) {
+		handedOver := s.previous != nil && !now.Before(s.handover)
+		if handedOver {
+			s.previous = nil
+		}
+		ring := s.ring
+		s.mu.Unlock()
+
+		if handedOver && s.rebalance != nil {
+			log.FromContext(ctx).Info("shard keys handed over", "identity", s.opts.Identity, "members", members)
+			s.rebalance(ctx, ring)
+		}
+		return nil
+	}
+
+	// the other members use the ring they had before the change, the one
+	// without the replica when it joins, and the oldest one if the members
+	// change again before the keys are handed over
+	if s.previous == nil || !now.Before(s.handover) {
+		s.previous = s.ring
+		if others := without(members, s.opts.Identity); s.previous == nil && len(others) > 0 {
+			s.previous = NewRing(others, s.opts.VirtualNodes)
+		}
+	}
+	s.handover = now.Add(s.opts.RenewPeriod)
+	ring := NewRing(members, s.opts.VirtualNodes)
+	s.ring = ring
+	s.mu.Unlock()
+
+	log.FromContext(ctx).Info("shard members changed", "identity", s.opts.Identity, "members", members)
+	if s.rebalance != nil {
+		s.rebalance(ctx, ring)
+	}
+	return nil
+}
+
+func without(members []string, member string) []string {
+	var ms []string
+	for _, m := range members {
+		if m != member {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+func (s *Sharder) lease() *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "service-mapper-shard-" + s.opts.Identity,
+			Namespace: s.opts.Namespace,
+			Labels:    map[string]string{LabelShard: "true"},
+		},
+	}
+}
+
+// renew creates or renews the Lease of the replica, and records its new
+// expiry
+func (s *Sharder) renew(ctx context.Context) error {
+	now := metav1.NewMicroTime(s.now().Truncate(time.Microsecond))
+	seconds := int32(s.opts.LeaseDuration.Seconds())
+	if err := s.writeLease(ctx, now, seconds); err != nil {
+		return err
+	}
+
+	// the other replicas take the keys over once the Lease expires
+	s.mu.Lock()
+	s.deadline = now.Add(time.Duration(seconds) * time.Second)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Sharder) writeLease(ctx context.Context, now metav1.MicroTime, seconds int32) error {
+	spec := coordinationv1.LeaseSpec{
+		HolderIdentity:       pointer.String(s.opts.Identity),
+		LeaseDurationSeconds: pointer.Int32(seconds),
+		RenewTime:            &now,
+	}
+
+	lease := s.lease()
+	if err := s.reader.Get(ctx, client.ObjectKeyFromObject(lease), lease); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error getting Lease '%s': %w", lease.Name, err)
+		}
+
+		spec.AcquireTime = &now
+		lease.Spec = spec
+		if err := s.client.Create(ctx, lease); err != nil {
+			return fmt.Errorf("error creating Lease '%s': %w", lease.Name, err)
+		}
+		return nil
+	}
+
+	spec.AcquireTime = lease.Spec.AcquireTime
+	lease.Spec = spec
+	if err := s.client.Update(ctx, lease); err != nil {
+		return fmt.Errorf("error renewing Lease '%s': %w", lease.Name, err)
+	}
+	return nil
+}
+
+// members returns the sorted identities of the replicas whose Lease is not
+// expired
+func (s *Sharder) members(ctx context.Context) ([]string, error) {
+	var leases coordinationv1.LeaseList
+	if err := s.reader.List(ctx, &leases, client.InNamespace(s.opts.Namespace), client.MatchingLabels{LabelShard: "true"}); err != nil {
+		return nil, fmt.Errorf("error listing the shard Leases: %w", err)
+	}
+
+	now := s.now()
+	var members []string
+	for _, l := range leases.Items {
+		if l.Spec.HolderIdentity == nil || l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expiry := l.Spec.RenewTime.Add(time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second)
+		if now.Before(expiry) {
+			members = append(members, *l.Spec.HolderIdentity)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
@@ -0,0 +1,207 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeLeases stores the Leases of the replicas, any other call panics. The
+// writes and lists fail with err, if set.
+type fakeLeases struct {
+	client.Client
+
+	mu     sync.Mutex
+	leases map[types.NamespacedName]*coordinationv1.Lease
+	err    error
+}
+
+func (f *fakeLeases) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, ok := f.leases[key]
+	if !ok {
+		return apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, key.Name)
+	}
+	l.DeepCopyInto(obj.(*coordinationv1.Lease))
+	return nil
+}
+
+func (f *fakeLeases) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	ll := list.(*coordinationv1.LeaseList)
+	for _, l := range f.leases {
+		ll.Items = append(ll.Items, *l.DeepCopy())
+	}
+	return nil
+}
+
+func (f *fakeLeases) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.leases[client.ObjectKeyFromObject(obj)] = obj.(*coordinationv1.Lease).DeepCopy()
+	return nil
+}
+
+func (f *fakeLeases) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	return f.Create(context.TODO(), obj)
+}
+
+func (f *fakeLeases) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.leases, client.ObjectKeyFromObject(obj))
+	return nil
+}
+
+func newSharder(cli *fakeLeases, identity string, rebalances *int) *Sharder {
+	return New(cli, cli, Options{
+		Identity:      identity,
+		Namespace:     "service-mapper-system",
+		LeaseDuration: 30 * time.Second,
+		RenewPeriod:   10 * time.Second,
+		VirtualNodes:  64,
+	}, func(context.Context, *Ring) { *rebalances++ })
+}
+
+func TestSharder(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cli := &fakeLeases{leases: map[types.NamespacedName]*coordinationv1.Lease{}}
+	rebalances := 0
+	replicas := []*Sharder{
+		newSharder(cli, "replica-0", &rebalances),
+		newSharder(cli, "replica-1", &rebalances),
+		newSharder(cli, "replica-2", &rebalances),
+	}
+	g.Expect(replicas[0].NeedLeaderElection()).To(BeFalse())
+
+	// replicas own no key until they have listed the members
+	g.Expect(replicas[0].Ready()).To(BeFalse())
+	g.Expect(replicas[0].Owns("key")).To(BeFalse())
+
+	for _, s := range replicas {
+		g.Expect(s.sync(ctx)).To(Succeed())
+	}
+	g.Expect(replicas[0].sync(ctx)).To(Succeed())
+	g.Expect(replicas[0].Members()).To(Equal([]string{"replica-0", "replica-1", "replica-2"}))
+	g.Expect(replicas[2].Members()).To(Equal([]string{"replica-0", "replica-1", "replica-2"}))
+	g.Expect(rebalances).To(Equal(4))
+
+	owners := func(ss []*Sharder) map[string]int {
+		counts := map[string]int{}
+		for _, k := range keys(3000) {
+			n := 0
+			for _, s := range ss {
+				if s.Owns(k) {
+					n++
+				}
+			}
+			counts[k] = n
+		}
+		return counts
+	}
+
+	// the replicas joining do not own the keys of the others until they have
+	// seen them join, replica-1 still using the ring without replica-2
+	g.Expect(replicas[1].Members()).To(Equal([]string{"replica-0", "replica-1"}))
+	unowned := 0
+	for k, n := range owners(replicas) {
+		g.Expect(n).To(BeNumerically("<=", 1), k)
+		if n == 0 {
+			unowned++
+		}
+	}
+	g.Expect(unowned).To(BeNumerically(">", 0))
+
+	// every key has a single owner once handed over, the replicas rebalancing
+	// again
+	handover := time.Now().Add(10 * time.Second)
+	for _, s := range replicas {
+		s.now = func() time.Time { return handover }
+		g.Expect(s.sync(ctx)).To(Succeed())
+	}
+	g.Expect(rebalances).To(Equal(7))
+	for k, n := range owners(replicas) {
+		g.Expect(n).To(Equal(1), k)
+	}
+
+	// the keys of a replica whose Lease expired are taken over
+	later := time.Now().Add(time.Minute)
+	for _, s := range replicas[:2] {
+		s.now = func() time.Time { return later }
+		g.Expect(s.sync(ctx)).To(Succeed())
+	}
+	g.Expect(replicas[0].sync(ctx)).To(Succeed())
+	g.Expect(replicas[0].Members()).To(Equal([]string{"replica-0", "replica-1"}))
+	for k, n := range owners(replicas[:2]) {
+		g.Expect(n).To(Equal(1), k)
+	}
+}
+
+func TestSharderExpires(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cli := &fakeLeases{leases: map[types.NamespacedName]*coordinationv1.Lease{}}
+	rebalances := 0
+	s := newSharder(cli, "replica-0", &rebalances)
+	g.Expect(s.sync(ctx)).To(Succeed())
+	g.Expect(s.Owns("key")).To(BeTrue())
+
+	// a replica that can not renew its Lease keeps its ring, but owns no key
+	// once the other replicas consider it gone
+	cli.err = errors.New("connection refused")
+	now := time.Now()
+	s.now = func() time.Time { return now.Add(20 * time.Second) }
+	g.Expect(s.sync(ctx)).NotTo(Succeed())
+	g.Expect(s.Owns("key")).To(BeTrue())
+
+	s.now = func() time.Time { return now.Add(40 * time.Second) }
+	g.Expect(s.sync(ctx)).NotTo(Succeed())
+	g.Expect(s.Members()).To(Equal([]string{"replica-0"}))
+	g.Expect(s.Owns("key")).To(BeFalse())
+
+	// it owns its keys again once it renewed its Lease
+	cli.err = nil
+	g.Expect(s.sync(ctx)).To(Succeed())
+	g.Expect(s.Owns("key")).To(BeTrue())
+}
+
+func TestSharderDeletesLeaseOnStop(t *testing.T) {
+	g := NewWithT(t)
+
+	cli := &fakeLeases{leases: map[types.NamespacedName]*coordinationv1.Lease{}}
+	rebalances := 0
+	s := newSharder(cli, "replica-0", &rebalances)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		_ = s.Start(ctx)
+	}()
+	g.Eventually(s.Ready).Should(BeTrue())
+	g.Expect(s.Owns("key")).To(BeTrue())
+
+	cancel()
+	g.Eventually(stopped).Should(BeClosed())
+	g.Expect(cli.leases).To(BeEmpty())
+}
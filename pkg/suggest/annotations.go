@@ -0,0 +1,80 @@
+package suggest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+	"github.com/openshift-app-service-poc/service-mapper/pkg/binding"
+)
+
+// AnnotationImportedFrom records the CRD whose Service Binding annotations
+// were imported in a ServiceResourceMap
+const AnnotationImportedFrom = "servicemapper.binding/imported-from"
+
+// HasBindingAnnotations returns true if the CRD carries at least one
+// Service Binding Operator annotation
+func HasBindingAnnotations(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	for a := range crd.Annotations {
+		if _, ok := bindingAnnotationKey(a); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FromBindingAnnotations converts the Service Binding Operator annotations of
+// a CRD, `service.binding(/<name>)?: path=...,objectType=...,sourceKey=...`,
+// into an equivalent ServiceResourceMap. Annotations that can not be
+// translated exactly are skipped and returned as errors, so that the caller
+// can report them.
+func FromBindingAnnotations(crd *apiextensionsv1.CustomResourceDefinition) (*bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, []error, error) {
+	v, err := storageVersion(crd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	as := make([]string, 0, len(crd.Annotations))
+	for a := range crd.Annotations {
+		as = append(as, a)
+	}
+	sort.Strings(as)
+
+	sm := newServiceResourceMap(crd, v, crd.Name)
+	sm.Annotations[AnnotationImportedFrom] = crd.Name
+	sm.Spec.ServiceMap = map[string]string{}
+
+	var skipped []error
+	for _, a := range as {
+		k, ok := bindingAnnotationKey(a)
+		if !ok {
+			continue
+		}
+
+		r, err := binding.ParseRule(crd.Annotations[a])
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("annotation '%s': %w", a, err))
+			continue
+		}
+		sm.Spec.ServiceMap[k] = r.String()
+	}
+
+	if len(sm.Spec.ServiceMap) == 0 {
+		return nil, skipped, fmt.Errorf("CRD '%s' has no Service Binding annotations to import", crd.Name)
+	}
+	return sm, skipped, nil
+}
+
+// bindingAnnotationKey returns the service_map key for a Service Binding
+// annotation: its name, or binding.UnnamedKey for `service.binding`
+func bindingAnnotationKey(a string) (string, bool) {
+	if a == binding.UnnamedKey {
+		return binding.UnnamedKey, true
+	}
+
+	k := strings.TrimPrefix(a, binding.UnnamedKey+"/")
+	return k, k != a && k != ""
+}
@@ -0,0 +1,278 @@
+package suggest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	bindingoperatorscoreoscomv1alpha1 "github.com/openshift-app-service-poc/service-mapper/api/v1alpha1"
+)
+
+const (
+	// AnnotationSource records the CRD a draft ServiceResourceMap was generated from
+	AnnotationSource = "servicemapper.binding/suggested-from"
+	// AnnotationConfidencePrefix prefixes the per-key confidence annotations of a draft
+	AnnotationConfidencePrefix = "servicemapper.binding/confidence."
+)
+
+// Confidence expresses how likely a suggested rule is to be correct
+type Confidence int
+
+const (
+	Low Confidence = iota + 1
+	Medium
+	High
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case High:
+		return "high"
+	case Medium:
+		return "medium"
+	case Low:
+		return "low"
+	}
+	return "unknown"
+}
+
+// Suggestion is a candidate rule for a ServiceResourceMap's service_map
+type Suggestion struct {
+	Key        string
+	Rule       string
+	Confidence Confidence
+
+	// score breaks ties between suggestions with the same confidence
+	score int
+}
+
+// matcher recognizes a scalar field by its name and proposes a rule for it
+type matcher struct {
+	key   string
+	exact []string
+	fuzzy []string
+	types []string
+}
+
+var matchers = []matcher{
+	{key: "host", exact: []string{"host", "hostname", "address"}, fuzzy: []string{"host", "endpoint", "address"}, types: []string{"string"}},
+	{key: "port", exact: []string{"port"}, fuzzy: []string{"port"}, types: []string{"integer", "string"}},
+	{key: "username", exact: []string{"username", "user"}, fuzzy: []string{"username"}, types: []string{"string"}},
+	{key: "database", exact: []string{"database", "dbname", "db"}, fuzzy: []string{"database", "dbname"}, types: []string{"string"}},
+	{key: "type", exact: []string{"engine"}, fuzzy: []string{"engine"}, types: []string{"string"}},
+}
+
+var passwordMatcher = matcher{key: "password", exact: []string{"password", "passwordsecret", "passwordsecretref"}, fuzzy: []string{"password"}}
+
+// FromCRD inspects the openAPIV3Schema of the given CRD and returns a draft
+// ServiceResourceMap. Each rule is annotated with a confidence level so that
+// an administrator can review it before applying.
+func FromCRD(crd *apiextensionsv1.CustomResourceDefinition) (*bindingoperatorscoreoscomv1alpha1.ServiceResourceMap, error) {
+	v, err := storageVersion(crd)
+	if err != nil {
+		return nil, err
+	}
+	if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+		return nil, fmt.Errorf("CRD '%s' version '%s' has no openAPIV3Schema", crd.Name, v.Name)
+	}
+
+	ss := Suggest(v.Schema.OpenAPIV3Schema)
+	if _, ok := ss["type"]; !ok {
+		ss["type"] = Suggestion{Key: "type", Rule: strings.ToLower(crd.Spec.Names.Kind), Confidence: Low}
+	}
+
+	sm := newServiceResourceMap(crd, v, crd.Spec.Names.Singular+"-draft")
+	sm.Annotations[AnnotationSource] = crd.Name
+	sm.Spec.ServiceMap = make(map[string]string, len(ss))
+
+	for k, s := range ss {
+		sm.Spec.ServiceMap[k] = s.Rule
+		sm.Annotations[AnnotationConfidencePrefix+k] = s.Confidence.String()
+	}
+	return sm, nil
+}
+
+func newServiceResourceMap(
+	crd *apiextensionsv1.CustomResourceDefinition,
+	v *apiextensionsv1.CustomResourceDefinitionVersion,
+	name string) *bindingoperatorscoreoscomv1alpha1.ServiceResourceMap {
+	return &bindingoperatorscoreoscomv1alpha1.ServiceResourceMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: bindingoperatorscoreoscomv1alpha1.GroupVersion.String(),
+			Kind:       "ServiceResourceMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{},
+		},
+		Spec: bindingoperatorscoreoscomv1alpha1.ServiceResourceMapSpec{
+			ServiceKindReference: bindingoperatorscoreoscomv1alpha1.ServiceKindReference{
+				ApiGroup: crd.Spec.Group + "/" + v.Name,
+				Kind:     crd.Spec.Names.Plural,
+			},
+		},
+	}
+}
+
+// Suggest walks the schema and returns the best suggestion found for each key
+func Suggest(schema *apiextensionsv1.JSONSchemaProps) map[string]Suggestion {
+	ss := map[string]Suggestion{}
+	if schema == nil {
+		return ss
+	}
+
+	add := func(s Suggestion) {
+		if c, ok := ss[s.Key]; !ok || s.Confidence > c.Confidence ||
+			(s.Confidence == c.Confidence && s.score > c.score) {
+			ss[s.Key] = s
+		}
+	}
+
+	walk(schema, nil, func(path []string, name string, props *apiextensionsv1.JSONSchemaProps) bool {
+		n := strings.ToLower(name)
+
+		if f := secretNameField(n, props); f != "" {
+			rule := fmt.Sprintf("path={%s},objectType=Secret", jsonpath(append(path, f)))
+			if c, ok := passwordMatcher.confidence(n); ok {
+				// the key of SecretKeySelector-like references is read from
+				// the instance, otherwise the entry is guessed
+				if k, ok := props.Properties["key"]; ok && k.Type == "string" {
+					rule += fmt.Sprintf(",sourceKey={%s}", jsonpath(append(path, "key")))
+				} else {
+					rule += ",sourceKey=" + passwordMatcher.key
+					c = Low
+				}
+				add(Suggestion{Key: passwordMatcher.key, Rule: rule, Confidence: c})
+			} else {
+				// generic Secret references are copied as a whole
+				add(Suggestion{Key: name, Rule: rule, Confidence: Medium})
+			}
+			return false
+		}
+
+		for _, m := range matchers {
+			if s, ok := m.match(path, n, props); ok {
+				add(s)
+			}
+		}
+		return true
+	})
+	return ss
+}
+
+func (m matcher) match(path []string, name string, props *apiextensionsv1.JSONSchemaProps) (Suggestion, bool) {
+	if !contains(m.types, props.Type) {
+		return Suggestion{}, false
+	}
+
+	c, ok := m.confidence(name)
+	if !ok {
+		return Suggestion{}, false
+	}
+
+	// values published by the operator in the status are preferred over the
+	// ones requested by the user in the spec
+	score := 0
+	if path[0] == "status" {
+		score++
+	}
+
+	return Suggestion{
+		Key:        m.key,
+		Rule:       fmt.Sprintf("path={%s}", jsonpath(path)),
+		Confidence: c,
+		score:      score,
+	}, true
+}
+
+func (m matcher) confidence(name string) (Confidence, bool) {
+	if contains(m.exact, name) {
+		return High, true
+	}
+
+	for _, f := range m.fuzzy {
+		if strings.Contains(name, f) {
+			return Medium, true
+		}
+	}
+	return 0, false
+}
+
+// secretNameField returns the field holding the Secret's name if the given
+// property looks like a reference to a Secret, an empty string otherwise.
+// Both `fooSecretRef: {name: ...}` and SecretKeySelector-like objects such as
+// `masterUserPassword: {name: ..., key: ...}` are recognized.
+func secretNameField(name string, props *apiextensionsv1.JSONSchemaProps) string {
+	if props.Type != "object" {
+		return ""
+	}
+
+	_, hasKey := props.Properties["key"]
+	if !strings.Contains(name, "secret") && !(hasKey && strings.Contains(name, "password")) {
+		return ""
+	}
+
+	for _, f := range []string{"name", "secretName"} {
+		if p, ok := props.Properties[f]; ok && p.Type == "string" {
+			return f
+		}
+	}
+	return ""
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// visitFunc is called for every property, returning false skips its children
+type visitFunc func(path []string, name string, props *apiextensionsv1.JSONSchemaProps) bool
+
+// walk visits every property of the schema in a deterministic order.
+// Metadata is skipped as it is common to every resource.
+func walk(schema *apiextensionsv1.JSONSchemaProps, path []string, visit visitFunc) {
+	names := make([]string, 0, len(schema.Properties))
+	for n := range schema.Properties {
+		if len(path) == 0 && (n == "metadata" || n == "apiVersion" || n == "kind") {
+			continue
+		}
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		p := schema.Properties[n]
+		pp := append(append([]string{}, path...), n)
+		if !visit(pp, n, &p) {
+			continue
+		}
+
+		switch {
+		case p.Type == "object":
+			walk(&p, pp, visit)
+		case p.Type == "array" && p.Items != nil && p.Items.Schema != nil:
+			pp[len(pp)-1] += "[0]"
+			walk(p.Items.Schema, pp, visit)
+		}
+	}
+}
+
+func jsonpath(path []string) string {
+	return "." + strings.Join(path, ".")
+}
+
+func storageVersion(crd *apiextensionsv1.CustomResourceDefinition) (*apiextensionsv1.CustomResourceDefinitionVersion, error) {
+	for i, v := range crd.Spec.Versions {
+		if v.Storage {
+			return &crd.Spec.Versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("CRD '%s' has no storage version", crd.Name)
+}
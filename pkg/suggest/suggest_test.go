@@ -0,0 +1,144 @@
+package suggest
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func object(props map[string]apiextensionsv1.JSONSchemaProps) apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{Type: "object", Properties: props}
+}
+
+func scalar(t string) apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{Type: t}
+}
+
+func dbInstanceCRD() *apiextensionsv1.CustomResourceDefinition {
+	schema := object(map[string]apiextensionsv1.JSONSchemaProps{
+		"metadata": scalar("object"),
+		"spec": object(map[string]apiextensionsv1.JSONSchemaProps{
+			"engine":         scalar("string"),
+			"masterUsername": scalar("string"),
+			"dbName":         scalar("string"),
+			"port":           scalar("integer"),
+			"masterUserPassword": object(map[string]apiextensionsv1.JSONSchemaProps{
+				"name":      scalar("string"),
+				"namespace": scalar("string"),
+				"key":       scalar("string"),
+			}),
+			"tlsSecretRef": object(map[string]apiextensionsv1.JSONSchemaProps{
+				"name": scalar("string"),
+			}),
+		}),
+		"status": object(map[string]apiextensionsv1.JSONSchemaProps{
+			"endpoint": object(map[string]apiextensionsv1.JSONSchemaProps{
+				"address": scalar("string"),
+				"port":    scalar("integer"),
+			}),
+		}),
+	})
+
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "dbinstances.rds.services.k8s.aws"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "rds.services.k8s.aws",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:     "DBInstance",
+				Plural:   "dbinstances",
+				Singular: "dbinstance",
+			},
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Storage: true, Schema: &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &schema}},
+			},
+		},
+	}
+}
+
+func TestFromCRD(t *testing.T) {
+	g := NewWithT(t)
+
+	sm, err := FromCRD(dbInstanceCRD())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(sm.Name).To(Equal("dbinstance-draft"))
+	g.Expect(sm.Spec.ServiceKindReference.ApiGroup).To(Equal("rds.services.k8s.aws/v1alpha1"))
+	g.Expect(sm.Spec.ServiceKindReference.Kind).To(Equal("dbinstances"))
+	g.Expect(sm.Spec.ServiceMap).To(Equal(map[string]string{
+		"host":         "path={.status.endpoint.address}",
+		"port":         "path={.status.endpoint.port}",
+		"username":     "path={.spec.masterUsername}",
+		"database":     "path={.spec.dbName}",
+		"type":         "path={.spec.engine}",
+		"password":     "path={.spec.masterUserPassword.name},objectType=Secret,sourceKey={.spec.masterUserPassword.key}",
+		"tlsSecretRef": "path={.spec.tlsSecretRef.name},objectType=Secret",
+	}))
+	g.Expect(sm.Annotations).To(HaveKeyWithValue(AnnotationConfidencePrefix+"password", "medium"))
+	g.Expect(sm.Annotations).To(HaveKeyWithValue(AnnotationSource, "dbinstances.rds.services.k8s.aws"))
+	g.Expect(sm.Annotations).To(HaveKeyWithValue(AnnotationConfidencePrefix+"host", "high"))
+	g.Expect(sm.Annotations).To(HaveKeyWithValue(AnnotationConfidencePrefix+"username", "medium"))
+}
+
+func TestFromCRDWithoutSchema(t *testing.T) {
+	g := NewWithT(t)
+
+	crd := dbInstanceCRD()
+	crd.Spec.Versions[0].Schema = nil
+
+	_, err := FromCRD(crd)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestFromBindingAnnotations(t *testing.T) {
+	g := NewWithT(t)
+
+	crd := dbInstanceCRD()
+	crd.Annotations = map[string]string{
+		"service.binding":          "path={.spec.masterUserPassword.name},objectType=Secret,sourceKey=password",
+		"service.binding/host":     "path={.status.endpoint.address}",
+		"service.binding/type":     "postgresql",
+		"service.binding/uri":      "path={.status.uris},elementType=sliceOfMaps,sourceKey=type,sourceValue=url",
+		"kubectl.kubernetes.io/fo": "bar",
+	}
+	g.Expect(HasBindingAnnotations(crd)).To(BeTrue())
+
+	sm, skipped, err := FromBindingAnnotations(crd)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(skipped).To(HaveLen(1))
+	g.Expect(sm.Name).To(Equal("dbinstances.rds.services.k8s.aws"))
+	g.Expect(sm.Annotations).To(HaveKeyWithValue(AnnotationImportedFrom, "dbinstances.rds.services.k8s.aws"))
+	g.Expect(sm.Spec.ServiceMap).To(Equal(map[string]string{
+		"service.binding": "path={.spec.masterUserPassword.name},objectType=Secret,sourceKey=password",
+		"host":            "path={.status.endpoint.address}",
+		"type":            "postgresql",
+	}))
+}
+
+func TestFromBindingAnnotationsWithoutAnnotations(t *testing.T) {
+	g := NewWithT(t)
+
+	crd := dbInstanceCRD()
+	g.Expect(HasBindingAnnotations(crd)).To(BeFalse())
+
+	_, _, err := FromBindingAnnotations(crd)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestSuggestPasswordSecretWithoutKey(t *testing.T) {
+	g := NewWithT(t)
+
+	schema := object(map[string]apiextensionsv1.JSONSchemaProps{
+		"spec": object(map[string]apiextensionsv1.JSONSchemaProps{
+			"passwordSecretRef": object(map[string]apiextensionsv1.JSONSchemaProps{
+				"name": scalar("string"),
+			}),
+		}),
+	})
+
+	ss := Suggest(&schema)
+	g.Expect(ss).To(HaveKey("password"))
+	g.Expect(ss["password"].Rule).To(Equal("path={.spec.passwordSecretRef.name},objectType=Secret,sourceKey=password"))
+	g.Expect(ss["password"].Confidence).To(Equal(Low))
+}